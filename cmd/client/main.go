@@ -7,10 +7,12 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"time"
 
 	"mini-spark/internal/common"
+	"mini-spark/internal/dag"
 )
 
 // Configuración
@@ -19,15 +21,45 @@ var (
 	submitFile string
 	jobIDArg   string
 	poll       bool
+	resultsID  string
+	cancelID   string
+	showPools  bool
+	offset     int
+	limit      int
+	masters    *common.MasterList // Masters de -master; las peticiones siguen al líder
 )
 // Se ejecuta el cliente
 func main() {
 	// Definir argumentos de línea de comandos
-	flag.StringVar(&masterURL, "master", "http://localhost:8080", "URL del Master")
+	flag.StringVar(&masterURL, "master", "http://localhost:8080", "URL del Master (o lista separada por comas: líder y standby)")
 	flag.StringVar(&submitFile, "submit", "", "Ruta al archivo JSON con la definición del Job")
 	flag.StringVar(&jobIDArg, "status", "", "Consultar estado de un Job ID específico")
 	flag.BoolVar(&poll, "watch", false, "Si se usa con -submit o -status, se queda monitoreando hasta finalizar")
+	flag.StringVar(&resultsID, "results", "", "Mostrar los resultados publicados de un Job ID")
+	flag.IntVar(&offset, "offset", 0, "Con -results: primer registro a mostrar")
+	flag.IntVar(&limit, "limit", 0, "Con -results: máximo de registros a mostrar (0 = todos)")
+	flag.StringVar(&cancelID, "cancel", "", "Cancelar un Job ID en curso")
+	flag.BoolVar(&showPools, "pools", false, "Mostrar la cola de cada pool de planificación")
 	flag.Parse()
+	masters = common.NewMasterList(masterURL)
+
+	// MODO -2: Estado de los pools
+	if showPools {
+		printPools()
+		return
+	}
+
+	// MODO -1: Cancelar Job
+	if cancelID != "" {
+		cancelJob(cancelID)
+		return
+	}
+
+	// MODO 0: Ver Resultados
+	if resultsID != "" {
+		printResults(resultsID, offset, limit)
+		return
+	}
 
 	// MODO 1: Consultar Estado
 	if jobIDArg != "" {
@@ -65,16 +97,44 @@ func main() {
 	fmt.Println("Uso del Cliente:")
 	fmt.Println("  Enviar Job:      go run cmd/client/main.go -submit jobs_specs/wordcount.json -watch")
 	fmt.Println("  Consultar Job:   go run cmd/client/main.go -status <JOB_ID>")
+	fmt.Println("  Ver Resultados:  go run cmd/client/main.go -results <JOB_ID> [-offset N] [-limit M]")
+	fmt.Println("  Cancelar Job:    go run cmd/client/main.go -cancel <JOB_ID>")
+	fmt.Println("  Ver Pools:       go run cmd/client/main.go -pools")
 	flag.PrintDefaults()
 }
+// GET al Master líder (sigue al líder si el configurado está en standby o caído)
+func getFromMaster(path string) (*http.Response, error) {
+	return masters.Do(func(base string) (*http.Request, error) {
+		return http.NewRequest(http.MethodGet, base+path, nil)
+	})
+}
 // Enviar Job al Master y retornar el Job ID asignado
 func submitJob(data []byte) string {
-	resp, err := http.Post(masterURL+"/api/v1/jobs", "application/json", bytes.NewBuffer(data))
+	resp, err := masters.Do(func(base string) (*http.Request, error) {
+		req, err := http.NewRequest(http.MethodPost, base+"/api/v1/jobs", bytes.NewReader(data))
+		if err == nil { req.Header.Set("Content-Type", "application/json") }
+		return req, err
+	})
 	if err != nil {
 		panic(fmt.Sprintf("Error contactando master: %v", err))
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusBadRequest {
+		// Spec inválido: el Master devuelve la lista completa de problemas
+		var rejected struct {
+			Errors []dag.ValidationError `json:"errors"`
+		}
+		body, _ := io.ReadAll(resp.Body)
+		if json.Unmarshal(body, &rejected) == nil && len(rejected.Errors) > 0 {
+			fmt.Println(" Master rechazó el job (spec inválido):")
+			for _, e := range rejected.Errors {
+				fmt.Printf("   - %s\n", e.Error())
+			}
+			os.Exit(1)
+		}
+		panic(fmt.Sprintf("Master rechazó job: %s", string(body)))
+	}
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
 		panic(fmt.Sprintf("Master rechazó job: %s", string(body)))
@@ -86,7 +146,7 @@ func submitJob(data []byte) string {
 }
 // Consultar estado de un Job por su ID
 func checkStatus(id string) {
-	resp, err := http.Get(fmt.Sprintf("%s/api/v1/jobs/%s", masterURL, id))
+	resp, err := getFromMaster("/api/v1/jobs/" + id)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
 		return
@@ -99,7 +159,7 @@ func checkStatus(id string) {
 func monitorJob(jobID string) {
 	fmt.Println(" Monitoreando...")
 	for {
-		resp, err := http.Get(fmt.Sprintf("%s/api/v1/jobs/%s", masterURL, jobID))
+		resp, err := getFromMaster("/api/v1/jobs/" + jobID)
 		if err != nil { break }
 		
 		var status map[string]interface{}
@@ -110,10 +170,99 @@ func monitorJob(jobID string) {
 		fmt.Printf("\r>> Estado: %s   ", st) 
 
 		// Verificar si el Job ha finalizado
-		if st == "SUCCEEDED" || st == "FAILED" {
+		if st == "SUCCEEDED" || st == "FAILED" || st == "CANCELLED" {
 			fmt.Println("\n Finalizado.")
+			if req, ok := status["Request"].(map[string]interface{}); ok && st == "SUCCEEDED" {
+				fmt.Printf(" Resultados en: %v (ver con -results %s)\n", req["output_path"], jobID)
+			}
 			break
 		}
 		time.Sleep(1 * time.Second)
 	}
-}
\ No newline at end of file
+}
+// Mostrar los resultados de un Job, pidiendo páginas al Master hasta llegar a max registros (0 = todos)
+func printResults(jobID string, offset, max int) {
+	const pageSize = 1000
+	shown := 0
+	cursor := "" // Tras la primera página el Master reanuda desde next_cursor en vez de releer la salida
+	for {
+		size := pageSize
+		if max > 0 && max-shown < size { size = max - shown }
+
+		path := fmt.Sprintf("/api/v1/jobs/%s/results?offset=%d&limit=%d", jobID, offset, size)
+		if cursor != "" { path += "&cursor=" + url.QueryEscape(cursor) }
+		resp, err := getFromMaster(path)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		if resp.StatusCode != http.StatusOK {
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			fmt.Printf("Error (%d): %s", resp.StatusCode, body)
+			os.Exit(1)
+		}
+		var page struct {
+			Records    []json.RawMessage `json:"records"`
+			NextOffset int               `json:"next_offset"`
+			NextCursor string            `json:"next_cursor"`
+			HasMore    bool              `json:"has_more"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			fmt.Printf("Respuesta inválida del Master: %v\n", err)
+			os.Exit(1)
+		}
+
+		for _, rec := range page.Records { fmt.Println(string(rec)) }
+		shown += len(page.Records)
+		offset = page.NextOffset
+		cursor = page.NextCursor
+		if !page.HasMore || (max > 0 && shown >= max) { break }
+	}
+	fmt.Fprintf(os.Stderr, " %d registros.\n", shown)
+}
+
+// Cancelar un Job en curso
+func cancelJob(jobID string) {
+	resp, err := masters.Do(func(base string) (*http.Request, error) {
+		return http.NewRequest(http.MethodDelete, base+"/api/v1/jobs/"+jobID, nil)
+	})
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		fmt.Printf(" No se pudo cancelar (%d): %s", resp.StatusCode, body)
+		os.Exit(1)
+	}
+	fmt.Printf(" Job %s cancelado.\n", jobID)
+}
+
+// Mostrar la profundidad de cola y las tareas en curso de cada pool
+func printPools() {
+	resp, err := getFromMaster("/api/v1/pools")
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+	var pools []struct {
+		Pool    string `json:"pool"`
+		Weight  int    `json:"weight"`
+		Pending int    `json:"pending"`
+		Running int    `json:"running"`
+		Jobs    int    `json:"jobs"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&pools); err != nil {
+		fmt.Printf("Respuesta inválida del Master: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf(" %-16s %6s %8s %8s %5s\n", "POOL", "PESO", "EN COLA", "EN CURSO", "JOBS")
+	for _, p := range pools {
+		fmt.Printf(" %-16s %6d %8d %8d %5d\n", p.Pool, p.Weight, p.Pending, p.Running, p.Jobs)
+	}
+}
@@ -0,0 +1,11 @@
+//go:build chaos
+
+package main
+
+import "mini-spark/internal/udf"
+
+// Solo en el binario de caos (make chaos-test): el Master valida los nombres de UDF al recibir el Job,
+// así que necesita conocer "map_slow". Nunca la ejecuta; la versión lenta real vive en cmd/worker_test.
+func init() {
+	udf.UDFRegistry["map_slow"] = udf.UDFRegistry["map_wordcount"]
+}
@@ -1,17 +1,96 @@
 package main
 
 import (
+	"flag"
+	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
 	"mini-spark/internal/master"
 	"mini-spark/internal/storage"
 )
 
 func main() {
-	// 1. Inicializar Componentes del Master
-	store := storage.NewJobStore()
+	policyName := flag.String("policy", master.PolicyLeastLoaded, "Política de planificación: least-loaded, round-robin, locality o fair-share")
+	poolsSpec := flag.String("pools", "", "Peso de cada pool en el reparto de slots (ej. batch=1,interactive=3; sin configurar = 1)")
+	stateDir := flag.String("state-dir", "./data/master", "Directorio del WAL y snapshots del Master (vacío = estado solo en memoria)")
+	port := flag.Int("port", 8080, "Puerto del Master")
+	advertise := flag.String("advertise", "", "URL con la que workers y clientes llegan a este Master (default http://localhost:<port>)")
+	ha := flag.Bool("ha", false, "Alta disponibilidad: compite por el liderazgo con otros Masters del mismo -state-dir")
+	leaseTTL := flag.Duration("lease-ttl", master.DefaultLeaseTTL, "Con -ha: vigencia del lease de líder")
+	flag.Parse()
+
+	policy, err := master.NewSchedulingPolicy(*policyName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	pools, err := master.ParsePoolWeights(*poolsSpec)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *ha && *stateDir == "" {
+		log.Fatal("-ha requiere un -state-dir compartido entre los Masters")
+	}
+	if *advertise == "" { *advertise = fmt.Sprintf("http://localhost:%d", *port) }
+
+	// 1. Atender HTTP desde el arranque: en standby, el gate responde 503 indicando el líder
+	var lease *master.Lease
+	var fenceable atomic.Pointer[storage.DurableStore] // Estado a cortar si se pierde el liderazgo
+	if *ha {
+		host, _ := os.Hostname()
+		lease = master.NewLease(*stateDir, fmt.Sprintf("%s-%d", host, os.Getpid()), *advertise, *leaseTTL)
+	}
+	gate := master.NewLeaderGate(lease)
+	go func() {
+		if err := http.ListenAndServe(fmt.Sprintf(":%d", *port), gate); err != nil {
+			log.Fatal(err)
+		}
+	}()
+	log.Printf(" Master iniciado en puerto :%d (%s)", *port, *advertise)
+
+	if lease != nil {
+		log.Printf("   - Alta disponibilidad: esperando el lease de líder en %s...", *stateDir)
+		lease.AwaitLeadership()
+		log.Printf("[HA] Este Master es el LÍDER")
+		// Renovar desde ya (cargar el WAL puede tardar más que el TTL). Sin lease otro Master
+		// puede estar escribiendo el estado: se cortan las escrituras antes de que el lease venza y se sale.
+		go lease.KeepAlive(func() {
+			if s := fenceable.Load(); s != nil { s.Fence() }
+			log.Fatal("[HA] Liderazgo perdido, deteniendo este Master")
+		})
+	}
+
+	// 2. Inicializar Componentes del Master (solo el líder abre el estado compartido)
+	var store storage.JobStore = storage.NewMemoryStore()
+	if *stateDir != "" {
+		durable, err := storage.OpenDurableStore(*stateDir)
+		if err != nil {
+			log.Fatalf("No se pudo abrir el estado del Master en %s: %v", *stateDir, err)
+		}
+		store = durable
+		fenceable.Store(durable)
+	}
 	registry := master.NewWorkerRegistry()
 	scheduler := master.NewScheduler(registry, store)
+	scheduler.SetPolicy(policy)
+	scheduler.SetPoolWeights(pools)
+	if n := scheduler.Recover(); n > 0 {
+		log.Printf("   - %d Jobs retomados tras el reinicio", n)
+	}
+
+	// Al detener el Master se guarda un snapshot final (el WAL ya tiene todo, esto acorta la recuperación)
+	// y se libera el lease para que el standby no espere a que venza
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-stop
+		if err := store.Close(); err != nil { log.Printf("Error guardando el estado del Master: %v", err) }
+		if lease != nil { lease.Release() }
+		os.Exit(0)
+	}()
 
 	server := &master.MasterServer{
 		Scheduler: scheduler,
@@ -19,22 +98,26 @@ func main() {
 		Store:     store,
 	}
 
-	// 2. Definir Rutas (API RESTful + Internas)
+	// 3. Definir Rutas (API RESTful + Internas)
 	mux := http.NewServeMux()
 	
 	// API Cliente (Para recibir Jobs)
 	mux.HandleFunc("/api/v1/jobs", server.HandleSubmitJob)      
 	mux.HandleFunc("/api/v1/jobs/", server.HandleGetJob)        
+	mux.HandleFunc("GET /api/v1/jobs/{id}/results", server.HandleGetResults)
+	mux.HandleFunc("DELETE /api/v1/jobs/{id}", server.HandleCancelJob)
+	mux.HandleFunc("GET /api/v1/pools", server.HandleGetPools)
 
 	// API Interna (Comunicación Worker -> Master)
 	mux.HandleFunc("/heartbeat", server.HandleHeartbeat)
 	mux.HandleFunc("/report", server.HandleReport)
 
-	log.Println(" Master iniciado en puerto :8080")
+	log.Printf("   - Política de planificación: %s", policy.Name())
+	if len(pools) > 0 { log.Printf("   - Pools: %s", *poolsSpec) }
+	if *stateDir != "" { log.Printf("   - Estado persistido en %s", *stateDir) }
 	log.Println("   - Esperando workers...")
 	
-	// 3. Bloquear y escuchar
-	if err := http.ListenAndServe(":8080", mux); err != nil {
-		log.Fatal(err)
-	}
-}
\ No newline at end of file
+	// 4. Empezar a atender como líder y bloquear
+	gate.Activate(mux)
+	select {}
+}
@@ -8,8 +8,10 @@ import (
 func main() {
 	// se definen los flags
 	port := flag.Int("port", 8081, "Puerto del worker")
-	master := flag.String("master", "http://localhost:8080", "URL del Master")
+	master := flag.String("master", "http://localhost:8080", "URL del Master (o lista separada por comas: líder y standby)")
+	slots := flag.Int("slots", 4, "Tareas simultáneas (hilos del pool)")
+	mem := flag.Uint64("mem", 2048, "Memoria para tareas en MB (0 = sin límite)")
 	flag.Parse()
 
-	worker.StartServer(*port, *master)
+	worker.StartServer(*port, *master, *slots, *mem)
 }
\ No newline at end of file
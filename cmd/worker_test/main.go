@@ -1,13 +1,10 @@
 package main
 
 import (
-	"encoding/json"
 	"flag"
 	"log"
-	"strings"
 	"time"
 
-	"mini-spark/internal/common"
 	"mini-spark/internal/udf"
 	"mini-spark/internal/worker"
 )
@@ -16,31 +13,23 @@ func main() {
 	// --- INYECCIÓN DE CÓDIGO DE PRUEBA ---
 	// Registramos una UDF "lenta" sin tocar el código base
 	log.Println("INICIANDO WORKER EN MODO DE PRUEBA (CON DELAY)")
-	
+
+	wordcount := udf.UDFRegistry["map_wordcount"].(udf.UDFMapFn)
 	udf.UDFRegistry["map_slow"] = udf.UDFMapFn(func(r udf.Record) []udf.Record {
 		// Simular carga pesada (500ms por registro para darte tiempo de matarlo)
 		time.Sleep(500 * time.Millisecond)
 
 		// Lógica original de wordcount
-		clean := strings.Map(func(r rune) rune {
-			if strings.ContainsRune(".,;?!-", r) { return -1 }
-			return r
-		}, string(r))
-		words := strings.Fields(clean)
-		var results []udf.Record
-		for _, w := range words {
-			kv := common.KeyValue{Key: strings.ToLower(w), Value: "1"}
-			b, _ := json.Marshal(kv)
-			results = append(results, udf.Record(b))
-		}
-		return results
+		return wordcount(r)
 	})
 	// -------------------------------------
 
 	// Arranque normal del Worker
 	port := flag.Int("port", 8081, "Puerto del worker")
-	master := flag.String("master", "http://localhost:8080", "URL del Master")
+	master := flag.String("master", "http://localhost:8080", "URL del Master (o lista separada por comas: líder y standby)")
+	slots := flag.Int("slots", 4, "Tareas simultáneas (hilos del pool)")
+	mem := flag.Uint64("mem", 2048, "Memoria para tareas en MB (0 = sin límite)")
 	flag.Parse()
 
-	worker.StartServer(*port, *master)
-}
\ No newline at end of file
+	worker.StartServer(*port, *master, *slots, *mem)
+}
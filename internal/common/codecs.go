@@ -0,0 +1,30 @@
+package common
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// Codecs de compresión de entrada (detectados por la extensión del archivo)
+const (
+	CodecNone  = ""
+	CodecGzip  = "GZIP"
+	CodecBzip2 = "BZIP2"
+)
+
+var codecByExtension = map[string]string{
+	".gz":   CodecGzip,
+	".gzip": CodecGzip,
+	".bz2":  CodecBzip2,
+}
+
+// InputCodec devuelve el codec con el que está comprimido un archivo de entrada (CodecNone si es texto plano)
+func InputCodec(path string) string {
+	return codecByExtension[strings.ToLower(filepath.Ext(path))]
+}
+
+// IsSplittable indica si un archivo puede partirse en rangos de bytes. Un stream comprimido
+// solo puede leerse desde el principio, así que se procesa entero en una sola tarea.
+func IsSplittable(path string) bool {
+	return InputCodec(path) == CodecNone
+}
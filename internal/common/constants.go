@@ -9,6 +9,18 @@ const (
 	OpTypeReduceByKey   = "REDUCE_BY_KEY"
 	OpTypeJoin          = "JOIN"
 	OpTypeFlatMap       = "FLAT_MAP"
+	// Joins externos: el motor completa el lado ausente, la UDF (UDFJoinPairFn) solo formatea el par
+	OpTypeLeftOuterJoin  = "LEFT_OUTER_JOIN"
+	OpTypeRightOuterJoin = "RIGHT_OUTER_JOIN"
+	OpTypeFullOuterJoin  = "FULL_OUTER_JOIN"
+	// Orden global por clave: muestrea las claves, reparte por rangos y ordena cada rango (sin UDF)
+	OpTypeSortByKey = "SORT_BY_KEY"
+	// Orden de un SORT_BY_KEY (OperationNode.Order)
+	SortAscending  = "ASC"
+	SortDescending = "DESC"
+	// BROADCAST_JOIN (OperationNode.Broadcast): el lado indicado se replica entero y el otro no se reparte por clave
+	BroadcastLeft  = "LEFT"
+	BroadcastRight = "RIGHT"
 	// Agrega más tipos si implementas JOIN o AGGREGATE en fases posteriores
 	
 	
@@ -17,11 +29,16 @@ const (
 	SourceTypeFile    = "FILE"      // Leer de un archivo de entrada (InputPath)
 	SourceTypeShuffle = "SHUFFLE"   // Leer de otros workers (a traves de ShuffleMap)
 	SourceTypeNone    = "NONE"      // Para tareas que no necesitan entrada (ej. tarea inicial)
+
+	// Formatos de archivo de entrada (InputSpec.Format)
+	InputFormatText  = "TEXT"  // Una línea = un registro
+	InputFormatCSV   = "CSV"   // Igual que TEXT, con cabecera opcional (SkipHeader)
+	InputFormatJSONL = "JSONL" // Una línea = un objeto JSON; se descartan líneas inválidas
 	
 	// Tipos de Destino de Datos (TaskOutput.Type)
 	OutputTypeLocalSpill = "LOCAL_SPILL"  // Escribir en archivo temporal del Worker
 	OutputTypeShuffle    = "SHUFFLE"     // Salida particionada (N archivos)
-	//OutputTypeFinal      = "FINAL_OUTPUT" // Escribir en el OutputPath final
+	OutputTypeFinal      = "FINAL_OUTPUT" // Escribir en el OutputPath del Job (con commit, ver output.go)
 	
 	
 	
@@ -34,6 +51,7 @@ const (
 	JobStatusRunning   = "RUNNING"
 	JobStatusFailed    = "FAILED"
 	JobStatusSucceeded = "SUCCEEDED"
+	JobStatusCancelled = "CANCELLED" // Detenido a pedido del cliente (DELETE /api/v1/jobs/{id})
 
 	// Estados de Tarea
 	TaskStatusPending     = "PENDING"
@@ -43,5 +61,6 @@ const (
 	
 	// Configuración
 	MaxTaskRetries = 3
+	DefaultPool    = "default" // Pool de los Jobs que no declaran uno
 )
 
@@ -13,4 +13,25 @@ type OperationNode struct {
 	Key        string 		`json:"key,omitempty"` // Para reduce/join
 	Dependencies []string 	`json:"dependencies"` // IDs de nodos previos
 	NumPartitions int    	`json:"partitions"` // Calculado internamente o config global
+	CombineUDF string		`json:"combine_udf,omitempty"` // REDUCE_BY_KEY: UDF que combina resultados parciales de una clave (permite dividir claves calientes)
+	Order      string		`json:"order,omitempty"` // SORT_BY_KEY: "ASC" (default) o "DESC"
+	Broadcast  string		`json:"broadcast,omitempty"` // JOIN: lado chico ("LEFT" o "RIGHT") que se replica en cada tarea del lado grande (BROADCAST_JOIN)
+	Input      *InputSpec 	`json:"input,omitempty"` // Solo nodos fuente: origen propio (si no, JobRequest.InputPath)
+}
+
+// InputSpec describe el origen de datos de un nodo fuente.
+// Path (y cada entrada de Paths) puede ser un archivo, un directorio o un patrón glob (ej. "logs/2024-01-*.log").
+type InputSpec struct {
+	Path       string   `json:"path,omitempty"`
+	Paths      []string `json:"paths,omitempty"` // Lista adicional de archivos/directorios/globs
+	Format     string   `json:"format,omitempty"` // TEXT (default), CSV o JSONL
+	SkipHeader bool     `json:"skip_header,omitempty"` // CSV: descartar la primera línea de cada archivo
+	IncludeFileName bool `json:"include_file_name,omitempty"` // Entregar a la UDF "archivo\tlínea" (ver udf.SplitFileRecord)
+}
+
+// Patterns devuelve todas las rutas declaradas (Path seguido de Paths).
+func (in InputSpec) Patterns() []string {
+	var patterns []string
+	if in.Path != "" { patterns = append(patterns, in.Path) }
+	return append(patterns, in.Paths...)
 }
\ No newline at end of file
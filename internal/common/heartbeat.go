@@ -6,5 +6,8 @@ type Heartbeat struct {
 	Status     		string `json:"status"`
 	ActiveTasks 	int    `json:"active_tasks"`
 	MemUsageMB 		uint64 `json:"mem_usage_mb"` // Memoria usada en MB
+	Slots 			int    `json:"slots"`           // Hilos del pool de ejecución (tareas simultáneas)
+	MemCapacityMB 	uint64 `json:"mem_capacity_mb"` // Memoria disponible para tareas en MB (0 = sin límite)
 	LastHeartbeat 	int64  `json:"last_heartbeat"` // Timestamp del último heartbeat
+	Jobs 			[]string `json:"jobs,omitempty"` // Jobs con tareas en curso o shuffle en disco en este worker
 }
\ No newline at end of file
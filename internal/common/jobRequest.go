@@ -3,7 +3,23 @@ package common
 type JobRequest struct {
 	JobID      string `json:"job_id"` // Generado por el sistema si viene vacío
 	Name       string `json:"name"`
-	InputPath  string `json:"path"`
+	InputPath  string `json:"path"`  // Archivo, directorio o glob de entrada por defecto de los nodos fuente
+	InputPaths []string `json:"paths,omitempty"` // Rutas adicionales (mismas reglas que InputPath)
+	OutputPath string `json:"output_path,omitempty"` // Directorio de resultados (default ./data/outputs/<job_id>)
 	NumPartitions int    `json:"partitions"`
+	TaskTimeoutSec int   `json:"task_timeout_sec,omitempty"` // Límite por tarea en segundos (0 = default del worker)
+	Pool       string `json:"pool,omitempty"`     // Pool de planificación (default "default")
+	Priority   int    `json:"priority,omitempty"` // Prioridad dentro del pool (mayor = antes)
+	Adaptive   *AdaptiveSpec `json:"adaptive,omitempty"` // Tareas de los stages de shuffle según el tamaño real de sus particiones
+	BroadcastJoinBytes int64 `json:"broadcast_join_bytes,omitempty"` // JOIN con un lado de hasta estos bytes: se ejecuta como BROADCAST_JOIN (0 = solo los marcados)
 	DAG        DAG    `json:"dag"`
-}
\ No newline at end of file
+}
+// AdaptiveSpec ajusta las tareas de cada stage que lee shuffle al tamaño real de sus particiones
+// (ShuffleMeta.Size): las particiones contiguas chicas se fusionan en una sola tarea y una partición
+// demasiado grande, o con una clave caliente, se reparte entre varias (JOIN, o REDUCE_BY_KEY con combine_udf).
+type AdaptiveSpec struct {
+	TargetPartitionBytes int64 `json:"target_partition_bytes"`        // Bytes de entrada buscados por tarea
+	MinPartitions        int   `json:"min_partitions,omitempty"`      // No fusionar por debajo de este número de tareas (default 1)
+	MaxPartitionBytes    int64 `json:"max_partition_bytes,omitempty"` // Dividir las particiones mayores a esto (0 = no dividir por tamaño)
+	HotKeyBytes          int64 `json:"hot_key_bytes,omitempty"`       // Dividir las particiones con una clave de más bytes (0 = no detectar)
+}
@@ -0,0 +1,92 @@
+package common
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Un Master en standby responde 503 con MasterRoleHeader = MasterRoleStandby y, si lo conoce,
+// la URL del líder en LeaderHeader
+const (
+	MasterRoleHeader  = "X-Master-Role"
+	MasterRoleStandby = "standby"
+	LeaderHeader      = "X-Master-Leader"
+)
+
+// MasterList es la lista de Masters conocidos (líder y standby). Cada petición va primero al último
+// que respondió como líder; si no responde o es un standby, sigue su pista o prueba el siguiente.
+type MasterList struct {
+	mu      sync.Mutex
+	urls    []string
+	current int
+}
+
+// NewMasterList lee una lista de URLs separadas por coma (ej. "http://m1:8080,http://m2:8080")
+func NewMasterList(spec string) *MasterList {
+	m := &MasterList{}
+	for _, raw := range strings.Split(spec, ",") {
+		if u := strings.TrimRight(strings.TrimSpace(raw), "/"); u != "" { m.urls = append(m.urls, u) }
+	}
+	return m
+}
+
+// Leader devuelve la URL del Master al que se envía la próxima petición
+func (m *MasterList) Leader() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.urls) == 0 { return "" }
+	return m.urls[m.current]
+}
+
+// follow registra la URL que respondió como líder (la agrega si no estaba en la lista)
+func (m *MasterList) follow(url string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, u := range m.urls {
+		if u == url { m.current = i; return }
+	}
+	m.urls = append(m.urls, url)
+	m.current = len(m.urls) - 1
+}
+
+// candidates devuelve las URLs en orden de prueba, empezando por el líder conocido
+func (m *MasterList) candidates() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]string, 0, len(m.urls))
+	for i := range m.urls { out = append(out, m.urls[(m.current+i)%len(m.urls)]) }
+	return out
+}
+
+// Do envía la petición que arma build (con la URL base de un Master) al líder. Devuelve la primera
+// respuesta que no sea de un standby; si ningún Master responde como líder, el último error.
+func (m *MasterList) Do(build func(base string) (*http.Request, error)) (*http.Response, error) {
+	tried := make(map[string]bool)
+	queue := m.candidates()
+	if len(queue) == 0 { return nil, fmt.Errorf("no hay Masters configurados") }
+
+	var lastErr error
+	for len(queue) > 0 {
+		base := queue[0]
+		queue = queue[1:]
+		if tried[base] { continue }
+		tried[base] = true
+
+		req, err := build(base)
+		if err != nil { return nil, err }
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil { lastErr = err; continue }
+		if resp.StatusCode == http.StatusServiceUnavailable && resp.Header.Get(MasterRoleHeader) == MasterRoleStandby {
+			// Standby: probar primero el líder que indica
+			if hint := strings.TrimRight(resp.Header.Get(LeaderHeader), "/"); hint != "" && !tried[hint] { queue = append([]string{hint}, queue...) }
+			resp.Body.Close()
+			lastErr = fmt.Errorf("%s está en standby", base)
+			continue
+		}
+		m.follow(base)
+		return resp, nil
+	}
+	return nil, lastErr
+}
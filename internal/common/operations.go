@@ -0,0 +1,47 @@
+package common
+
+// IsJoinOp indica si el tipo de operación es un JOIN (interno o externo)
+func IsJoinOp(opType string) bool {
+	switch opType {
+	case OpTypeJoin, OpTypeLeftOuterJoin, OpTypeRightOuterJoin, OpTypeFullOuterJoin:
+		return true
+	}
+	return false
+}
+
+// IsNarrowOp indica si la operación procesa registro a registro (fusionable en un stage, sin shuffle)
+func IsNarrowOp(opType string) bool {
+	switch opType {
+	case OpTypeMap, OpTypeFilter, OpTypeFlatMap:
+		return true
+	}
+	return false
+}
+
+// CanBroadcast indica si un JOIN puede replicar el lado indicado (BroadcastLeft/BroadcastRight):
+// cada registro del otro lado se cruza en una sola tarea con todo el lado replicado. El lado que un
+// JOIN externo conserva sin pareja no se puede replicar (cada tarea lo emitiría otra vez).
+func CanBroadcast(joinType, side string) bool {
+	switch joinType {
+	case OpTypeJoin:
+		return side == BroadcastLeft || side == BroadcastRight
+	case OpTypeLeftOuterJoin:
+		return side == BroadcastRight
+	case OpTypeRightOuterJoin:
+		return side == BroadcastLeft
+	}
+	return false
+}
+
+// KeyLess devuelve el orden de las claves de un SORT_BY_KEY: por bytes de la clave, al revés con DESC
+func KeyLess(order string) func(a, b string) bool {
+	if order == SortDescending { return func(a, b string) bool { return a > b } }
+	return func(a, b string) bool { return a < b }
+}
+
+// InKeyRange indica si la clave cae en el rango [desde, hasta) según less ("" = sin límite)
+func InKeyRange(key string, keyRange []string, less func(a, b string) bool) bool {
+	if len(keyRange) != 2 { return true }
+	if keyRange[0] != "" && less(key, keyRange[0]) { return false }
+	return keyRange[1] == "" || less(key, keyRange[1])
+}
@@ -0,0 +1,48 @@
+package common
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Estructura del directorio de salida final de un Job (protocolo de commit estilo Hadoop):
+//
+//	<output>/_temporary/<task>_attempt_<n>/part-NNNNN   Escritura de cada intento (worker)
+//	<output>/_temporary/committed/part-NNNNN           Intento ganador de cada tarea (commit de tarea)
+//	<output>/part-NNNNN + <output>/_SUCCESS            Resultado publicado (commit del Job)
+const (
+	OutputTempDir      = "_temporary"
+	OutputCommittedDir = "committed"
+	SuccessMarker      = "_SUCCESS"
+)
+
+// ShuffleRoot es la carpeta (relativa al worker) de los archivos intermedios de shuffle: <ShuffleRoot>/<job>/<stage>_...
+const ShuffleRoot = "./data/shuffle"
+
+// JobShuffleDir devuelve la carpeta con todos los archivos de shuffle de un Job
+func JobShuffleDir(jobID string) string {
+	return filepath.Join(ShuffleRoot, jobID)
+}
+
+// IsSafeJobID indica si un JobID puede usarse como nombre de carpeta (sin separadores ni "..")
+func IsSafeJobID(jobID string) bool {
+	return jobID != "" && jobID != "." && jobID != ".." && !strings.ContainsAny(jobID, `/\`)
+}
+
+// PartFileName devuelve el nombre del archivo final de una partición (ej. part-00003)
+func PartFileName(partition int) string {
+	return fmt.Sprintf("part-%05d", partition)
+}
+
+// AttemptShufflePath devuelve un archivo intermedio (shuffle o salida no final) de un intento concreto de una tarea.
+// Como en la salida final, el intento va en el nombre: dos copias de la misma tarea en un mismo directorio
+// (reintento o ejecución especulativa) no se truncan ni se borran los archivos entre sí.
+func AttemptShufflePath(base, taskID string, attempt int, suffix string) string {
+	return fmt.Sprintf("%s_%s_attempt_%d_%s", base, taskID, attempt, suffix)
+}
+
+// AttemptOutputPath devuelve dónde escribe un intento concreto de una tarea con salida final
+func AttemptOutputPath(outputDir, taskID string, attempt, partition int) string {
+	return filepath.Join(outputDir, OutputTempDir, fmt.Sprintf("%s_attempt_%d", taskID, attempt), PartFileName(partition))
+}
@@ -5,23 +5,41 @@ type Task struct {
 	JobID      string `json:"job_id"`
 	StageID    string `json:"stage_id"`
 	Operation OperationNode `json:"operation"`   // Operación a realizar en esta tarea
+	Pipeline  []OperationNode `json:"pipeline,omitempty"` // Operaciones narrow fusionadas que se aplican tras Operation
 	InputPartition TaskInput `json:"input_partition"` // Particion de entrada para esta tarea
 	OutputTarget TaskOutput `json:"output_target"`   // Destino de salida para esta tarea
 	RetryCount  int    `json:"retry_count"`    // Reintentos realizados
+	Attempt     int    `json:"attempt"`        // Número de intento asignado al despachar (único por Master)
 	PartitionIndex int    `json:"partition_index"` // Índice de partición (0 a N-1)
+	TimeoutSec  int    `json:"timeout_sec,omitempty"` // Límite de ejecución (del Job); 0 = default del worker
+	Pool        string `json:"pool,omitempty"`     // Pool del Job (solo lo usa el Scheduler)
+	Priority    int    `json:"priority,omitempty"` // Prioridad del Job dentro de su pool
 }
 
 type TaskInput struct {
 	SourceType 	string `json:"source_type"` // "file" o "shuffle"
 	Path 	 	string `json:"path"`        // Ruta del archivo o ubicación del shuffle
-	Offsets   	[2]int64  `json:"offsets"`      // rango de bytes a leer (start, end)
+	Format 		string `json:"format,omitempty"` // Formato del archivo (si SourceType=FILE)
+	SkipHeader 	bool   `json:"skip_header,omitempty"` // Descartar la cabecera del archivo (CSV)
+	Offsets   	[2]int64  `json:"offsets"`      // rango de bytes a leer [start, end); {0,0} = sin rango (archivo completo)
+	Splits 		[]FileSplit `json:"splits"` // Rangos de uno o más archivos asignados por el Master; nil = usar Path/Offsets, vacío = sin datos
+	IncludeFileName bool `json:"include_file_name,omitempty"` // Anteponer "archivo\t" a cada línea
 	ShuffleMap 	map[string]string `json:"shuffle_map"` // Mapa de WorkerID a URL para descargar datos de Shuffle (si SourceType=SHUFFLE)
+	BroadcastMap map[string]string `json:"broadcast_map,omitempty"` // Broadcast join: URLs del lado replicado (ShuffleMap trae solo el otro lado, si lo lee por shuffle)
+	KeyRange    []string `json:"key_range,omitempty"` // SORT_BY_KEY: claves [desde, hasta) en el orden del nodo ("" = sin límite)
 
 }
 
+// FileSplit es un rango de bytes [start, end) de un archivo de entrada
+type FileSplit struct {
+	Path    string   `json:"path"`
+	Offsets [2]int64 `json:"offsets"`
+}
+
 type TaskOutput struct {
 	Type		  	string `json:"type"` // "file" o "shuffle"
 	Path 		   	string `json:"path"`             // Ruta del archivo o ubicación del shuffle
 	NumPartitions  	int    `json:"partitions"`      // Número de particiones (si DestinationType=SHUFFLE)
 	WorkerID      	string `json:"worker_id"`       // ID del shuffle (si DestinationType=SHUFFLE)
+	Sort          	string `json:"sort,omitempty"`  // Lo lee un SORT_BY_KEY: un solo bloque ordenado por clave ("ASC" o "DESC")
 }
\ No newline at end of file
@@ -5,19 +5,79 @@ type TaskReport struct {
 	StageID			string 		`json:"stage_id"`
 	
 	WorkerID		string 		`json:"worker_id"`
+	Attempt			int 		`json:"attempt"`      // Intento que produjo el reporte (Task.Attempt)
 	Status	  		string 		`json:"status"`       // "completed", "failed", "in_progress"
 	ErrorMsg 		string 		`json:"error_msg"`
 	OutputPath  	string 		`json:"output_path"` // Ruta del archivo de salida !!!Para pruebas
 	Timestamp		int64  		`json:"timestamp"`    // Un entero que representa segundos para facilitar el ordenamiento
 	DurationMs 		int64  		`json:"duration_ms"`  // Duración de la tarea en milisegundos
 	ShuffleOutput 	[]ShuffleMeta 	`json:"shuffle_outputs"` // Metadatos de salidas de shuffle generadas
+	FetchFailure	*ShuffleFetchFailure `json:"fetch_failure,omitempty"` // Salida de shuffle que no se pudo descargar (si falló por eso)
 
 }
 
+// ShuffleFetchFailure identifica la salida de un map que un consumidor no pudo descargar
+type ShuffleFetchFailure struct {
+	WorkerID string `json:"worker_id"` // Worker que debía servir el archivo
+	Path     string `json:"path"`      // Ruta del archivo en ese worker
+}
+
 type ShuffleMeta struct {
 	PartitionKey int 		`json:"partition_key"` // La clave de partición (ej: "part_0_of_4")
 	Path         string 	`json:"path"`          // Ruta local donde está el archivo
 	Size       	 int64 		`json:"size"`       // Tamaño del dato para optimización
+	HotKeys      []KeyCount `json:"hot_keys,omitempty"` // Claves con más bytes en el bloque (muestra acotada, ver worker.keySketch)
+	KeyIndex     []KeyOffset `json:"key_index,omitempty"` // Bloque ordenado (TaskOutput.Sort): clave y offset de un registro cada ~1/64 del bloque
 	//LocationURL  string 	`json:"location_url"`  // URL en el Worker para que otro Worker lo descargue (ej: "http://worker-id:8081/data/...")
 }
 
+
+// KeyCount es el peso estimado de una clave dentro de un bloque de shuffle
+type KeyCount struct {
+	Key     string `json:"key"`
+	Records int64  `json:"records"`
+	Bytes   int64  `json:"bytes"`
+}
+
+// KeyOffset ubica un registro de un bloque ordenado: su clave y el byte donde empieza
+type KeyOffset struct {
+	Key    string `json:"key"`
+	Offset int64  `json:"offset"`
+}
+
+// ShuffleSlice es la parte del shuffle de sus padres que lee una tarea de un stage de reduce/join:
+// las particiones p con p % N en Partitions (N = particiones del stage en el spec). Con MapTasks solo
+// se leen los bloques de esas tareas: de todos los padres, o solo del padre Source si no es vacío
+// (partición de JOIN dividida; el otro lado se lee completo).
+// Una partición de REDUCE dividida se reparte entre tareas Partial (reducen sus bloques y guardan el
+// resultado parcial) y una tarea de merge (MergeOf: índices del layout) que combina esos parciales.
+// En un BROADCAST_JOIN (Broadcast) cada tarea lee los bloques de una tarea de map del lado Source y
+// carga completo en memoria el otro lado.
+// En un SORT_BY_KEY (KeyRange) cada tarea lee, de los bloques ordenados de su padre, solo los bytes
+// que pueden tener claves de su rango [desde, hasta).
+type ShuffleSlice struct {
+	Partitions []int    `json:"partitions"`
+	Source     string   `json:"source,omitempty"`
+	MapTasks   []string `json:"map_tasks,omitempty"`
+	Partial    bool     `json:"partial,omitempty"`
+	MergeOf    []int    `json:"merge_of,omitempty"`
+	Broadcast  bool     `json:"broadcast,omitempty"`
+	KeyRange   []string `json:"key_range,omitempty"`
+}
+
+// Includes indica si la partición (ya reducida módulo N) es de esta tarea
+func (s ShuffleSlice) Includes(partition int) bool {
+	for _, p := range s.Partitions {
+		if p == partition { return true }
+	}
+	return false
+}
+
+// ReadsFrom indica si la tarea lee la salida de shuffle de este reporte
+func (s ShuffleSlice) ReadsFrom(rep TaskReport) bool {
+	if len(s.MapTasks) == 0 || (s.Source != "" && rep.StageID != s.Source) { return true }
+	for _, id := range s.MapTasks {
+		if id == rep.TaskID { return true }
+	}
+	return false
+}
@@ -0,0 +1,185 @@
+package dag
+
+import (
+	"fmt"
+
+	"mini-spark/internal/common"
+)
+
+// Graph es la representación planificada de un DAG de Job: nodos indexados por ID,
+// aristas padre -> hijo y un orden topológico precalculado.
+type Graph struct {
+	nodes    map[string]common.OperationNode
+	parents  map[string][]string // NodeID -> IDs de nodos padre (en orden de declaración)
+	children map[string][]string // NodeID -> IDs de nodos hijo
+	order    []string            // Orden topológico
+}
+
+// NewGraph construye el grafo a partir de DAG.Edges y de OperationNode.Dependencies.
+// Devuelve error si hay IDs duplicados, aristas hacia nodos inexistentes o ciclos.
+// Cada nodo del grafo queda con Dependencies = lista completa de sus padres.
+func NewGraph(d common.DAG) (*Graph, error) {
+	g := &Graph{
+		nodes:    make(map[string]common.OperationNode),
+		parents:  make(map[string][]string),
+		children: make(map[string][]string),
+	}
+
+	declared := make([]string, 0, len(d.Nodes))
+	for _, node := range d.Nodes {
+		if node.ID == "" {
+			return nil, fmt.Errorf("nodo sin id en el DAG")
+		}
+		if _, dup := g.nodes[node.ID]; dup {
+			return nil, fmt.Errorf("id de nodo duplicado: %s", node.ID)
+		}
+		g.nodes[node.ID] = node
+		declared = append(declared, node.ID)
+	}
+
+	// 1. Dependencias declaradas en el nodo (tienen prioridad para fijar el orden de padres)
+	for _, id := range declared {
+		for _, dep := range g.nodes[id].Dependencies {
+			if err := g.addEdge(dep, id); err != nil {
+				return nil, err
+			}
+		}
+	}
+	// 2. Aristas explícitas ["from", "to"]
+	for _, edge := range d.Edges {
+		if len(edge) != 2 {
+			return nil, fmt.Errorf("arista mal formada: %v (se esperaba [from, to])", edge)
+		}
+		if err := g.addEdge(edge[0], edge[1]); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, id := range declared {
+		node := g.nodes[id]
+		node.Dependencies = g.parents[id]
+		g.nodes[id] = node
+	}
+
+	order, err := topologicalSort(declared, g.parents, g.children)
+	if err != nil {
+		return nil, err
+	}
+	g.order = order
+	return g, nil
+}
+
+func (g *Graph) addEdge(from, to string) error {
+	if _, ok := g.nodes[from]; !ok {
+		return fmt.Errorf("arista %s -> %s referencia un nodo inexistente: %s", from, to, from)
+	}
+	if _, ok := g.nodes[to]; !ok {
+		return fmt.Errorf("arista %s -> %s referencia un nodo inexistente: %s", from, to, to)
+	}
+	for _, p := range g.parents[to] {
+		if p == from {
+			return nil // Arista repetida (Edges + Dependencies), se ignora
+		}
+	}
+	g.parents[to] = append(g.parents[to], from)
+	g.children[from] = append(g.children[from], to)
+	return nil
+}
+
+// topologicalSort aplica Kahn respetando el orden de declaración como desempate.
+func topologicalSort(declared []string, parents, children map[string][]string) ([]string, error) {
+	inDegree := make(map[string]int, len(declared))
+	for _, id := range declared {
+		inDegree[id] = len(parents[id])
+	}
+
+	var queue []string
+	for _, id := range declared {
+		if inDegree[id] == 0 {
+			queue = append(queue, id)
+		}
+	}
+
+	order := make([]string, 0, len(declared))
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		order = append(order, id)
+		for _, child := range children[id] {
+			inDegree[child]--
+			if inDegree[child] == 0 {
+				queue = append(queue, child)
+			}
+		}
+	}
+
+	if len(order) != len(declared) {
+		var cyclic []string
+		for _, id := range declared {
+			if inDegree[id] > 0 {
+				cyclic = append(cyclic, id)
+			}
+		}
+		return nil, fmt.Errorf("el DAG contiene un ciclo entre los nodos %v", cyclic)
+	}
+	return order, nil
+}
+
+// Node devuelve la definición de un nodo (con Dependencies completas).
+func (g *Graph) Node(id string) (common.OperationNode, bool) {
+	n, ok := g.nodes[id]
+	return n, ok
+}
+
+// Len devuelve el número de nodos del grafo.
+func (g *Graph) Len() int { return len(g.nodes) }
+
+// Parents devuelve los IDs de los nodos de los que depende id.
+func (g *Graph) Parents(id string) []string { return g.parents[id] }
+
+// Children devuelve los IDs de los nodos que consumen la salida de id.
+func (g *Graph) Children(id string) []string { return g.children[id] }
+
+// TopologicalOrder devuelve todos los nodos de forma que cada padre precede a sus hijos.
+func (g *Graph) TopologicalOrder() []string { return g.order }
+
+// Roots devuelve los nodos fuente (sin padres), en orden topológico.
+func (g *Graph) Roots() []string {
+	var roots []string
+	for _, id := range g.order {
+		if len(g.parents[id]) == 0 {
+			roots = append(roots, id)
+		}
+	}
+	return roots
+}
+
+// Sinks devuelve los nodos terminales (sin hijos), en orden topológico.
+func (g *Graph) Sinks() []string {
+	var sinks []string
+	for _, id := range g.order {
+		if len(g.children[id]) == 0 {
+			sinks = append(sinks, id)
+		}
+	}
+	return sinks
+}
+
+// ReadyChildren devuelve los hijos de id cuyos padres están todos completados
+// según isDone. Es lo que el Scheduler lanza cuando id termina.
+func (g *Graph) ReadyChildren(id string, isDone func(string) bool) []string {
+	var ready []string
+	for _, child := range g.children[id] {
+		allDone := true
+		for _, p := range g.parents[child] {
+			if !isDone(p) {
+				allDone = false
+				break
+			}
+		}
+		if allDone {
+			ready = append(ready, child)
+		}
+	}
+	return ready
+}
@@ -0,0 +1,124 @@
+package dag_test
+
+import (
+	"reflect"
+	"testing"
+
+	"mini-spark/internal/common"
+	"mini-spark/internal/dag"
+)
+
+func TestNewGraph_TopologyFromEdges(t *testing.T) {
+	// Dos fuentes independientes que confluyen en un JOIN y luego un REDUCE.
+	// Los nodos se declaran desordenados a propósito: el orden lo dictan las aristas.
+	d := common.DAG{
+		Nodes: []common.OperationNode{
+			{ID: "reduce", Type: common.OpTypeReduceByKey},
+			{ID: "join", Type: common.OpTypeJoin},
+			{ID: "users", Type: common.OpTypeMap},
+			{ID: "orders", Type: common.OpTypeMap},
+		},
+		Edges: [][]string{{"users", "join"}, {"orders", "join"}, {"join", "reduce"}},
+	}
+
+	g, err := dag.NewGraph(d)
+	if err != nil {
+		t.Fatalf("NewGraph falló: %v", err)
+	}
+
+	if got := g.Roots(); !reflect.DeepEqual(got, []string{"users", "orders"}) {
+		t.Errorf("Raíces incorrectas: %v", got)
+	}
+	if got := g.Sinks(); !reflect.DeepEqual(got, []string{"reduce"}) {
+		t.Errorf("Nodos terminales incorrectos: %v", got)
+	}
+
+	pos := make(map[string]int)
+	for i, id := range g.TopologicalOrder() {
+		pos[id] = i
+	}
+	for _, e := range d.Edges {
+		if pos[e[0]] >= pos[e[1]] {
+			t.Errorf("Orden topológico viola la arista %v: %v", e, g.TopologicalOrder())
+		}
+	}
+
+	join, _ := g.Node("join")
+	if !reflect.DeepEqual(join.Dependencies, []string{"users", "orders"}) {
+		t.Errorf("Dependencies del JOIN no reflejan las aristas: %v", join.Dependencies)
+	}
+
+	// El JOIN solo está listo cuando ambas fuentes terminaron
+	done := map[string]bool{"users": true}
+	isDone := func(id string) bool { return done[id] }
+	if ready := g.ReadyChildren("users", isDone); len(ready) != 0 {
+		t.Errorf("JOIN lanzado con un padre pendiente: %v", ready)
+	}
+	done["orders"] = true
+	if ready := g.ReadyChildren("orders", isDone); !reflect.DeepEqual(ready, []string{"join"}) {
+		t.Errorf("JOIN no quedó listo tras completar ambos padres: %v", ready)
+	}
+}
+
+func TestNewGraph_DependenciesAndErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		dag         common.DAG
+		expectError bool
+	}{
+		{
+			name: "Dependencies sin Edges",
+			dag: common.DAG{Nodes: []common.OperationNode{
+				{ID: "a"}, {ID: "b", Dependencies: []string{"a"}},
+			}},
+		},
+		{
+			name: "Arista duplicada en Edges y Dependencies",
+			dag: common.DAG{
+				Nodes: []common.OperationNode{{ID: "a"}, {ID: "b", Dependencies: []string{"a"}}},
+				Edges: [][]string{{"a", "b"}},
+			},
+		},
+		{
+			name: "Ciclo",
+			dag: common.DAG{
+				Nodes: []common.OperationNode{{ID: "a"}, {ID: "b"}},
+				Edges: [][]string{{"a", "b"}, {"b", "a"}},
+			},
+			expectError: true,
+		},
+		{
+			name: "Arista hacia nodo inexistente",
+			dag: common.DAG{
+				Nodes: []common.OperationNode{{ID: "a"}},
+				Edges: [][]string{{"a", "fantasma"}},
+			},
+			expectError: true,
+		},
+		{
+			name: "Arista mal formada",
+			dag: common.DAG{
+				Nodes: []common.OperationNode{{ID: "a"}, {ID: "b"}},
+				Edges: [][]string{{"a"}},
+			},
+			expectError: true,
+		},
+		{
+			name:        "ID duplicado",
+			dag:         common.DAG{Nodes: []common.OperationNode{{ID: "a"}, {ID: "a"}}},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := dag.NewGraph(tt.dag)
+			if (err != nil) != tt.expectError {
+				t.Fatalf("Se esperaba error=%t, se obtuvo: %v", tt.expectError, err)
+			}
+			if !tt.expectError && len(g.Parents("b")) != 1 {
+				t.Errorf("Se esperaba exactamente un padre para b, obtuvo %v", g.Parents("b"))
+			}
+		})
+	}
+}
@@ -12,13 +12,15 @@ func setupJob(partitions int, opType string) common.JobRequest {
 	return common.JobRequest{
 		JobID: "job-" + uuid.New().String(),
 		InputPath: "/data/input/test.csv",
-		Graph: []common.OperationNode{
-			{
-				ID: "map-stage-1",
-				Type: opType,
-				UDFName: "test_map_func",
-				Dependencies: nil,
-				NumPartitions: partitions,
+		DAG: common.DAG{
+			Nodes: []common.OperationNode{
+				{
+					ID: "map-stage-1",
+					Type: opType,
+					UDFName: "test_map_func",
+					Dependencies: nil,
+					NumPartitions: partitions,
+				},
 			},
 		},
 	}
@@ -43,7 +45,7 @@ func TestGenerateMapTasks(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			job := setupJob(tt.partitions, tt.opType)
-			node := job.Graph[0]
+			node := job.DAG.Nodes[0]
 
 			tasks, err := dag.GenerateMapTasks(job, node)
 
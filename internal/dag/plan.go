@@ -0,0 +1,173 @@
+package dag
+
+import (
+	"mini-spark/internal/common"
+)
+
+// Stage es una cadena de operaciones que se ejecuta dentro de una misma tarea, sin shuffle intermedio.
+// Nodes[0] (la cabeza) puede ser cualquier operación; el resto son operaciones narrow fusionadas.
+// El ID del stage es el del último nodo: es el que produce la salida (y etiqueta el shuffle).
+type Stage struct {
+	ID    string
+	Nodes []common.OperationNode
+}
+
+// Head devuelve la operación que abre el stage (decide si la tarea es de lado Map o Reduce).
+func (s Stage) Head() common.OperationNode { return s.Nodes[0] }
+
+// Pipeline devuelve las operaciones narrow que se aplican después de la cabeza.
+func (s Stage) Pipeline() []common.OperationNode { return s.Nodes[1:] }
+
+// Plan agrupa los nodos del grafo en stages. Los shuffles solo aparecen en los bordes entre stages.
+type Plan struct {
+	graph   *Graph
+	stages  map[string]*Stage // StageID -> Stage
+	stageOf map[string]string // NodeID -> StageID
+	order   []string          // StageIDs en orden topológico
+	broadcast map[string][]string // StageID -> stages replicados que cargan sus JOINs fusionados
+}
+
+// NewPlan construye el grafo del DAG y fusiona las operaciones narrow consecutivas.
+// Un nodo se fusiona con su padre cuando es narrow, tiene un único padre y es el único hijo de ese padre.
+// Un JOIN con lado replicado marcado en el spec (Broadcast) se fusiona igual con el lado que se recorre:
+// el cruce ocurre en las tareas de ese stage y el lado replicado pasa a ser un padre broadcast.
+func NewPlan(d common.DAG) (*Plan, error) {
+	g, err := NewGraph(d)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Plan{
+		graph:   g,
+		stages:  make(map[string]*Stage),
+		stageOf: make(map[string]string),
+		broadcast: make(map[string][]string),
+	}
+
+	// fusedParent: el padre cuyo stage continúa el nodo (false = el nodo abre un stage)
+	fusedParent := func(id string) (string, bool) {
+		node, _ := g.Node(id)
+		parents := g.Parents(id)
+		if common.IsNarrowOp(node.Type) && len(parents) == 1 && len(g.Children(parents[0])) == 1 {
+			return parents[0], true
+		}
+		if common.IsJoinOp(node.Type) && common.CanBroadcast(node.Type, node.Broadcast) && len(node.Dependencies) == 2 {
+			streamed := node.Dependencies[0]
+			if node.Broadcast == common.BroadcastLeft { streamed = node.Dependencies[1] }
+			if len(g.Children(streamed)) == 1 { return streamed, true }
+		}
+		return "", false
+	}
+
+	for _, id := range g.TopologicalOrder() {
+		if _, fused := fusedParent(id); fused {
+			continue // Ya fue incluido en la cadena de su padre
+		}
+		// id es cabeza: extender la cadena mientras el único hijo se fusione con ella
+		head, _ := g.Node(id)
+		chain := []common.OperationNode{head}
+		tail := id
+		var replicated []string
+		for len(g.Children(tail)) == 1 {
+			child := g.Children(tail)[0]
+			if parent, fused := fusedParent(child); !fused || parent != tail { break }
+			next, _ := g.Node(child)
+			for _, dep := range next.Dependencies {
+				if dep != tail { replicated = append(replicated, dep) } // Lado replicado de un JOIN fusionado
+			}
+			tail = child
+			chain = append(chain, next)
+		}
+		p.stages[tail] = &Stage{ID: tail, Nodes: chain}
+		if len(replicated) > 0 { p.broadcast[tail] = replicated }
+		for _, n := range chain {
+			p.stageOf[n.ID] = tail
+		}
+	}
+
+	for _, id := range g.TopologicalOrder() {
+		if _, isTail := p.stages[id]; isTail {
+			p.order = append(p.order, id)
+		}
+	}
+	return p, nil
+}
+
+// Graph devuelve el grafo de nodos sobre el que se construyó el plan.
+func (p *Plan) Graph() *Graph { return p.graph }
+
+// Stage devuelve un stage por su ID.
+func (p *Plan) Stage(id string) (Stage, bool) {
+	s, ok := p.stages[id]
+	if !ok {
+		return Stage{}, false
+	}
+	return *s, true
+}
+
+// StageOf devuelve el ID del stage que contiene al nodo.
+func (p *Plan) StageOf(nodeID string) string { return p.stageOf[nodeID] }
+
+// Len devuelve el número de stages del plan.
+func (p *Plan) Len() int { return len(p.stages) }
+
+// TopologicalOrder devuelve los stages de forma que cada padre precede a sus hijos.
+func (p *Plan) TopologicalOrder() []string { return p.order }
+
+// Parents devuelve los stages cuyo shuffle consume el stage id.
+// Los padres de la cabeza son siempre colas de stage, así que sus IDs son IDs de stage.
+func (p *Plan) Parents(id string) []string {
+	s, ok := p.stages[id]
+	if !ok {
+		return nil
+	}
+	return p.graph.Parents(s.Head().ID)
+}
+
+// BroadcastParents devuelve los stages que el stage id carga completos para sus JOINs fusionados
+// (el lado replicado de un broadcast join). No se leen por shuffle, pero deben terminar antes.
+func (p *Plan) BroadcastParents(id string) []string { return p.broadcast[id] }
+
+// Dependencies devuelve todos los stages que deben terminar antes del stage id: los padres cuyo
+// shuffle lee y sus padres broadcast.
+func (p *Plan) Dependencies(id string) []string {
+	return append(append([]string(nil), p.Parents(id)...), p.BroadcastParents(id)...)
+}
+
+// Children devuelve los stages que consumen la salida del stage id.
+func (p *Plan) Children(id string) []string {
+	var children []string
+	for _, child := range p.graph.Children(id) {
+		children = append(children, p.stageOf[child])
+	}
+	return children
+}
+
+// Roots devuelve los stages fuente (sin dependencias), en orden topológico.
+func (p *Plan) Roots() []string {
+	var roots []string
+	for _, id := range p.order {
+		if len(p.Dependencies(id)) == 0 {
+			roots = append(roots, id)
+		}
+	}
+	return roots
+}
+
+// ReadyChildren devuelve los stages hijos de id cuyas dependencias están todas completadas según isDone.
+func (p *Plan) ReadyChildren(id string, isDone func(string) bool) []string {
+	var ready []string
+	for _, child := range p.Children(id) {
+		allDone := true
+		for _, parent := range p.Dependencies(child) {
+			if !isDone(parent) {
+				allDone = false
+				break
+			}
+		}
+		if allDone {
+			ready = append(ready, child)
+		}
+	}
+	return ready
+}
@@ -0,0 +1,194 @@
+package dag_test
+
+import (
+	"reflect"
+	"testing"
+
+	"mini-spark/internal/common"
+	"mini-spark/internal/dag"
+)
+
+// stageShape resume un plan como StageID -> IDs de sus nodos
+func stageShape(p *dag.Plan) map[string][]string {
+	shape := make(map[string][]string)
+	for _, id := range p.TopologicalOrder() {
+		stage, _ := p.Stage(id)
+		for _, n := range stage.Nodes {
+			shape[id] = append(shape[id], n.ID)
+		}
+	}
+	return shape
+}
+
+func TestNewPlan_StageFusion(t *testing.T) {
+	tests := []struct {
+		name     string
+		dag      common.DAG
+		expected map[string][]string
+		roots    []string
+	}{
+		{
+			name: "FILTER -> MAP -> REDUCE (filter.json)",
+			dag: common.DAG{
+				Nodes: []common.OperationNode{
+					{ID: "filter", Type: common.OpTypeFilter},
+					{ID: "map", Type: common.OpTypeMap},
+					{ID: "reduce", Type: common.OpTypeReduceByKey},
+				},
+				Edges: [][]string{{"filter", "map"}, {"map", "reduce"}},
+			},
+			expected: map[string][]string{"map": {"filter", "map"}, "reduce": {"reduce"}},
+			roots:    []string{"map"},
+		},
+		{
+			name: "Narrow después de REDUCE se fusiona con el REDUCE",
+			dag: common.DAG{
+				Nodes: []common.OperationNode{
+					{ID: "map", Type: common.OpTypeMap},
+					{ID: "reduce", Type: common.OpTypeReduceByKey},
+					{ID: "filter", Type: common.OpTypeFilter},
+					{ID: "flat", Type: common.OpTypeFlatMap},
+				},
+				Edges: [][]string{{"map", "reduce"}, {"reduce", "filter"}, {"filter", "flat"}},
+			},
+			expected: map[string][]string{"map": {"map"}, "flat": {"reduce", "filter", "flat"}},
+			roots:    []string{"map"},
+		},
+		{
+			name: "Salida compartida por dos consumidores no se fusiona",
+			dag: common.DAG{
+				Nodes: []common.OperationNode{
+					{ID: "src", Type: common.OpTypeMap},
+					{ID: "a", Type: common.OpTypeFilter},
+					{ID: "b", Type: common.OpTypeFilter},
+				},
+				Edges: [][]string{{"src", "a"}, {"src", "b"}},
+			},
+			expected: map[string][]string{"src": {"src"}, "a": {"a"}, "b": {"b"}},
+			roots:    []string{"src"},
+		},
+		{
+			name: "Fuentes de un JOIN se fusionan hasta el JOIN",
+			dag: common.DAG{
+				Nodes: []common.OperationNode{
+					{ID: "users", Type: common.OpTypeMap},
+					{ID: "clean", Type: common.OpTypeFilter},
+					{ID: "orders", Type: common.OpTypeMap},
+					{ID: "join", Type: common.OpTypeJoin},
+				},
+				Edges: [][]string{{"users", "clean"}, {"clean", "join"}, {"orders", "join"}},
+			},
+			expected: map[string][]string{"clean": {"users", "clean"}, "orders": {"orders"}, "join": {"join"}},
+			roots:    []string{"orders", "clean"}, // Orden topológico de las colas de stage
+		},
+		{
+			name: "JOIN con lado replicado se fusiona con el lado que se recorre",
+			dag: common.DAG{
+				Nodes: []common.OperationNode{
+					{ID: "users", Type: common.OpTypeMap},
+					{ID: "clean", Type: common.OpTypeFilter},
+					{ID: "orders", Type: common.OpTypeMap},
+					{ID: "join", Type: common.OpTypeJoin, Broadcast: common.BroadcastRight},
+					{ID: "fmt", Type: common.OpTypeMap},
+				},
+				Edges: [][]string{{"users", "clean"}, {"clean", "join"}, {"orders", "join"}, {"join", "fmt"}},
+			},
+			expected: map[string][]string{"fmt": {"users", "clean", "join", "fmt"}, "orders": {"orders"}},
+			roots:    []string{"orders"}, // El stage fusionado espera a su padre broadcast
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := dag.NewPlan(tt.dag)
+			if err != nil {
+				t.Fatalf("NewPlan falló: %v", err)
+			}
+			if got := stageShape(p); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Stages incorrectos.\nEsperado: %v\nObtenido: %v", tt.expected, got)
+			}
+			if got := p.Roots(); !reflect.DeepEqual(got, tt.roots) {
+				t.Errorf("Raíces incorrectas. Esperado %v, obtuvo %v", tt.roots, got)
+			}
+		})
+	}
+}
+
+func TestNewPlan_StageEdges(t *testing.T) {
+	p, err := dag.NewPlan(common.DAG{
+		Nodes: []common.OperationNode{
+			{ID: "users", Type: common.OpTypeMap},
+			{ID: "clean", Type: common.OpTypeFilter},
+			{ID: "orders", Type: common.OpTypeMap},
+			{ID: "join", Type: common.OpTypeJoin},
+		},
+		Edges: [][]string{{"users", "clean"}, {"clean", "join"}, {"orders", "join"}},
+	})
+	if err != nil {
+		t.Fatalf("NewPlan falló: %v", err)
+	}
+
+	if got := p.Parents("join"); !reflect.DeepEqual(got, []string{"clean", "orders"}) {
+		t.Errorf("Padres del stage JOIN incorrectos: %v", got)
+	}
+	if got := p.Children("clean"); !reflect.DeepEqual(got, []string{"join"}) {
+		t.Errorf("Hijos del stage 'clean' incorrectos: %v", got)
+	}
+	if got := p.StageOf("users"); got != "clean" {
+		t.Errorf("El nodo 'users' debía pertenecer al stage 'clean', obtuvo %s", got)
+	}
+
+	done := map[string]bool{"clean": true}
+	if ready := p.ReadyChildren("clean", func(id string) bool { return done[id] }); len(ready) != 0 {
+		t.Errorf("JOIN listo con un padre pendiente: %v", ready)
+	}
+}
+
+func TestNewPlan_BroadcastParents(t *testing.T) {
+	p, err := dag.NewPlan(common.DAG{
+		Nodes: []common.OperationNode{
+			{ID: "users", Type: common.OpTypeMap},
+			{ID: "orders", Type: common.OpTypeMap},
+			{ID: "audit", Type: common.OpTypeFilter},
+			{ID: "join", Type: common.OpTypeJoin, Broadcast: common.BroadcastLeft},
+		},
+		Edges: [][]string{{"users", "join"}, {"orders", "join"}, {"orders", "audit"}},
+	})
+	if err != nil {
+		t.Fatalf("NewPlan falló: %v", err)
+	}
+
+	// orders tiene otro consumidor: el JOIN no puede fusionarse y lee ambos lados por shuffle
+	if got := p.Parents("join"); !reflect.DeepEqual(got, []string{"users", "orders"}) {
+		t.Errorf("Padres del JOIN incorrectos: %v", got)
+	}
+	if got := p.BroadcastParents("join"); len(got) != 0 {
+		t.Errorf("Un JOIN sin fusionar no tiene padres broadcast: %v", got)
+	}
+
+	p, err = dag.NewPlan(common.DAG{
+		Nodes: []common.OperationNode{
+			{ID: "users", Type: common.OpTypeMap},
+			{ID: "orders", Type: common.OpTypeMap},
+			{ID: "join", Type: common.OpTypeJoin, Broadcast: common.BroadcastLeft},
+		},
+		Edges: [][]string{{"users", "join"}, {"orders", "join"}},
+	})
+	if err != nil {
+		t.Fatalf("NewPlan falló: %v", err)
+	}
+	if got := p.Dependencies("join"); !reflect.DeepEqual(got, []string{"users"}) {
+		t.Errorf("El stage fusionado debía depender solo de users: %v", got)
+	}
+	if got := p.Children("users"); !reflect.DeepEqual(got, []string{"join"}) {
+		t.Errorf("Hijos de users incorrectos: %v", got)
+	}
+	done := map[string]bool{}
+	if ready := p.ReadyChildren("users", func(id string) bool { return done[id] }); len(ready) != 0 {
+		t.Errorf("Stage listo con su padre broadcast pendiente: %v", ready)
+	}
+	done["users"] = true
+	if ready := p.ReadyChildren("users", func(id string) bool { return done[id] }); !reflect.DeepEqual(ready, []string{"join"}) {
+		t.Errorf("El stage fusionado debía quedar listo: %v", ready)
+	}
+}
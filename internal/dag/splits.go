@@ -0,0 +1,136 @@
+package dag
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"mini-spark/internal/common"
+)
+
+// ByteRangeSplits divide un archivo de size bytes en n rangos contiguos [start, end).
+// Los rangos no respetan líneas: el lector del worker los alinea (ver worker.openSplit).
+// Si sobran tareas, los rangos vacíos quedan al final: el primero nunca es {0,0} (salvo archivo vacío),
+// valor que TaskInput reserva para "sin rango".
+func ByteRangeSplits(size int64, n int) [][2]int64 {
+	if n <= 0 {
+		n = 1
+	}
+	chunk := (size + int64(n) - 1) / int64(n)
+	splits := make([][2]int64, n)
+	for i := 0; i < n; i++ {
+		start := min(size, chunk*int64(i))
+		end := min(size, start+chunk)
+		splits[i] = [2]int64{start, end}
+	}
+	return splits
+}
+
+// InputFile es un archivo de entrada ya resuelto, con su tamaño.
+type InputFile struct {
+	Path string
+	Size int64
+}
+
+// IsGlob indica si la ruta contiene metacaracteres de patrón (*, ?, [).
+func IsGlob(path string) bool { return strings.ContainsAny(path, "*?[") }
+
+// ResolveInputFiles expande una lista de rutas (archivos, directorios o globs) a los archivos que contienen.
+// Los directorios se listan sin recursión y, como en Hadoop, se ignoran los archivos ocultos ("." o "_",
+// ej. _SUCCESS). Cada patrón debe aportar al menos un archivo; los duplicados se descartan.
+func ResolveInputFiles(patterns []string) ([]InputFile, error) {
+	var files []InputFile
+	seen := make(map[string]bool)
+	found := 0 // Archivos aportados por el patrón actual (incluidos duplicados)
+	add := func(path string, info os.FileInfo) {
+		found++
+		if seen[path] { return }
+		seen[path] = true
+		files = append(files, InputFile{Path: path, Size: info.Size()})
+	}
+
+	for _, pattern := range patterns {
+		matches := []string{pattern}
+		if IsGlob(pattern) {
+			var err error
+			if matches, err = filepath.Glob(pattern); err != nil {
+				return nil, fmt.Errorf("patrón inválido %q: %w", pattern, err)
+			}
+			sort.Strings(matches)
+		}
+
+		found = 0
+		for _, path := range matches {
+			info, err := os.Stat(path)
+			if err != nil {
+				return nil, err
+			}
+			if !info.IsDir() {
+				add(path, info)
+				continue
+			}
+			entries, err := os.ReadDir(path)
+			if err != nil {
+				return nil, err
+			}
+			for _, e := range entries { // ReadDir ya devuelve las entradas ordenadas por nombre
+				if e.IsDir() || strings.HasPrefix(e.Name(), ".") || strings.HasPrefix(e.Name(), "_") { continue }
+				info, err := e.Info()
+				if err != nil {
+					return nil, err
+				}
+				add(filepath.Join(path, e.Name()), info)
+			}
+		}
+		if found == 0 {
+			return nil, fmt.Errorf("la entrada %q no contiene archivos", pattern)
+		}
+	}
+	return files, nil
+}
+
+// PackSplits reparte los bytes de todos los archivos entre n tareas de forma equilibrada.
+// Los archivos se recorren como si estuvieran concatenados y la tarea i recibe el tramo
+// [i*chunk, (i+1)*chunk) de esa secuencia, expresado como rangos de cada archivo. Así un archivo
+// grande se parte entre varias tareas y muchos archivos pequeños se agrupan en una sola.
+// Un archivo no divisible (comprimido) va entero a la tarea en cuyo tramo empieza.
+// Las tareas sin datos reciben una lista vacía (no nil).
+func PackSplits(files []InputFile, n int) [][]common.FileSplit {
+	if n <= 0 {
+		n = 1
+	}
+	var total int64
+	for _, f := range files {
+		total += f.Size
+	}
+	ranges := ByteRangeSplits(total, n)
+
+	tasks := make([][]common.FileSplit, n)
+	for i := range tasks {
+		tasks[i] = []common.FileSplit{}
+	}
+
+	var base int64 // Offset global del inicio del archivo actual
+	for _, f := range files {
+		if !common.IsSplittable(f.Path) {
+			if f.Size > 0 {
+				i := 0
+				for i < n-1 && ranges[i][1] <= base { i++ }
+				tasks[i] = append(tasks[i], common.FileSplit{Path: f.Path, Offsets: [2]int64{0, f.Size}})
+			}
+			base += f.Size
+			continue
+		}
+		for i, r := range ranges {
+			start := max(r[0], base) - base
+			end := min(r[1], base+f.Size) - base
+			if start < end {
+				tasks[i] = append(tasks[i], common.FileSplit{Path: f.Path, Offsets: [2]int64{start, end}})
+			}
+		}
+		base += f.Size
+	}
+	return tasks
+}
@@ -0,0 +1,137 @@
+package dag_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"mini-spark/internal/common"
+	"mini-spark/internal/dag"
+)
+
+func TestByteRangeSplits_CoverFile(t *testing.T) {
+	tests := []struct {
+		name string
+		size int64
+		n    int
+	}{
+		{"Divisible", 100, 4},
+		{"Con resto", 103, 4},
+		{"Más tareas que bytes", 3, 5},
+		{"Una tarea", 50, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			splits := dag.ByteRangeSplits(tt.size, tt.n)
+			if len(splits) != tt.n {
+				t.Fatalf("Se esperaban %d rangos, obtuvo %d", tt.n, len(splits))
+			}
+			if splits[0] == [2]int64{0, 0} {
+				t.Errorf("El primer rango no puede ser {0,0} (reservado para 'sin rango')")
+			}
+			// Contiguos y cubriendo [0, size)
+			var next int64
+			for i, s := range splits {
+				if s[0] != next || s[1] < s[0] {
+					t.Fatalf("Rango %d inválido %v (se esperaba inicio %d)", i, s, next)
+				}
+				next = s[1]
+			}
+			if next != tt.size {
+				t.Errorf("Los rangos cubren hasta %d, el archivo mide %d", next, tt.size)
+			}
+		})
+	}
+}
+
+func TestResolveInputFiles(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	a := write("logs/app-01.log", "a\n")
+	b := write("logs/app-02.log", "bb\n")
+	write("logs/_SUCCESS", "")
+	write("logs/.crc", "x")
+	other := write("extra/other.txt", "ccc\n")
+
+	paths := func(files []dag.InputFile) []string {
+		var out []string
+		for _, f := range files {
+			out = append(out, f.Path)
+		}
+		return out
+	}
+
+	tests := []struct {
+		name     string
+		patterns []string
+		expected []string
+	}{
+		{"Directorio ignora ocultos", []string{filepath.Join(dir, "logs")}, []string{a, b}},
+		{"Glob", []string{filepath.Join(dir, "logs", "app-*.log")}, []string{a, b}},
+		{"Lista sin duplicados", []string{b, filepath.Join(dir, "logs"), other}, []string{b, a, other}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files, err := dag.ResolveInputFiles(tt.patterns)
+			if err != nil {
+				t.Fatalf("ResolveInputFiles falló: %v", err)
+			}
+			if got := paths(files); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Archivos incorrectos.\nEsperado: %v\nObtenido: %v", tt.expected, got)
+			}
+		})
+	}
+
+	os.MkdirAll(filepath.Join(dir, "vacio"), 0755)
+	for _, bad := range []string{filepath.Join(dir, "logs", "*.gz"), filepath.Join(dir, "vacio"), filepath.Join(dir, "no-existe.txt")} {
+		if _, err := dag.ResolveInputFiles([]string{bad}); err == nil {
+			t.Errorf("Se esperaba error para la entrada %s", bad)
+		}
+	}
+}
+
+func TestPackSplits(t *testing.T) {
+	files := []dag.InputFile{{Path: "big", Size: 100}, {Path: "s1", Size: 10}, {Path: "s2", Size: 10}, {Path: "empty", Size: 0}}
+	tasks := dag.PackSplits(files, 3) // 120 bytes -> 40 por tarea
+
+	expected := [][]common.FileSplit{
+		{{Path: "big", Offsets: [2]int64{0, 40}}},
+		{{Path: "big", Offsets: [2]int64{40, 80}}},
+		{{Path: "big", Offsets: [2]int64{80, 100}}, {Path: "s1", Offsets: [2]int64{0, 10}}, {Path: "s2", Offsets: [2]int64{0, 10}}},
+	}
+	if !reflect.DeepEqual(tasks, expected) {
+		t.Errorf("Reparto incorrecto.\nEsperado: %v\nObtenido: %v", expected, tasks)
+	}
+
+	// Más tareas que bytes: las sobrantes quedan vacías pero no nil (el worker no debe leer nada)
+	tasks = dag.PackSplits([]dag.InputFile{{Path: "tiny", Size: 2}}, 4)
+	for i, splits := range tasks[2:] {
+		if splits == nil || len(splits) != 0 {
+			t.Errorf("Tarea %d debería tener una lista vacía, obtuvo %v", i+2, splits)
+		}
+	}
+}
+
+func TestPackSplits_CompressedFilesAreWhole(t *testing.T) {
+	files := []dag.InputFile{{Path: "a.log", Size: 30}, {Path: "b.log.gz", Size: 60}, {Path: "c.log.bz2", Size: 30}}
+	tasks := dag.PackSplits(files, 3) // 120 bytes -> 40 por tarea
+
+	// b.log.gz empieza en el byte 30 (tramo de la tarea 0) y va entero a ella, aunque cruce su tramo
+	expected := [][]common.FileSplit{
+		{{Path: "a.log", Offsets: [2]int64{0, 30}}, {Path: "b.log.gz", Offsets: [2]int64{0, 60}}},
+		{},
+		{{Path: "c.log.bz2", Offsets: [2]int64{0, 30}}},
+	}
+	if !reflect.DeepEqual(tasks, expected) {
+		t.Errorf("Reparto incorrecto.\nEsperado: %v\nObtenido: %v", expected, tasks)
+	}
+}
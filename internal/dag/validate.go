@@ -0,0 +1,228 @@
+package dag
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"mini-spark/internal/common"
+	"mini-spark/internal/udf"
+)
+
+// ValidationError describe un problema concreto de la especificación de un Job.
+type ValidationError struct {
+	NodeID  string `json:"node_id,omitempty"` // Nodo afectado (vacío si es un error del Job o de una arista)
+	Field   string `json:"field"`             // Campo del spec (ej. "udf_name", "edges")
+	Message string `json:"message"`
+}
+
+func (e ValidationError) Error() string {
+	if e.NodeID == "" {
+		return fmt.Sprintf("%s: %s", e.Field, e.Message)
+	}
+	return fmt.Sprintf("nodo %s, %s: %s", e.NodeID, e.Field, e.Message)
+}
+
+// udfKinds indica qué tipos de UDF acepta cada operación
+var udfKinds = map[string]func(interface{}) bool{
+	common.OpTypeMap:         func(fn interface{}) bool { _, ok := fn.(udf.UDFMapFn); return ok },
+	common.OpTypeFlatMap:     func(fn interface{}) bool { _, ok := fn.(udf.UDFFlatMapFn); return ok },
+	common.OpTypeFilter:      func(fn interface{}) bool { _, ok := fn.(udf.UDFFilterFn); return ok },
+	common.OpTypeReduceByKey: func(fn interface{}) bool { _, ok := fn.(udf.UDFReduceFn); return ok },
+	common.OpTypeJoin: func(fn interface{}) bool {
+		switch fn.(type) {
+		case udf.UDFJoinFn, udf.UDFJoinPairFn:
+			return true
+		}
+		return false
+	},
+	common.OpTypeLeftOuterJoin:  isJoinPairFn,
+	common.OpTypeRightOuterJoin: isJoinPairFn,
+	common.OpTypeFullOuterJoin:  isJoinPairFn,
+	common.OpTypeSortByKey:      nil, // Sin UDF: ordena los pares clave-valor de su padre
+}
+
+func isJoinPairFn(fn interface{}) bool { _, ok := fn.(udf.UDFJoinPairFn); return ok }
+
+var inputFormats = map[string]bool{
+	"":                      true, // TEXT por defecto
+	common.InputFormatText:  true,
+	common.InputFormatCSV:   true,
+	common.InputFormatJSONL: true,
+}
+
+// ValidateJob revisa la especificación completa de un Job antes de planificarlo y devuelve
+// todos los problemas encontrados (lista vacía si el Job es válido).
+// Se asume que los defaults del Job (ej. NumPartitions global) ya fueron aplicados.
+func ValidateJob(job common.JobRequest) []ValidationError {
+	var errs []ValidationError
+	add := func(nodeID, field, format string, args ...interface{}) {
+		errs = append(errs, ValidationError{NodeID: nodeID, Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if job.JobID != "" && !common.IsSafeJobID(job.JobID) {
+		add("", "job_id", "el id del Job no puede contener separadores de ruta ni ser \".\" o \"..\": %q", job.JobID)
+	}
+	if job.NumPartitions <= 0 {
+		add("", "partitions", "el número de particiones del Job debe ser mayor a cero (obtuvo %d)", job.NumPartitions)
+	}
+	if strings.ContainsAny(job.Pool, ",=") {
+		add("", "pool", "el nombre del pool no puede contener ',' ni '=': %q", job.Pool)
+	}
+	if spec := job.Adaptive; spec != nil {
+		if spec.TargetPartitionBytes <= 0 {
+			add("", "adaptive.target_partition_bytes", "el tamaño objetivo por tarea debe ser mayor a cero (obtuvo %d)", spec.TargetPartitionBytes)
+		}
+		if spec.MinPartitions < 0 {
+			add("", "adaptive.min_partitions", "el mínimo de tareas no puede ser negativo (obtuvo %d)", spec.MinPartitions)
+		}
+		if spec.MaxPartitionBytes != 0 && spec.MaxPartitionBytes < spec.TargetPartitionBytes {
+			add("", "adaptive.max_partition_bytes", "debe ser 0 (no dividir) o al menos target_partition_bytes (obtuvo %d)", spec.MaxPartitionBytes)
+		}
+		if spec.HotKeyBytes < 0 {
+			add("", "adaptive.hot_key_bytes", "el umbral de clave caliente no puede ser negativo (obtuvo %d)", spec.HotKeyBytes)
+		}
+	}
+	if job.BroadcastJoinBytes < 0 {
+		add("", "broadcast_join_bytes", "el umbral de BROADCAST_JOIN no puede ser negativo (obtuvo %d)", job.BroadcastJoinBytes)
+	}
+	if job.TaskTimeoutSec < 0 {
+		add("", "task_timeout_sec", "el timeout por tarea no puede ser negativo (obtuvo %d)", job.TaskTimeoutSec)
+	}
+	for _, pattern := range append([]string{job.InputPath}, job.InputPaths...) {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			add("", "path", "patrón glob inválido: %q", pattern)
+		}
+	}
+	if len(job.DAG.Nodes) == 0 {
+		add("", "dag.nodes", "el DAG no tiene nodos")
+		return errs
+	}
+
+	// 1. Nodos: IDs, tipos, UDFs y particiones
+	ids := make(map[string]bool)
+	for i, node := range job.DAG.Nodes {
+		if node.ID == "" {
+			add("", "dag.nodes", "el nodo en la posición %d no tiene id", i)
+			continue
+		}
+		if ids[node.ID] {
+			add(node.ID, "id", "id de nodo duplicado")
+		}
+		ids[node.ID] = true
+
+		if node.NumPartitions < 0 {
+			add(node.ID, "partitions", "el número de particiones no puede ser negativo (obtuvo %d)", node.NumPartitions)
+		}
+
+		acceptsKind, known := udfKinds[node.Type]
+		if !known {
+			add(node.ID, "op_type", "tipo de operación desconocido: %q", node.Type)
+		} else if acceptsKind == nil {
+			if node.UDFName != "" { add(node.ID, "udf_name", "%s no usa UDF (obtuvo %q)", node.Type, node.UDFName) }
+		} else if fn, exists := udf.UDFRegistry[node.UDFName]; !exists {
+			add(node.ID, "udf_name", "la UDF %q no está registrada", node.UDFName)
+		} else if !acceptsKind(fn) {
+			add(node.ID, "udf_name", "la UDF %q (%T) no es compatible con %s", node.UDFName, fn, node.Type)
+		}
+
+		if node.CombineUDF != "" {
+			if node.Type != common.OpTypeReduceByKey {
+				add(node.ID, "combine_udf", "solo aplica a %s", common.OpTypeReduceByKey)
+			} else if _, ok := udf.UDFRegistry[node.CombineUDF].(udf.UDFReduceFn); !ok {
+				add(node.ID, "combine_udf", "la UDF %q no es una UDF de REDUCE registrada", node.CombineUDF)
+			}
+		}
+
+		if node.Order != "" {
+			if node.Type != common.OpTypeSortByKey {
+				add(node.ID, "order", "solo aplica a %s", common.OpTypeSortByKey)
+			} else if node.Order != common.SortAscending && node.Order != common.SortDescending {
+				add(node.ID, "order", "debe ser %s o %s (obtuvo %q)", common.SortAscending, common.SortDescending, node.Order)
+			}
+		}
+
+		if node.Broadcast != "" {
+			if !common.IsJoinOp(node.Type) {
+				add(node.ID, "broadcast", "solo aplica a los JOIN")
+			} else if node.Broadcast != common.BroadcastLeft && node.Broadcast != common.BroadcastRight {
+				add(node.ID, "broadcast", "debe ser %s o %s (obtuvo %q)", common.BroadcastLeft, common.BroadcastRight, node.Broadcast)
+			} else if !common.CanBroadcast(node.Type, node.Broadcast) {
+				add(node.ID, "broadcast", "%s no puede replicar el lado %s: conserva sus registros sin pareja", node.Type, node.Broadcast)
+			}
+		}
+
+		if node.Input != nil {
+			if len(node.Input.Patterns()) == 0 {
+				add(node.ID, "input.path", "la entrada declarada no tiene ruta")
+			}
+			for _, pattern := range node.Input.Patterns() {
+				if _, err := filepath.Match(pattern, ""); err != nil {
+					add(node.ID, "input.path", "patrón glob inválido: %q", pattern)
+				}
+			}
+			if !inputFormats[node.Input.Format] {
+				add(node.ID, "input.format", "formato de entrada desconocido: %q", node.Input.Format)
+			}
+		}
+	}
+
+	// 2. Aristas y dependencias hacia nodos inexistentes
+	structural := len(errs)
+	for _, edge := range job.DAG.Edges {
+		if len(edge) != 2 {
+			add("", "edges", "arista mal formada %v (se esperaba [from, to])", edge)
+			continue
+		}
+		for _, end := range edge {
+			if !ids[end] {
+				add("", "edges", "la arista %s -> %s referencia un nodo inexistente: %s", edge[0], edge[1], end)
+			}
+		}
+	}
+	for _, node := range job.DAG.Nodes {
+		for _, dep := range node.Dependencies {
+			if !ids[dep] {
+				add(node.ID, "dependencies", "depende de un nodo inexistente: %s", dep)
+			}
+		}
+	}
+	if len(errs) > structural {
+		return errs // Sin aristas válidas no tiene sentido analizar la topología
+	}
+
+	// 3. Topología: ciclos, fuentes con entrada y aridad de los JOIN
+	g, err := NewGraph(job.DAG)
+	if err != nil {
+		add("", "edges", "%v", err)
+		return errs
+	}
+	for _, id := range g.TopologicalOrder() {
+		node, _ := g.Node(id)
+		parents := g.Parents(id)
+
+		if len(parents) == 0 {
+			if _, known := udfKinds[node.Type]; known && !common.IsNarrowOp(node.Type) {
+				add(id, "op_type", "%s no puede ser un nodo fuente: necesita la salida de otro nodo", node.Type)
+			}
+			if node.Input == nil && job.InputPath == "" && len(job.InputPaths) == 0 {
+				add(id, "input", "nodo fuente sin entrada: declare \"input\" o el \"path\" global del Job")
+			}
+		} else if node.Input != nil {
+			add(id, "input", "solo los nodos fuente pueden declarar una entrada propia")
+		}
+
+		if common.IsJoinOp(node.Type) && len(parents) != 2 {
+			add(id, "dependencies", "%s necesita exactamente 2 padres (izquierdo y derecho), tiene %d", node.Type, len(parents))
+		}
+		// El padre de un SORT_BY_KEY escribe un único bloque ordenado: no sirve a otros consumidores
+		if node.Type == common.OpTypeSortByKey {
+			if len(parents) != 1 {
+				add(id, "dependencies", "%s necesita exactamente 1 padre, tiene %d", node.Type, len(parents))
+			} else if n := len(g.Children(parents[0])); n > 1 {
+				add(id, "dependencies", "el padre %s alimenta a %d nodos: %s necesita su salida en exclusiva", parents[0], n, node.Type)
+			}
+		}
+	}
+	return errs
+}
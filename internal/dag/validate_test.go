@@ -0,0 +1,162 @@
+package dag_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"mini-spark/internal/common"
+	"mini-spark/internal/dag"
+)
+
+// validJob devuelve un Job correcto (dos fuentes -> JOIN) que cada caso de prueba rompe de una forma distinta
+func validJob() common.JobRequest {
+	return common.JobRequest{
+		JobID:         "job-validate",
+		InputPath:     "/data/default.txt",
+		NumPartitions: 2,
+		DAG: common.DAG{
+			Nodes: []common.OperationNode{
+				{ID: "users", Type: common.OpTypeMap, UDFName: "map_parse_users", NumPartitions: 2},
+				{ID: "orders", Type: common.OpTypeMap, UDFName: "map_parse_orders", NumPartitions: 2},
+				{ID: "join", Type: common.OpTypeJoin, UDFName: "join_users_orders", NumPartitions: 2},
+			},
+			Edges: [][]string{{"users", "join"}, {"orders", "join"}},
+		},
+	}
+}
+
+func TestValidateJob(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(j *common.JobRequest)
+		field  string // Campo esperado en el error ("" = job válido)
+		nodeID string
+	}{
+		{name: "Job válido", mutate: func(j *common.JobRequest) {}},
+		{name: "Sin nodos", mutate: func(j *common.JobRequest) { j.DAG = common.DAG{} }, field: "dag.nodes"},
+		{name: "Tipo desconocido", mutate: func(j *common.JobRequest) { j.DAG.Nodes[0].Type = "MAPP" }, field: "op_type", nodeID: "users"},
+		{name: "UDF no registrada", mutate: func(j *common.JobRequest) { j.DAG.Nodes[1].UDFName = "map_typo" }, field: "udf_name", nodeID: "orders"},
+		{name: "UDF de otro tipo", mutate: func(j *common.JobRequest) { j.DAG.Nodes[0].UDFName = "reduce_sum" }, field: "udf_name", nodeID: "users"},
+		{name: "Outer join sin UDF por pares", mutate: func(j *common.JobRequest) { j.DAG.Nodes[2].Type = common.OpTypeLeftOuterJoin }, field: "udf_name", nodeID: "join"},
+		{name: "Particiones negativas", mutate: func(j *common.JobRequest) { j.DAG.Nodes[2].NumPartitions = -1 }, field: "partitions", nodeID: "join"},
+		{name: "Particiones globales en cero", mutate: func(j *common.JobRequest) { j.NumPartitions = 0 }, field: "partitions"},
+		{name: "Arista colgante", mutate: func(j *common.JobRequest) { j.DAG.Edges = append(j.DAG.Edges, []string{"join", "fantasma"}) }, field: "edges"},
+		{name: "Dependencia colgante", mutate: func(j *common.JobRequest) { j.DAG.Nodes[2].Dependencies = []string{"fantasma"} }, field: "dependencies", nodeID: "join"},
+		{name: "Ciclo", mutate: func(j *common.JobRequest) { j.DAG.Edges = append(j.DAG.Edges, []string{"join", "users"}) }, field: "edges"},
+		{name: "JOIN con un solo padre", mutate: func(j *common.JobRequest) { j.DAG.Edges = j.DAG.Edges[:1] }, field: "dependencies", nodeID: "join"},
+		{name: "REDUCE como fuente", mutate: func(j *common.JobRequest) {
+			j.DAG.Nodes = append(j.DAG.Nodes, common.OperationNode{ID: "lonely", Type: common.OpTypeReduceByKey, UDFName: "reduce_sum"})
+		}, field: "op_type", nodeID: "lonely"},
+		{name: "Fuente sin entrada", mutate: func(j *common.JobRequest) { j.InputPath = "" }, field: "input", nodeID: "users"},
+		{name: "Formato desconocido", mutate: func(j *common.JobRequest) {
+			j.DAG.Nodes[0].Input = &common.InputSpec{Path: "/data/u.csv", Format: "XML"}
+		}, field: "input.format", nodeID: "users"},
+		{name: "Lista de entradas", mutate: func(j *common.JobRequest) {
+			j.DAG.Nodes[0].Input = &common.InputSpec{Paths: []string{"/data/logs/", "/data/extra/*.log"}}
+		}},
+		{name: "Glob inválido", mutate: func(j *common.JobRequest) {
+			j.DAG.Nodes[0].Input = &common.InputSpec{Path: "/data/logs/[a-"}
+		}, field: "input.path", nodeID: "users"},
+		{name: "Job ID con ruta", mutate: func(j *common.JobRequest) {
+			j.JobID = "../outputs"
+		}, field: "job_id"},
+		{name: "Timeout negativo", mutate: func(j *common.JobRequest) {
+			j.TaskTimeoutSec = -5
+		}, field: "task_timeout_sec"},
+		{name: "Pool con separador", mutate: func(j *common.JobRequest) {
+			j.Pool = "batch=2"
+		}, field: "pool"},
+		{name: "Adaptive sin objetivo", mutate: func(j *common.JobRequest) {
+			j.Adaptive = &common.AdaptiveSpec{MinPartitions: 2}
+		}, field: "adaptive.target_partition_bytes"},
+		{name: "Combine UDF en un JOIN", mutate: func(j *common.JobRequest) {
+			j.DAG.Nodes[2].CombineUDF = "reduce_add"
+		}, field: "combine_udf", nodeID: "join"},
+		{name: "Adaptive con máximo menor al objetivo", mutate: func(j *common.JobRequest) {
+			j.Adaptive = &common.AdaptiveSpec{TargetPartitionBytes: 1 << 20, MaxPartitionBytes: 1024}
+		}, field: "adaptive.max_partition_bytes"},
+		{name: "Broadcast en un JOIN", mutate: func(j *common.JobRequest) { j.DAG.Nodes[2].Broadcast = common.BroadcastRight }},
+		{name: "Broadcast del lado conservado", mutate: func(j *common.JobRequest) {
+			j.DAG.Nodes[2].Type = common.OpTypeLeftOuterJoin
+			j.DAG.Nodes[2].UDFName = "join_users_orders_pair"
+			j.DAG.Nodes[2].Broadcast = common.BroadcastLeft
+		}, field: "broadcast", nodeID: "join"},
+		{name: "Sort por clave", mutate: func(j *common.JobRequest) {
+			j.DAG.Nodes = append(j.DAG.Nodes, common.OperationNode{ID: "sorted", Type: common.OpTypeSortByKey, Order: common.SortDescending})
+			j.DAG.Edges = append(j.DAG.Edges, []string{"join", "sorted"})
+		}},
+		{name: "Sort con UDF", mutate: func(j *common.JobRequest) {
+			j.DAG.Nodes = append(j.DAG.Nodes, common.OperationNode{ID: "sorted", Type: common.OpTypeSortByKey, UDFName: "reduce_sum"})
+			j.DAG.Edges = append(j.DAG.Edges, []string{"join", "sorted"})
+		}, field: "udf_name", nodeID: "sorted"},
+		{name: "Sort con padre compartido", mutate: func(j *common.JobRequest) {
+			j.DAG.Nodes = append(j.DAG.Nodes, common.OperationNode{ID: "sorted", Type: common.OpTypeSortByKey})
+			j.DAG.Edges = append(j.DAG.Edges, []string{"users", "sorted"})
+		}, field: "dependencies", nodeID: "sorted"},
+		{name: "Orden desconocido", mutate: func(j *common.JobRequest) { j.DAG.Nodes[2].Order = common.SortDescending }, field: "order", nodeID: "join"},
+		{name: "Broadcast fuera de un JOIN", mutate: func(j *common.JobRequest) { j.DAG.Nodes[0].Broadcast = common.BroadcastLeft }, field: "broadcast", nodeID: "users"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			job := validJob()
+			tt.mutate(&job)
+			errs := dag.ValidateJob(job)
+
+			if tt.field == "" {
+				if len(errs) != 0 {
+					t.Fatalf("Job válido rechazado: %v", errs)
+				}
+				return
+			}
+			for _, e := range errs {
+				if e.Field == tt.field && e.NodeID == tt.nodeID {
+					return
+				}
+			}
+			t.Errorf("Se esperaba un error en %q (nodo %q), obtuvo: %v", tt.field, tt.nodeID, errs)
+		})
+	}
+}
+
+func TestValidateJob_ReportsAllErrors(t *testing.T) {
+	job := validJob()
+	job.DAG.Nodes[0].UDFName = "no_existe"
+	job.DAG.Nodes[1].Type = "DESCONOCIDO"
+	job.DAG.Nodes[2].NumPartitions = -3
+
+	if errs := dag.ValidateJob(job); len(errs) != 3 {
+		t.Errorf("Se esperaban los 3 errores juntos, obtuvo %d: %v", len(errs), errs)
+	}
+}
+
+func TestValidateJob_BundledSpecs(t *testing.T) {
+	// Los specs de demostración deben pasar siempre la validación del Master
+	specs, _ := filepath.Glob("../../jobs_specs/*.json")
+	if len(specs) == 0 {
+		t.Skip("No se encontraron specs en jobs_specs/")
+	}
+	for _, path := range specs {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("No se pudo leer %s: %v", path, err)
+		}
+		var job common.JobRequest
+		if err := json.Unmarshal(data, &job); err != nil {
+			t.Fatalf("%s no es un JobRequest válido: %v", path, err)
+		}
+		if job.NumPartitions == 0 {
+			job.NumPartitions = 2 // Default aplicado por la API
+		}
+		if errs := dag.ValidateJob(job); len(errs) > 0 {
+			var msgs []string
+			for _, e := range errs {
+				msgs = append(msgs, e.Error())
+			}
+			t.Errorf("%s inválido:\n  %s", filepath.Base(path), strings.Join(msgs, "\n  "))
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package master
+
+import (
+	"log"
+	"sort"
+
+	"mini-spark/internal/common"
+	"mini-spark/internal/dag"
+)
+
+// ==========================================
+// PARTICIONES ADAPTATIVAS
+// ==========================================
+// Al lanzar un stage que lee shuffle se fija su layout: qué particiones de los padres lee cada tarea.
+// Sin JobRequest.Adaptive es una tarea por partición del spec. Con Adaptive se miran los tamaños
+// reportados (ShuffleMeta.Size): las particiones contiguas chicas se fusionan hasta el objetivo y una
+// partición demasiado grande, o con una clave caliente (ver skew.go), se reparte por bloques de map.
+// Un JOIN que replica un lado (ver broadcast.go) tiene una tarea por tarea de map del otro lado, y un
+// SORT_BY_KEY una por rango de claves (ver sort.go).
+// El layout queda en el Store: los recálculos por linaje y la recuperación tras un reinicio
+// replanifican exactamente las mismas tareas.
+
+// defaultLayout es una tarea por partición: la tarea i lee las particiones p con p % n == i
+func defaultLayout(n int) []common.ShuffleSlice {
+	layout := make([]common.ShuffleSlice, n)
+	for i := range layout { layout[i].Partitions = []int{i} }
+	return layout
+}
+
+// stageLayout devuelve el layout fijado de un stage o, si todavía no tiene, lo calcula con el shuffle
+// actual de sus padres y lo fija. Un stage fuente (sin entradas) no tiene layout.
+func (s *Scheduler) stageLayout(job *common.JobRequest, stage dag.Stage, inputs []common.TaskReport) []common.ShuffleSlice {
+	if inputs == nil { return nil }
+	if layout := s.Store.StageLayout(job.JobID, stage.ID); layout != nil { return layout }
+	layout := planLayout(job, stage, inputs)
+	s.Store.SetStageLayout(job.JobID, stage.ID, layout)
+	if n := stagePartitions(job, stage.Head()); len(layout) != n {
+		log.Printf("[Scheduler] Stage %s del Job %s: %d particiones → %d tareas según el tamaño del shuffle", stage.ID, job.JobID, n, len(layout))
+	}
+	return layout
+}
+
+// stageTaskCount devuelve cuántas tareas tiene un stage: las de su layout si ya se fijó
+func (s *Scheduler) stageTaskCount(job *common.JobRequest, stage dag.Stage) int {
+	if layout := s.Store.StageLayout(job.JobID, stage.ID); layout != nil { return len(layout) }
+	return stagePartitions(job, stage.Head())
+}
+
+// planLayout reparte las particiones del shuffle de los padres entre las tareas del stage
+func planLayout(job *common.JobRequest, stage dag.Stage, inputs []common.TaskReport) []common.ShuffleSlice {
+	head := stage.Head()
+	n := stagePartitions(job, head)
+	if head.Type == common.OpTypeSortByKey {
+		layout := sortLayout(n, head.Order, inputs)
+		log.Printf("[Scheduler] Stage %s del Job %s: SORT_BY_KEY en %d rangos de claves", stage.ID, job.JobID, len(layout))
+		return layout
+	}
+	if side := broadcastSide(job, head, inputs); side != "" {
+		replicated, streamed := joinStages(head, side)
+		log.Printf("[Scheduler] Stage %s del Job %s: BROADCAST_JOIN, se replica %s en cada tarea de %s", stage.ID, job.JobID, replicated, streamed)
+		return broadcastLayout(n, streamed, inputs)
+	}
+	spec := job.Adaptive
+	if spec == nil || spec.TargetPartitionBytes <= 0 { return defaultLayout(n) }
+
+	sizes := make([]int64, n)
+	for _, rep := range inputs {
+		for _, meta := range rep.ShuffleOutput { sizes[meta.PartitionKey%n] += meta.Size }
+	}
+	layout := coalesce(sizes, spec.TargetPartitionBytes, spec.MinPartitions)
+
+	var split []common.ShuffleSlice
+	for _, slice := range layout {
+		reason := skewReason(slice, n, inputs, spec)
+		pieces := []common.ShuffleSlice{slice}
+		if reason != "" { pieces = splitPartition(head, slice, n, inputs, spec.TargetPartitionBytes) }
+		if len(pieces) < 2 {
+			split = append(split, slice)
+			continue
+		}
+		log.Printf("[Scheduler] Stage %s del Job %s: particiones %v (%s) divididas en %d tareas", stage.ID, job.JobID, slice.Partitions, reason, len(pieces))
+		if head.Type == common.OpTypeReduceByKey {
+			// Cada parte reduce sus bloques; una tarea más combina los parciales de cada clave
+			merge := common.ShuffleSlice{Partitions: slice.Partitions}
+			for j := range pieces {
+				pieces[j].Partial = true
+				merge.MergeOf = append(merge.MergeOf, len(split)+j)
+			}
+			pieces = append(pieces, merge)
+		}
+		split = append(split, pieces...)
+	}
+	return split
+}
+
+// coalesce agrupa particiones contiguas mientras el grupo no supere target bytes (una partición
+// mayor queda sola). Nunca deja menos de minParts grupos, si hay particiones suficientes.
+func coalesce(sizes []int64, target int64, minParts int) []common.ShuffleSlice {
+	n := len(sizes)
+	minParts = min(max(minParts, 1), n)
+
+	var layout []common.ShuffleSlice
+	var cur []int
+	var curSize int64
+	for i := 0; i < n; i++ {
+		// Sumar i al grupo actual dejaría menos particiones que grupos faltan para llegar al mínimo
+		mustClose := n-i <= minParts-len(layout)-1
+		if len(cur) > 0 && (curSize+sizes[i] > target || mustClose) {
+			layout = append(layout, common.ShuffleSlice{Partitions: cur})
+			cur, curSize = nil, 0
+		}
+		cur = append(cur, i)
+		curSize += sizes[i]
+	}
+	return append(layout, common.ShuffleSlice{Partitions: cur})
+}
+
+// splittableSides devuelve los lados de un JOIN que se pueden repartir entre tareas sin cambiar el
+// resultado: un registro del lado repartido cae en una sola tarea y ve todas sus parejas del otro lado.
+// En un JOIN externo el lado que conserva sus registros sin pareja no puede ser el replicado.
+func splittableSides(node common.OperationNode) []string {
+	if len(node.Dependencies) < 2 || node.Dependencies[0] == node.Dependencies[1] { return nil }
+	left, right := node.Dependencies[0], node.Dependencies[1]
+	switch node.Type {
+	case common.OpTypeJoin:
+		return []string{left, right}
+	case common.OpTypeLeftOuterJoin:
+		return []string{left}
+	case common.OpTypeRightOuterJoin:
+		return []string{right}
+	}
+	return nil
+}
+
+// splitPartition reparte los bloques de map de una partición en tareas de ~target bytes (en orden
+// de TaskID). En un JOIN se reparte el lado repartible más grande y cada tarea lee completo el otro;
+// en un REDUCE_BY_KEY con combine_udf se reparten los bloques de todos los padres (las partes se
+// combinan después). Devuelve la partición sin cambios si no se puede dividir.
+func splitPartition(node common.OperationNode, slice common.ShuffleSlice, n int, inputs []common.TaskReport, target int64) []common.ShuffleSlice {
+	// Bytes de la partición por lado y por tarea de map
+	bySide := make(map[string]int64)
+	byTask := make(map[string]map[string]int64) // Lado -> TaskID -> bytes
+	for _, rep := range inputs {
+		if byTask[rep.StageID] == nil { byTask[rep.StageID] = make(map[string]int64) }
+		byTask[rep.StageID][rep.TaskID] += 0 // Las tareas sin datos en la partición también se asignan
+		for _, meta := range rep.ShuffleOutput {
+			if !slice.Includes(meta.PartitionKey % n) { continue }
+			bySide[rep.StageID] += meta.Size
+			byTask[rep.StageID][rep.TaskID] += meta.Size
+		}
+	}
+
+	source := ""
+	sizes := make(map[string]int64) // TaskID -> bytes de los bloques que se reparten
+	switch {
+	case common.IsJoinOp(node.Type):
+		for _, candidate := range splittableSides(node) {
+			if source == "" || bySide[candidate] > bySide[source] { source = candidate }
+		}
+		if source == "" { return []common.ShuffleSlice{slice} }
+		sizes = byTask[source]
+	case node.Type == common.OpTypeReduceByKey && node.CombineUDF != "":
+		for _, tasks := range byTask {
+			for id, size := range tasks { sizes[id] = size }
+		}
+	default:
+		return []common.ShuffleSlice{slice}
+	}
+	if len(sizes) < 2 { return []common.ShuffleSlice{slice} }
+
+	taskIDs := make([]string, 0, len(sizes))
+	for id := range sizes { taskIDs = append(taskIDs, id) }
+	sort.Strings(taskIDs)
+
+	var pieces []common.ShuffleSlice
+	var cur []string
+	var curSize int64
+	for _, id := range taskIDs {
+		if curSize > 0 && curSize+sizes[id] > target {
+			pieces = append(pieces, common.ShuffleSlice{Partitions: slice.Partitions, Source: source, MapTasks: cur})
+			cur, curSize = nil, 0
+		}
+		cur = append(cur, id)
+		curSize += sizes[id]
+	}
+	pieces = append(pieces, common.ShuffleSlice{Partitions: slice.Partitions, Source: source, MapTasks: cur})
+	if len(pieces) == 1 { return []common.ShuffleSlice{slice} }
+	return pieces
+}
@@ -0,0 +1,169 @@
+package master
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"mini-spark/internal/common"
+	"mini-spark/internal/dag"
+	"mini-spark/internal/storage"
+)
+
+// mapOutput arma el reporte de un MAP que escribió las particiones indicadas con esos tamaños
+func mapOutput(jobID, stageID string, i int, sizes map[int]int64) common.TaskReport {
+	rep := common.TaskReport{
+		TaskID: stageTaskID(jobID, stageID, i), JobID: jobID, StageID: stageID, Status: common.TaskStatusSuccess, WorkerID: fmt.Sprintf("w%d", i),
+	}
+	for p, size := range sizes {
+		rep.ShuffleOutput = append(rep.ShuffleOutput, common.ShuffleMeta{PartitionKey: p, Path: fmt.Sprintf("/tmp/%s_%d_p%d", stageID, i, p), Size: size})
+	}
+	return rep
+}
+
+func TestCoalesce(t *testing.T) {
+	groups := func(layout []common.ShuffleSlice) string {
+		var out []string
+		for _, slice := range layout { out = append(out, fmt.Sprint(slice.Partitions)) }
+		return strings.Join(out, " ")
+	}
+	cases := []struct {
+		name     string
+		sizes    []int64
+		target   int64
+		minParts int
+		want     string
+	}{
+		{"Fusiona las chicas contiguas", []int64{10, 10, 10, 10, 200, 0, 0, 0}, 100, 0, "[0 1 2 3] [4] [5 6 7]"},
+		{"Todas vacías en una tarea", []int64{0, 0, 0, 0}, 100, 0, "[0 1 2 3]"},
+		{"Respeta el objetivo", []int64{60, 60, 60}, 100, 0, "[0] [1] [2]"},
+		{"Respeta el mínimo de tareas", []int64{1, 1, 1, 1, 1, 1}, 100, 3, "[0 1 2 3] [4] [5]"},
+		{"Mínimo mayor a las particiones", []int64{1, 1}, 100, 5, "[0] [1]"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := groups(coalesce(tc.sizes, tc.target, tc.minParts)); got != tc.want {
+				t.Errorf("Se esperaba %s, obtuvo %s", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestScheduler_AdaptiveCoalescing(t *testing.T) {
+	store := storage.NewMemoryStore()
+	scheduler := NewScheduler(NewWorkerRegistry(), store)
+
+	job := createTestJob("job-aqe")
+	job.OutputPath = t.TempDir()
+	job.DAG.Nodes[1].NumPartitions = 8
+	job.Adaptive = &common.AdaptiveSpec{TargetPartitionBytes: 100}
+	store.CreateJob(&job)
+	scheduler.SubmitJob(&job)
+
+	scheduler.mu.Lock()
+	scheduler.PendingTasks = nil
+	scheduler.mu.Unlock()
+	// Entre los dos MAP: particiones 0-3 chicas, la 4 grande, 5-7 vacías
+	maps := []common.TaskReport{
+		mapOutput(job.JobID, "stage-map", 0, map[int]int64{0: 5, 1: 5, 2: 5, 3: 5, 4: 100}),
+		mapOutput(job.JobID, "stage-map", 1, map[int]int64{0: 5, 1: 5, 2: 5, 3: 5, 4: 100}),
+	}
+	for _, rep := range maps {
+		store.AddTaskReport(job.JobID, rep.StageID, rep)
+		scheduler.HandleTaskCompletion(rep)
+	}
+
+	scheduler.mu.Lock()
+	reduces := scheduler.PendingTasks
+	scheduler.PendingTasks = nil
+	scheduler.mu.Unlock()
+	if len(reduces) != 3 { t.Fatalf("Se esperaban 3 tareas REDUCE tras fusionar 8 particiones, obtuvo %d", len(reduces)) }
+	if n := len(reduces[0].InputPartition.ShuffleMap); n != 8 {
+		t.Errorf("La tarea fusionada debe leer las particiones 0-3 de los dos MAP (8 bloques), lee %d", n)
+	}
+	if n := len(reduces[2].InputPartition.ShuffleMap); n != 0 {
+		t.Errorf("La tarea de las particiones vacías no debe leer bloques, lee %d", n)
+	}
+
+	// Un MAP perdido se recalcula con otros tamaños: el stage conserva sus 3 tareas
+	lost := mapOutput(job.JobID, "stage-map", 0, map[int]int64{0: 500, 5: 500})
+	store.RemoveWorkerOutputs(job.JobID, "stage-map", "w0")
+	store.AddTaskReport(job.JobID, lost.StageID, lost)
+	scheduler.mu.Lock()
+	tasks, err := scheduler.replanTasks(&job, scheduler.Plans[job.JobID], mustStage(t, scheduler, job.JobID, "stage-reduce"), map[string]common.Task{reduces[2].TaskID: reduces[2]})
+	scheduler.mu.Unlock()
+	if err != nil || len(tasks) != 1 || tasks[0].PartitionIndex != 2 {
+		t.Fatalf("La replanificación debe usar el layout fijado: %v %+v", err, tasks)
+	}
+
+	// El stage se completa con 3 reportes, no con 8
+	for _, task := range reduces {
+		rep := common.TaskReport{TaskID: task.TaskID, JobID: job.JobID, StageID: task.StageID, Status: common.TaskStatusSuccess, WorkerID: "w1"}
+		store.AddTaskReport(job.JobID, rep.StageID, rep)
+		scheduler.HandleTaskCompletion(rep)
+	}
+	if !store.IsStageCompleted(job.JobID, "stage-reduce") {
+		t.Errorf("El stage REDUCE debía completarse con sus 3 tareas")
+	}
+}
+
+func mustStage(t *testing.T, s *Scheduler, jobID, stageID string) dag.Stage {
+	t.Helper()
+	stage, ok := s.Plans[jobID].Stage(stageID)
+	if !ok { t.Fatalf("No existe el stage %s", stageID) }
+	return stage
+}
+
+func TestPlanLayout_SplitsSkewedJoin(t *testing.T) {
+	joinJob := func(opType string) (*common.JobRequest, *dag.Plan) {
+		job := &common.JobRequest{
+			JobID: "job-skew", NumPartitions: 2,
+			Adaptive: &common.AdaptiveSpec{TargetPartitionBytes: 100, MaxPartitionBytes: 150},
+			DAG: common.DAG{
+				Nodes: []common.OperationNode{
+					{ID: "left", Type: common.OpTypeMap, UDFName: "map_wc", NumPartitions: 3},
+					{ID: "right", Type: common.OpTypeMap, UDFName: "map_wc", NumPartitions: 1},
+					{ID: "joined", Type: opType, UDFName: "join_pair", Dependencies: []string{"left", "right"}},
+				},
+				Edges: [][]string{{"left", "joined"}, {"right", "joined"}},
+			},
+		}
+		plan, err := dag.NewPlan(job.DAG)
+		if err != nil { t.Fatalf("Plan inválido: %v", err) }
+		return job, plan
+	}
+	// La partición 0 concentra 300 bytes del lado izquierdo (tres MAP) y 10 del derecho
+	inputs := []common.TaskReport{
+		mapOutput("job-skew", "left", 0, map[int]int64{0: 100, 1: 5}),
+		mapOutput("job-skew", "left", 1, map[int]int64{0: 100}),
+		mapOutput("job-skew", "left", 2, map[int]int64{0: 100, 1: 5}),
+		mapOutput("job-skew", "right", 0, map[int]int64{0: 10, 1: 10}),
+	}
+
+	job, plan := joinJob(common.OpTypeJoin)
+	stage, _ := plan.Stage("joined")
+	layout := planLayout(job, stage, inputs)
+	if len(layout) != 4 { t.Fatalf("Se esperaban 3 tareas para la partición sesgada y 1 para la otra, obtuvo %+v", layout) }
+	tasks, err := stageTasks(job, plan, stage, inputs, layout)
+	if err != nil { t.Fatalf("Error planificando: %v", err) }
+	for _, task := range tasks[:3] {
+		left, right := 0, 0
+		for _, url := range task.InputPartition.ShuffleMap {
+			if strings.Contains(url, "left_") { left++ }
+			if strings.Contains(url, "right_0_p0") { right++ }
+		}
+		if left != 1 || right != 1 {
+			t.Errorf("%s debe leer un bloque del lado izquierdo y todo el derecho: %v", task.TaskID, task.InputPartition.ShuffleMap)
+		}
+	}
+	if n := len(tasks[3].InputPartition.ShuffleMap); n != 3 {
+		t.Errorf("La partición 1 no se divide: debe leer los 3 bloques, lee %d", n)
+	}
+
+	// En un RIGHT OUTER JOIN el lado izquierdo debe verse completo: no se divide
+	job, plan = joinJob(common.OpTypeRightOuterJoin)
+	stage, _ = plan.Stage("joined")
+	if layout := planLayout(job, stage, inputs); len(layout) != 2 {
+		t.Errorf("RIGHT OUTER JOIN no puede repartir el lado izquierdo: %+v", layout)
+	}
+}
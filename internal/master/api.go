@@ -2,10 +2,14 @@ package master
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"os"
+	"path/filepath"
 	"strings"
 	"mini-spark/internal/common"
+	"mini-spark/internal/dag"
 	"mini-spark/internal/storage"
 	"github.com/google/uuid"
 )
@@ -13,7 +17,7 @@ import (
 type MasterServer struct {
 	Scheduler *Scheduler
 	Registry  *WorkerRegistry
-	Store     *storage.JobStore
+	Store     storage.JobStore
 }
 
 // POST /api/v1/jobs
@@ -28,7 +32,34 @@ func (s *MasterServer) HandleSubmitJob(w http.ResponseWriter, r *http.Request) {
 	if req.JobID == "" { req.JobID = uuid.New().String() }
 	// Si no se especifica particiones globales, usamos un default razonable
 	if req.NumPartitions == 0 { req.NumPartitions = 2 }
+	if req.Pool == "" { req.Pool = common.DefaultPool }
 
+	// Rechazar specs inválidos antes de crear el Job (tipos, UDFs, ciclos, aristas...)
+	if errs := dag.ValidateJob(req); len(errs) > 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":  "invalid job spec",
+			"errors": errs,
+		})
+		return
+	}
+
+	// Como en Hadoop, no se sobrescribe una salida ya publicada
+	if req.OutputPath != "" {
+		if _, err := os.Stat(filepath.Join(req.OutputPath, common.SuccessMarker)); err == nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error":  "invalid job spec",
+				"errors": []dag.ValidationError{{Field: "output_path", Message: "el directorio ya contiene un resultado (" + common.SuccessMarker + ")"}},
+			})
+			return
+		}
+	}
+
+	// La ruta final queda en la definición guardada (la necesita un Master recuperado)
+	if req.OutputPath == "" { req.OutputPath = defaultOutputPath(req.JobID) }
 	s.Store.CreateJob(&req)
 	s.Scheduler.SubmitJob(&req)
 
@@ -53,11 +84,32 @@ func (s *MasterServer) HandleGetJob(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(job)
 }
 
+// DELETE /api/v1/jobs/{id}
+func (s *MasterServer) HandleCancelJob(w http.ResponseWriter, r *http.Request) {
+	jobID := r.PathValue("id")
+	err := s.Scheduler.CancelJob(jobID)
+	switch {
+	case errors.Is(err, ErrJobNotFound):
+		http.Error(w, "Job not found", 404); return
+	case errors.Is(err, ErrJobFinished):
+		http.Error(w, err.Error(), http.StatusConflict); return
+	case err != nil:
+		http.Error(w, err.Error(), 500); return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"job_id": jobID,
+		"status": common.JobStatusCancelled,
+	})
+}
+
 // POST /heartbeat (Internal)
 func (s *MasterServer) HandleHeartbeat(w http.ResponseWriter, r *http.Request) {
 	var hb common.Heartbeat
 	if err := json.NewDecoder(r.Body).Decode(&hb); err != nil { return }
 	s.Registry.UpdateHeartbeat(hb)
+	s.Scheduler.abortCancelledJobs(hb)
 }
 
 // POST /report (Internal)
@@ -65,21 +117,56 @@ func (s *MasterServer) HandleReport(w http.ResponseWriter, r *http.Request) {
 	var rep common.TaskReport
 	if err := json.NewDecoder(r.Body).Decode(&rep); err != nil { return }
 
-	// Persistir reporte para trazabilidad
-	s.Store.AddTaskReport(rep.JobID, rep.StageID, rep)
+	// Reportes tardíos de un Job cancelado: sus tareas ya se descartaron
+	if job := s.Store.GetJob(rep.JobID); job != nil && job.Status == common.JobStatusCancelled {
+		fmt.Printf("[Master] Ignorando reporte de %s: el Job %s fue cancelado\n", rep.TaskID, rep.JobID)
+		return
+	}
+
+	// Confirmar la salida final del intento antes de darlo por bueno
+	if rep.Status == common.TaskStatusSuccess {
+		if err := s.Scheduler.CommitTaskOutput(rep); err != nil {
+			fmt.Printf("[Master] Commit de la tarea %s falló: %v\n", rep.TaskID, err)
+			rep.Status = common.TaskStatusFailure
+			rep.ErrorMsg = "commit de salida: " + err.Error()
+		}
+	}
+
+	// El fallo tardío de un intento reemplazado no es un fallo del intento en curso: no se registra
+	// ni suma reintentos (la tarea ya se re-encoló al reemplazarlo)
+	if rep.Status != common.TaskStatusSuccess && !s.Scheduler.isLiveAttempt(rep.TaskID, rep.Attempt) {
+		fmt.Printf("[Master] Ignorando fallo del intento %d de %s: ya no está en curso\n", rep.Attempt, rep.TaskID)
+		return
+	}
+
+	// Persistir reporte para trazabilidad. Solo cuenta el primer éxito de cada partición:
+	// reintentos que reportan dos veces o intentos tardíos de un worker dado por muerto se ignoran.
+	if !s.Store.AddTaskReport(rep.JobID, rep.StageID, rep) {
+		won, _ := s.Store.SuccessfulReport(rep.JobID, rep.StageID, rep.TaskID)
+		fmt.Printf("[Master] Ignorando reporte %s del intento %d de %s: ya se registró el intento %d\n", rep.Status, rep.Attempt, rep.TaskID, won.Attempt)
+		return
+	}
 	
 	if rep.Status == common.TaskStatusSuccess {
 		// Notificar éxito al Scheduler para que avance el DAG
 		s.Scheduler.HandleTaskCompletion(rep)
 	} else {
 		// MANEJO DE FALLOS
+		// Si la tarea tenía una copia especulativa, basta con que el otro intento siga en curso
+		if s.Scheduler.dropFailedAttempt(rep.TaskID, rep.Attempt) {
+			fmt.Printf("[Master] Intento %d de %s falló, pero otro intento sigue en curso\n", rep.Attempt, rep.TaskID)
+			return
+		}
 		// Buscamos la tarea real en memoria del Scheduler para re-encolarla.
 		// Es vital usar la tarea original porque contiene la definición de la operación (UDF, Inputs).
 		s.Scheduler.mu.Lock()
 		realTask, exists := s.Scheduler.RunningTasks[rep.TaskID]
 		s.Scheduler.mu.Unlock()
 		
-		if exists {
+		if exists && rep.FetchFailure != nil {
+			// El fallo no es de la tarea: falta el shuffle de un padre, se recalcula por linaje
+			s.Scheduler.HandleFetchFailure(realTask, *rep.FetchFailure)
+		} else if exists {
 			s.Scheduler.HandleTaskFailure(realTask, rep.ErrorMsg)
 		} else {
 			// Si no existe, es posible que sea un reporte tardío de una tarea que ya dimos por perdida,
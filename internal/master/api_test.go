@@ -1,4 +1,20 @@
 package master
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"mini-spark/internal/common"
+	"mini-spark/internal/dag"
+	"mini-spark/internal/storage"
+)
+
 /*
 import (
 	"bytes"
@@ -18,7 +34,7 @@ import (
 
 func setupMasterServer(t *testing.T) *MasterServer {
 	// Inicialización de los componentes internos del Master
-	store := storage.NewJobStore()
+	store := storage.NewMemoryStore()
 	registry := NewWorkerRegistry()
 	scheduler := NewScheduler(registry, store)
 	
@@ -258,4 +274,198 @@ func TestMasterAPI_HandleReport(t *testing.T) {
 	})
 }
 
-*/
\ No newline at end of file
+*/
+
+func TestMasterAPI_SubmitJobValidation(t *testing.T) {
+	store := storage.NewMemoryStore()
+	registry := NewWorkerRegistry()
+	server := &MasterServer{Scheduler: NewScheduler(registry, store), Registry: registry, Store: store}
+
+	// Directorio con un resultado ya publicado
+	published := t.TempDir()
+	os.WriteFile(filepath.Join(published, common.SuccessMarker), nil, 0644)
+
+	tests := []struct {
+		name       string
+		body       string
+		expectCode int
+		expectErrs int
+	}{
+		{
+			name:       "Success_ValidJob",
+			body:       `{"name":"ok","path":"/data/in.txt","dag":{"nodes":[{"id":"m","op_type":"MAP","udf_name":"map_wordcount"},{"id":"r","op_type":"REDUCE_BY_KEY","udf_name":"reduce_sum"}],"edges":[["m","r"]]}}`,
+			expectCode: http.StatusOK,
+		},
+		{
+			name:       "Failure_NoNodes",
+			body:       `{"name":"vacio","path":"/data/in.txt","dag":{"nodes":[]}}`,
+			expectCode: http.StatusBadRequest,
+			expectErrs: 1,
+		},
+		{
+			name:       "Failure_TypoAndCycle",
+			body:       `{"name":"typo","path":"/data/in.txt","dag":{"nodes":[{"id":"m","op_type":"MAP","udf_name":"map_wordcout"},{"id":"f","op_type":"FILTER","udf_name":"not_empty"}],"edges":[["m","f"],["f","m"]]}}`,
+			expectCode: http.StatusBadRequest,
+			expectErrs: 2, // La UDF mal escrita y el ciclo, en una sola respuesta
+		},
+		{
+			name:       "Failure_OutputAlreadyPublished",
+			body:       fmt.Sprintf(`{"name":"repetido","path":"/data/in.txt","output_path":%q,"dag":{"nodes":[{"id":"m","op_type":"MAP","udf_name":"map_wordcount"}]}}`, published),
+			expectCode: http.StatusBadRequest,
+			expectErrs: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := len(store.Jobs)
+			req := httptest.NewRequest("POST", "/api/v1/jobs", bytes.NewBufferString(tt.body))
+			rr := httptest.NewRecorder()
+			server.HandleSubmitJob(rr, req)
+
+			if rr.Code != tt.expectCode {
+				t.Fatalf("Esperaba código %d, obtuvo %d. Body: %s", tt.expectCode, rr.Code, rr.Body.String())
+			}
+			if tt.expectCode != http.StatusBadRequest {
+				return
+			}
+
+			var resp struct {
+				Errors []dag.ValidationError `json:"errors"`
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("Respuesta 400 no es JSON: %v", err)
+			}
+			if len(resp.Errors) != tt.expectErrs {
+				t.Errorf("Esperaba %d errores, obtuvo %v", tt.expectErrs, resp.Errors)
+			}
+			if len(store.Jobs) != before {
+				t.Errorf("Un spec inválido no debe crear el Job (jobs en store: %d)", len(store.Jobs))
+			}
+		})
+	}
+
+	// Un ciclo sin otros errores se reporta como error de aristas
+	cyclic := common.JobRequest{InputPath: "/data/in.txt", DAG: common.DAG{
+		Nodes: []common.OperationNode{{ID: "m", Type: common.OpTypeMap, UDFName: "map_wordcount"}, {ID: "f", Type: common.OpTypeFilter, UDFName: "not_empty"}},
+		Edges: [][]string{{"m", "f"}, {"f", "m"}},
+	}}
+	body, _ := json.Marshal(cyclic)
+	rr := httptest.NewRecorder()
+	server.HandleSubmitJob(rr, httptest.NewRequest("POST", "/api/v1/jobs", bytes.NewBuffer(body)))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Un DAG cíclico debe rechazarse con 400, obtuvo %d", rr.Code)
+	}
+}
+
+func TestMasterAPI_DuplicateReports(t *testing.T) {
+	store := storage.NewMemoryStore()
+	registry := NewWorkerRegistry()
+	server := &MasterServer{Scheduler: NewScheduler(registry, store), Registry: registry, Store: store}
+
+	job := createTestJob("job-dup")
+	job.OutputPath = t.TempDir()
+	store.CreateJob(&job)
+	server.Scheduler.SubmitJob(&job)
+
+	report := func(rep common.TaskReport) {
+		body, _ := json.Marshal(rep)
+		server.HandleReport(httptest.NewRecorder(), httptest.NewRequest("POST", "/report", bytes.NewReader(body)))
+	}
+	mapReport := func(i, attempt int, worker string) common.TaskReport {
+		return common.TaskReport{
+			TaskID: fmt.Sprintf("job-dup-stage-map-%d", i), JobID: job.JobID, StageID: "stage-map", Status: common.TaskStatusSuccess,
+			WorkerID: worker, Attempt: attempt, ShuffleOutput: []common.ShuffleMeta{{PartitionKey: i, Path: fmt.Sprintf("/tmp/dup-%d", i)}},
+		}
+	}
+	countStage := func(stageID string) int {
+		server.Scheduler.mu.Lock()
+		defer server.Scheduler.mu.Unlock()
+		n := 0
+		for _, task := range server.Scheduler.PendingTasks {
+			if task.StageID == stageID { n++ }
+		}
+		return n
+	}
+
+	// El mismo MAP reporta dos veces (reintento + intento tardío): cuenta una sola partición
+	report(mapReport(0, 1, "w1"))
+	report(mapReport(0, 2, "w2"))
+	if store.IsStageCompleted(job.JobID, "stage-map") || countStage("stage-reduce") != 0 {
+		t.Fatalf("Un reporte duplicado completó el stage MAP antes de tiempo")
+	}
+	if got := len(store.GetStageReports(job.JobID, "stage-map")); got != 1 {
+		t.Errorf("Se esperaba un único éxito registrado, hay %d", got)
+	}
+	if won, _ := store.SuccessfulReport(job.JobID, "stage-map", "job-dup-stage-map-0"); won.Attempt != 1 {
+		t.Errorf("Debe ganar el primer intento exitoso, ganó el %d", won.Attempt)
+	}
+	if countStage("stage-map") != 1 {
+		t.Errorf("La copia en cola de una partición ya completada debe descartarse")
+	}
+
+	// Un fallo tardío de una partición ya completada no la re-encola
+	server.Scheduler.mu.Lock()
+	server.Scheduler.PendingTasks = nil
+	server.Scheduler.RunningTasks["job-dup-stage-map-0"] = common.Task{TaskID: "job-dup-stage-map-0", JobID: job.JobID, StageID: "stage-map"}
+	server.Scheduler.mu.Unlock()
+	report(common.TaskReport{TaskID: "job-dup-stage-map-0", JobID: job.JobID, StageID: "stage-map", Status: common.TaskStatusFailure, Attempt: 3})
+	if countStage("stage-map") != 0 {
+		t.Errorf("Un fallo tardío re-encoló una partición ya completada")
+	}
+
+	// La segunda partición completa el stage: el REDUCE se lanza una sola vez, aunque el reporte se repita
+	report(mapReport(1, 4, "w1"))
+	report(mapReport(1, 5, "w2"))
+	if got := countStage("stage-reduce"); got != 2 {
+		t.Errorf("Se esperaban 2 tareas REDUCE (lanzadas una vez), obtuvo %d", got)
+	}
+}
+
+func TestMasterAPI_StaleFailureReport(t *testing.T) {
+	store := storage.NewMemoryStore()
+	registry := NewWorkerRegistry()
+	server := &MasterServer{Scheduler: NewScheduler(registry, store), Registry: registry, Store: store}
+
+	job := createTestJob("job-stale")
+	job.OutputPath = t.TempDir()
+	store.CreateJob(&job)
+	server.Scheduler.SubmitJob(&job)
+
+	// El intento 1 corría en un worker dado por muerto: la tarea se relanzó como intento 2
+	taskID := "job-stale-stage-map-0"
+	server.Scheduler.mu.Lock()
+	task := server.Scheduler.PendingTasks[0]
+	server.Scheduler.PendingTasks = nil
+	task.Attempt = 2
+	server.Scheduler.RunningTasks[taskID] = task
+	server.Scheduler.AssignedWorker[taskID] = "w2"
+	server.Scheduler.mu.Unlock()
+
+	report := func(attempt int) {
+		rep := common.TaskReport{TaskID: taskID, JobID: job.JobID, StageID: "stage-map", Status: common.TaskStatusFailure, Attempt: attempt, ErrorMsg: "worker caído"}
+		body, _ := json.Marshal(rep)
+		server.HandleReport(httptest.NewRecorder(), httptest.NewRequest("POST", "/report", bytes.NewReader(body)))
+	}
+
+	// El fallo tardío del intento 1 no toca al intento 2 ni suma reintentos
+	report(1)
+	server.Scheduler.mu.Lock()
+	running, ok := server.Scheduler.RunningTasks[taskID]
+	pending := len(server.Scheduler.PendingTasks)
+	server.Scheduler.mu.Unlock()
+	if !ok || running.Attempt != 2 || running.RetryCount != 0 || pending != 0 {
+		t.Fatalf("Un fallo tardío afectó al intento en curso: %+v (en cola: %d)", running, pending)
+	}
+	if status := store.GetJob(job.JobID).TaskStatus[taskID]; status == common.TaskStatusFailure {
+		t.Errorf("El fallo de un intento reemplazado no debía registrarse")
+	}
+
+	// El fallo del intento en curso sí se reintenta
+	report(2)
+	server.Scheduler.mu.Lock()
+	defer server.Scheduler.mu.Unlock()
+	if len(server.Scheduler.PendingTasks) != 1 || server.Scheduler.PendingTasks[0].RetryCount != 1 {
+		t.Errorf("El fallo del intento en curso debía re-encolar la tarea con un reintento: %+v", server.Scheduler.PendingTasks)
+	}
+}
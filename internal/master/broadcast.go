@@ -0,0 +1,73 @@
+package master
+
+import (
+	"sort"
+
+	"mini-spark/internal/common"
+)
+
+// ==========================================
+// BROADCAST JOIN
+// ==========================================
+// Un JOIN contra una tabla chica no necesita repartir el lado grande por clave: el lado chico se replica
+// completo en cada tarea y cada registro del lado grande se cruza contra esa tabla en memoria.
+// Marcado en el spec (OperationNode.Broadcast), el plan fusiona el JOIN con el stage del lado grande
+// (dag.Plan.BroadcastParents) y el cruce ocurre antes de escribir: el lado grande no hace shuffle.
+// Por umbral (JobRequest.BroadcastJoinBytes) el tamaño recién se conoce al terminar los padres, así
+// que ambos escriben su shuffle: cada tarea de BROADCAST_JOIN recorre los bloques de una sola tarea de
+// map del lado grande sin agruparlos por clave (con la política locality, donde están esos bloques).
+// Un JOIN marcado que no se pudo fusionar (su lado grande alimenta a otro nodo) sigue este camino.
+
+// broadcastSide devuelve el lado que replica un JOIN: el marcado en el spec o, con umbral, el más chico
+// de los que no lo superan. "" = JOIN por shuffle.
+func broadcastSide(job *common.JobRequest, node common.OperationNode, inputs []common.TaskReport) string {
+	if !common.IsJoinOp(node.Type) || len(node.Dependencies) != 2 { return "" }
+	if node.Broadcast != "" { return node.Broadcast }
+	if job.BroadcastJoinBytes <= 0 { return "" }
+
+	sizes := make(map[string]int64)
+	for _, rep := range inputs {
+		for _, meta := range rep.ShuffleOutput { sizes[rep.StageID] += meta.Size }
+	}
+	side, best := "", int64(0)
+	for _, candidate := range []string{common.BroadcastLeft, common.BroadcastRight} {
+		replicated, _ := joinStages(node, candidate)
+		size := sizes[replicated]
+		if !common.CanBroadcast(node.Type, candidate) || size > job.BroadcastJoinBytes { continue }
+		if side == "" || size < best { side, best = candidate, size }
+	}
+	return side
+}
+
+// joinStages devuelve el stage del lado replicado y el del lado que se recorre
+func joinStages(node common.OperationNode, side string) (string, string) {
+	if side == common.BroadcastLeft { return node.Dependencies[0], node.Dependencies[1] }
+	return node.Dependencies[1], node.Dependencies[0]
+}
+
+// replicatedSide es el lado contrario al stage que se recorre
+func replicatedSide(node common.OperationNode, streamed string) string {
+	if node.Dependencies[0] == streamed { return common.BroadcastRight }
+	return common.BroadcastLeft
+}
+
+// broadcastLayout crea una tarea por tarea de map del lado streamed (en orden de TaskID): lee todas
+// las particiones de esa tarea y el lado replicado completo
+func broadcastLayout(n int, streamed string, inputs []common.TaskReport) []common.ShuffleSlice {
+	all := make([]int, n)
+	for p := range all { all[p] = p }
+	seen := make(map[string]bool)
+	var taskIDs []string
+	for _, rep := range inputs {
+		if rep.StageID != streamed || seen[rep.TaskID] { continue }
+		seen[rep.TaskID] = true
+		taskIDs = append(taskIDs, rep.TaskID)
+	}
+	sort.Strings(taskIDs)
+
+	layout := make([]common.ShuffleSlice, len(taskIDs))
+	for i, id := range taskIDs {
+		layout[i] = common.ShuffleSlice{Partitions: all, Source: streamed, MapTasks: []string{id}, Broadcast: true}
+	}
+	return layout
+}
@@ -0,0 +1,138 @@
+package master
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"mini-spark/internal/common"
+	"mini-spark/internal/dag"
+	"mini-spark/internal/storage"
+)
+
+func TestPlanLayout_BroadcastJoin(t *testing.T) {
+	joinJob := func(opType, broadcast string, threshold int64) (*common.JobRequest, *dag.Plan) {
+		job := &common.JobRequest{
+			JobID: "job-bcast", NumPartitions: 2, BroadcastJoinBytes: threshold,
+			DAG: common.DAG{
+				Nodes: []common.OperationNode{
+					{ID: "orders", Type: common.OpTypeMap, UDFName: "map_wc", NumPartitions: 3},
+					{ID: "users", Type: common.OpTypeMap, UDFName: "map_wc", NumPartitions: 1},
+					{ID: "joined", Type: opType, UDFName: "join_pair", Broadcast: broadcast},
+				},
+				Edges: [][]string{{"orders", "joined"}, {"users", "joined"}},
+			},
+		}
+		plan, err := dag.NewPlan(job.DAG)
+		if err != nil { t.Fatalf("Plan inválido: %v", err) }
+		return job, plan
+	}
+	// Lado izquierdo: 3 MAP de 100 bytes por partición; lado derecho: una tabla de 20 bytes
+	inputs := []common.TaskReport{
+		mapOutput("job-bcast", "orders", 0, map[int]int64{0: 100, 1: 100}),
+		mapOutput("job-bcast", "orders", 1, map[int]int64{0: 100, 1: 100}),
+		mapOutput("job-bcast", "orders", 2, map[int]int64{0: 100, 1: 100}),
+		mapOutput("job-bcast", "users", 0, map[int]int64{0: 10, 1: 10}),
+	}
+
+	// Por umbral: se replica el lado derecho y hay una tarea por MAP del izquierdo
+	job, plan := joinJob(common.OpTypeJoin, "", 50)
+	stage, _ := plan.Stage("joined")
+	layout := planLayout(job, stage, inputs)
+	if len(layout) != 3 || !layout[0].Broadcast || layout[0].Source != "orders" {
+		t.Fatalf("Se esperaba una tarea de BROADCAST_JOIN por MAP de orders: %+v", layout)
+	}
+	tasks, err := stageTasks(job, plan, stage, inputs, layout)
+	if err != nil { t.Fatalf("Error planificando: %v", err) }
+	for i, task := range tasks {
+		if task.Operation.Broadcast != common.BroadcastRight || len(task.InputPartition.BroadcastMap) != 2 {
+			t.Errorf("%s debe cargar completo el lado derecho: %+v", task.TaskID, task.InputPartition.BroadcastMap)
+		}
+		for _, url := range task.InputPartition.ShuffleMap {
+			if !strings.Contains(url, fmt.Sprintf("/orders_%d_", i)) {
+				t.Errorf("%s solo debe recorrer los bloques de su MAP, lee %s", task.TaskID, url)
+			}
+		}
+		if len(task.InputPartition.ShuffleMap) != 2 || task.PartitionIndex != i {
+			t.Errorf("%s debe leer las 2 particiones de su MAP y escribir part %d: %+v", task.TaskID, i, task)
+		}
+	}
+
+	// Ningún lado entra en el umbral: JOIN por shuffle
+	job, plan = joinJob(common.OpTypeJoin, "", 5)
+	stage, _ = plan.Stage("joined")
+	if layout := planLayout(job, stage, inputs); len(layout) != 2 || layout[0].Broadcast {
+		t.Errorf("Sobre el umbral el JOIN debe hacer shuffle: %+v", layout)
+	}
+
+	// Un RIGHT OUTER JOIN conserva el lado derecho: por umbral no puede replicarlo
+	job, plan = joinJob(common.OpTypeRightOuterJoin, "", 50)
+	stage, _ = plan.Stage("joined")
+	if layout := planLayout(job, stage, inputs); len(layout) != 2 {
+		t.Errorf("RIGHT OUTER JOIN no puede replicar el lado derecho: %+v", layout)
+	}
+
+	// Marcado en el spec: el JOIN se fusiona con el stage del lado que se recorre, que cruza sus propios
+	// registros; el lado replicado escribe una sola partición y pasa a ser padre broadcast
+	job, plan = joinJob(common.OpTypeJoin, common.BroadcastLeft, 0)
+	if stage, _ := plan.Stage("joined"); len(stage.Nodes) != 2 || stage.Head().ID != "users" {
+		t.Errorf("El JOIN debía fusionarse con el stage de users: %+v", stage.Nodes)
+	}
+	if got := plan.BroadcastParents("joined"); len(got) != 1 || got[0] != "orders" {
+		t.Errorf("orders debía ser el padre broadcast del stage fusionado: %v", got)
+	}
+	orders, _ := plan.Stage("orders")
+	if out := stageOutput(job, plan, orders); out.NumPartitions != 1 {
+		t.Errorf("El lado replicado no necesita particionar: %d particiones", out.NumPartitions)
+	}
+}
+
+func TestScheduler_MapSideBroadcastJoin(t *testing.T) {
+	store := storage.NewMemoryStore()
+	scheduler := NewScheduler(NewWorkerRegistry(), store)
+
+	job := common.JobRequest{
+		JobID: "job-mapside", NumPartitions: 2, OutputPath: t.TempDir(),
+		DAG: common.DAG{
+			Nodes: []common.OperationNode{
+				{ID: "orders", Type: common.OpTypeMap, UDFName: "map_wc", NumPartitions: 3},
+				{ID: "users", Type: common.OpTypeMap, UDFName: "map_wc", NumPartitions: 1},
+				{ID: "joined", Type: common.OpTypeJoin, UDFName: "join_pair", Broadcast: common.BroadcastRight},
+			},
+			Edges: [][]string{{"orders", "joined"}, {"users", "joined"}},
+		},
+	}
+	store.CreateJob(&job)
+	scheduler.SubmitJob(&job)
+
+	// Solo arranca el lado replicado: el stage de orders espera su tabla
+	scheduler.mu.Lock()
+	roots := scheduler.PendingTasks
+	scheduler.PendingTasks = nil
+	scheduler.mu.Unlock()
+	if len(roots) != 1 || roots[0].StageID != "users" {
+		t.Fatalf("Solo debía encolarse el MAP de users: %+v", roots)
+	}
+
+	rep := mapOutput(job.JobID, "users", 0, map[int]int64{0: 20})
+	store.AddTaskReport(job.JobID, rep.StageID, rep)
+	scheduler.HandleTaskCompletion(rep)
+
+	scheduler.mu.Lock()
+	tasks := scheduler.PendingTasks
+	scheduler.mu.Unlock()
+	if len(tasks) != 3 {
+		t.Fatalf("Se esperaba una tarea por partición de orders, obtuvo %d", len(tasks))
+	}
+	for _, task := range tasks {
+		if task.StageID != "joined" || task.Operation.ID != "orders" || task.InputPartition.SourceType == common.SourceTypeShuffle {
+			t.Errorf("%s debía leer orders de archivo y cruzar en el mismo stage: %+v", task.TaskID, task)
+		}
+		if len(task.Pipeline) != 1 || task.Pipeline[0].ID != "joined" {
+			t.Errorf("%s debía llevar el JOIN en su pipeline: %+v", task.TaskID, task.Pipeline)
+		}
+		if len(task.InputPartition.BroadcastMap) != 1 || len(task.InputPartition.ShuffleMap) != 0 {
+			t.Errorf("%s debía cargar solo la tabla de users: %+v", task.TaskID, task.InputPartition)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package master
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"mini-spark/internal/common"
+	"mini-spark/internal/dag"
+)
+
+// ==========================================
+// COMMIT DE LA SALIDA FINAL
+// ==========================================
+// Los workers escriben cada intento en <output>/_temporary/<task>_attempt_<n>/. El Master decide qué
+// intento gana (el primero que reporta éxito) y solo publica la salida cuando el Job completo termina,
+// así un consumidor que ve _SUCCESS sabe que el directorio tiene todas las particiones y nada más.
+
+// defaultOutputPath es el directorio de resultados si el Job no declara output_path
+func defaultOutputPath(jobID string) string {
+	return filepath.Join("./data/outputs", jobID)
+}
+
+// sinkOutputDir devuelve el directorio final de un stage terminal. Con un solo stage terminal es
+// el output_path del Job; con varios, cada uno publica en su propio subdirectorio.
+func sinkOutputDir(job *common.JobRequest, plan *dag.Plan, stageID string) string {
+	sinks := 0
+	for _, id := range plan.TopologicalOrder() {
+		if len(plan.Children(id)) == 0 { sinks++ }
+	}
+	if sinks > 1 { return filepath.Join(job.OutputPath, stageID) }
+	return job.OutputPath
+}
+
+// sinkOutputDirs devuelve los directorios finales de todos los stages terminales del plan
+func sinkOutputDirs(job *common.JobRequest, plan *dag.Plan) []string {
+	var dirs []string
+	for _, id := range plan.TopologicalOrder() {
+		if len(plan.Children(id)) == 0 { dirs = append(dirs, sinkOutputDir(job, plan, id)) }
+	}
+	return dirs
+}
+
+// commitTaskOutput promueve el archivo de un intento a <dir>/_temporary/committed/part-NNNNN.
+// Si la partición ya tiene un intento confirmado, el nuevo se descarta (gana el primero).
+func commitTaskOutput(outputDir, attemptPath string) error {
+	committedDir := filepath.Join(outputDir, common.OutputTempDir, common.OutputCommittedDir)
+	if err := os.MkdirAll(committedDir, 0755); err != nil { return err }
+
+	target := filepath.Join(committedDir, filepath.Base(attemptPath))
+	if _, err := os.Stat(target); err == nil {
+		log.Printf("[Committer] %s ya confirmado, se descarta el intento %s", target, attemptPath)
+		os.RemoveAll(filepath.Dir(attemptPath))
+		return nil
+	}
+	if err := os.Rename(attemptPath, target); err != nil { return err }
+	os.Remove(filepath.Dir(attemptPath)) // Directorio del intento (ya vacío)
+	return nil
+}
+
+// commitJobOutput publica las particiones confirmadas en el directorio final, borra _temporary
+// y escribe el marcador _SUCCESS.
+func commitJobOutput(outputDir string) error {
+	tempDir := filepath.Join(outputDir, common.OutputTempDir)
+	committedDir := filepath.Join(tempDir, common.OutputCommittedDir)
+
+	parts, err := os.ReadDir(committedDir)
+	if err != nil && !os.IsNotExist(err) { return err }
+	if err := os.MkdirAll(outputDir, 0755); err != nil { return err }
+	for _, part := range parts {
+		if err := os.Rename(filepath.Join(committedDir, part.Name()), filepath.Join(outputDir, part.Name())); err != nil {
+			return fmt.Errorf("publicando %s: %w", part.Name(), err)
+		}
+	}
+	if err := os.RemoveAll(tempDir); err != nil { return err }
+	return os.WriteFile(filepath.Join(outputDir, common.SuccessMarker), nil, 0644)
+}
+
+// abortJobOutput descarta los intentos y particiones sin publicar de un Job fallido
+func abortJobOutput(outputDir string) {
+	if err := os.RemoveAll(filepath.Join(outputDir, common.OutputTempDir)); err != nil {
+		log.Printf("[Committer] No se pudo limpiar %s: %v", outputDir, err)
+	}
+}
@@ -0,0 +1,131 @@
+package master
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"mini-spark/internal/common"
+	"mini-spark/internal/storage"
+)
+
+// writeAttempt simula la escritura de un intento por parte de un worker
+func writeAttempt(t *testing.T, outputDir, taskID string, attempt, partition int, content string) string {
+	path := common.AttemptOutputPath(outputDir, taskID, attempt, partition)
+	os.MkdirAll(filepath.Dir(path), 0755)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func listDir(t *testing.T, dir string) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("No se pudo listar %s: %v", dir, err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestCommitter_TaskAndJobCommit(t *testing.T) {
+	out := t.TempDir()
+
+	// Partición 0: dos intentos exitosos (p. ej. el worker se dio por muerto y volvió). Gana el primero.
+	first := writeAttempt(t, out, "job-t-0", 1, 0, "primero\n")
+	second := writeAttempt(t, out, "job-t-0", 3, 0, "duplicado\n")
+	part1 := writeAttempt(t, out, "job-t-1", 2, 1, "uno\n")
+	// Intento fallido que nunca se confirma
+	writeAttempt(t, out, "job-t-1", 4, 1, "basura\n")
+
+	for _, attempt := range []string{first, second, part1} {
+		if err := commitTaskOutput(out, attempt); err != nil {
+			t.Fatalf("commitTaskOutput(%s) falló: %v", attempt, err)
+		}
+	}
+	if names := listDir(t, out); strings.Join(names, ",") != common.OutputTempDir {
+		t.Fatalf("Antes del commit del Job no debe publicarse nada, hay: %v", names)
+	}
+
+	if err := commitJobOutput(out); err != nil {
+		t.Fatalf("commitJobOutput falló: %v", err)
+	}
+	expected := []string{common.SuccessMarker, "part-00000", "part-00001"}
+	if names := listDir(t, out); strings.Join(names, ",") != strings.Join(expected, ",") {
+		t.Errorf("Directorio final incorrecto. Esperado %v, obtuvo %v", expected, names)
+	}
+	if b, _ := os.ReadFile(filepath.Join(out, "part-00000")); string(b) != "primero\n" {
+		t.Errorf("part-00000 debe venir del primer intento confirmado, contiene %q", b)
+	}
+}
+
+func TestCommitter_AbortLeavesNoMarker(t *testing.T) {
+	out := t.TempDir()
+	commitTaskOutput(out, writeAttempt(t, out, "job-t-0", 1, 0, "x\n"))
+
+	abortJobOutput(out)
+	if names := listDir(t, out); len(names) != 0 {
+		t.Errorf("Un Job abortado no debe dejar archivos, hay: %v", names)
+	}
+}
+
+func TestScheduler_FinalOutputCommit(t *testing.T) {
+	store := storage.NewMemoryStore()
+	scheduler := NewScheduler(NewWorkerRegistry(), store)
+
+	out := filepath.Join(t.TempDir(), "resultado")
+	job := common.JobRequest{
+		JobID:         "job-commit",
+		InputPath:     "/data/in.txt",
+		OutputPath:    out,
+		NumPartitions: 2,
+		DAG: common.DAG{Nodes: []common.OperationNode{
+			{ID: "map", Type: common.OpTypeMap, UDFName: "map_wordcount", NumPartitions: 2},
+		}},
+	}
+	store.CreateJob(&job)
+	scheduler.SubmitJob(&job)
+
+	scheduler.mu.Lock()
+	tasks := append([]common.Task(nil), scheduler.PendingTasks...)
+	scheduler.PendingTasks = nil
+	scheduler.mu.Unlock()
+
+	for i, task := range tasks {
+		if task.OutputTarget.Type != common.OutputTypeFinal || task.OutputTarget.Path != out {
+			t.Fatalf("El stage terminal debe escribir en la salida final del Job, obtuvo %+v", task.OutputTarget)
+		}
+		rep := common.TaskReport{
+			TaskID: task.TaskID, JobID: job.JobID, StageID: "map", Status: common.TaskStatusSuccess, WorkerID: "w1",
+			OutputPath: writeAttempt(t, out, task.TaskID, i+1, task.PartitionIndex, "ok\n"),
+		}
+		if err := scheduler.CommitTaskOutput(rep); err != nil {
+			t.Fatalf("CommitTaskOutput falló: %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(out, common.SuccessMarker)); err == nil {
+			t.Fatalf("_SUCCESS escrito antes de terminar el Job")
+		}
+		store.AddTaskReport(job.JobID, "map", rep)
+		scheduler.HandleTaskCompletion(rep)
+	}
+
+	if status := store.GetJob(job.JobID).Status; status != common.JobStatusSucceeded {
+		t.Fatalf("Se esperaba SUCCEEDED, obtuvo %s", status)
+	}
+	expected := []string{common.SuccessMarker, "part-00000", "part-00001"}
+	if names := listDir(t, out); strings.Join(names, ",") != strings.Join(expected, ",") {
+		t.Errorf("Salida publicada incorrecta. Esperado %v, obtuvo %v", expected, names)
+	}
+
+	// Un reporte que apunta fuera del directorio temporal del Job no se confirma
+	bad := common.TaskReport{JobID: job.JobID, StageID: "map", Status: common.TaskStatusSuccess, OutputPath: "/etc/passwd"}
+	if err := scheduler.CommitTaskOutput(bad); err == nil {
+		t.Errorf("Se esperaba error para una salida fuera de %s", out)
+	}
+}
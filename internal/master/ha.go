@@ -0,0 +1,177 @@
+package master
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"mini-spark/internal/common"
+)
+
+// ==========================================
+// ALTA DISPONIBILIDAD (LÍDER / STANDBY)
+// ==========================================
+// Dos Masters comparten el directorio de estado. El líder es quien tiene el lease vigente en
+// <state-dir>/leader.lease y lo renueva cada TTL/3; el standby atiende HTTP sin abrir el estado
+// (responde 503 indicando el líder) y reintenta tomar el lease. Cuando el líder deja de renovarlo,
+// el standby lo toma al vencer, carga el WAL y retoma los Jobs (ver Scheduler.Recover).
+
+const (
+	LeaseFile       = "leader.lease"
+	DefaultLeaseTTL = 10 * time.Second
+)
+
+var errLeaseBusy = errors.New("lease en uso por otro Master")
+
+// LeaseInfo es el contenido del archivo de lease
+type LeaseInfo struct {
+	Holder  string `json:"holder"`  // Identificador del proceso Master
+	URL     string `json:"url"`     // URL donde atiende el líder (la siguen workers y clientes)
+	Expires int64  `json:"expires"` // Vencimiento (unix ms)
+}
+
+// Lease es el lease de liderazgo de un Master sobre un directorio de estado
+type Lease struct {
+	path   string
+	holder string
+	url    string
+	TTL    time.Duration
+}
+
+func NewLease(stateDir, holder, url string, ttl time.Duration) *Lease {
+	if ttl <= 0 { ttl = DefaultLeaseTTL }
+	return &Lease{path: filepath.Join(stateDir, LeaseFile), holder: holder, url: url, TTL: ttl}
+}
+
+// Current devuelve el lease vigente (false si no hay o ya venció)
+func (l *Lease) Current() (LeaseInfo, bool) {
+	data, err := os.ReadFile(l.path)
+	if err != nil { return LeaseInfo{}, false }
+	var info LeaseInfo
+	if json.Unmarshal(data, &info) != nil || time.Now().UnixMilli() >= info.Expires { return LeaseInfo{}, false }
+	return info, true
+}
+
+// lock toma el candado que serializa la lectura y escritura del lease entre procesos.
+// Un candado más viejo que el TTL quedó de un Master que murió a mitad: se descarta.
+func (l *Lease) lock() (func(), error) {
+	lockPath := l.path + ".lock"
+	for try := 0; try < 2; try++ {
+		f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(lockPath) }, nil
+		}
+		if !os.IsExist(err) { return nil, err }
+		if st, err := os.Stat(lockPath); err == nil && time.Since(st.ModTime()) > l.TTL { os.Remove(lockPath) }
+	}
+	return nil, errLeaseBusy
+}
+
+// TryAcquire toma el lease si está libre o vencido, o lo renueva si ya es propio.
+// Devuelve false si otro Master lo tiene vigente.
+func (l *Lease) TryAcquire() (bool, error) {
+	if err := os.MkdirAll(filepath.Dir(l.path), 0755); err != nil { return false, err }
+	unlock, err := l.lock()
+	if errors.Is(err, errLeaseBusy) { return false, nil }
+	if err != nil { return false, err }
+	defer unlock()
+
+	if cur, ok := l.Current(); ok && cur.Holder != l.holder { return false, nil }
+
+	data, _ := json.Marshal(LeaseInfo{Holder: l.holder, URL: l.url, Expires: time.Now().Add(l.TTL).UnixMilli()})
+	tmp := l.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil { return false, err }
+	if err := os.Rename(tmp, l.path); err != nil { return false, err }
+	return true, nil
+}
+
+// Release libera el lease si es propio (al detener el líder, el standby no espera al vencimiento)
+func (l *Lease) Release() {
+	unlock, err := l.lock()
+	if err != nil { return }
+	defer unlock()
+	if cur, ok := l.Current(); ok && cur.Holder == l.holder { os.Remove(l.path) }
+}
+
+// AwaitLeadership bloquea hasta tomar el lease, reintentando cada TTL/3
+func (l *Lease) AwaitLeadership() {
+	announced := false
+	for {
+		ok, err := l.TryAcquire()
+		if ok { return }
+		if err != nil {
+			log.Printf("[HA] Error leyendo el lease: %v", err)
+		} else if cur, held := l.Current(); held && !announced {
+			log.Printf("[HA] Standby: el líder es %s (%s)", cur.URL, cur.Holder)
+			announced = true
+		}
+		time.Sleep(l.TTL / 3)
+	}
+}
+
+// KeepAlive renueva el lease cada TTL/3. Si otro Master lo tomó, o pasan 2/3 del TTL sin poder renovarlo,
+// llama a lost. El margen asegura que este proceso se rinda antes de que el lease venza y el standby pueda
+// tomarlo; lost debe detener las escrituras al estado compartido antes de volver.
+func (l *Lease) KeepAlive(lost func()) {
+	renewed := time.Now()
+	margin := l.TTL * 2 / 3
+	for {
+		wait := l.TTL / 3
+		if left := time.Until(renewed.Add(margin)); left < wait { wait = left }
+		time.Sleep(wait)
+
+		attempt := time.Now() // El vencimiento que escribe TryAcquire es posterior a este instante
+		ok, err := l.TryAcquire()
+		switch {
+		case ok:
+			renewed = attempt
+		case err == nil:
+			if cur, held := l.Current(); held && cur.Holder != l.holder {
+				log.Printf("[HA] El lease pasó a %s (%s)", cur.URL, cur.Holder)
+				lost()
+				return
+			}
+		default:
+			log.Printf("[HA] Error renovando el lease: %v", err)
+		}
+		if time.Since(renewed) >= margin {
+			log.Printf("[HA] No se pudo renovar el lease en %s: se cede antes de que venza", margin)
+			lost()
+			return
+		}
+	}
+}
+
+// LeaderGate atiende con el handler del Master solo cuando este es el líder. Mientras tanto
+// responde 503 marcado como standby, con la URL del líder actual para que lo sigan.
+type LeaderGate struct {
+	lease  *Lease // nil = sin alta disponibilidad
+	active atomic.Value
+}
+
+func NewLeaderGate(lease *Lease) *LeaderGate {
+	return &LeaderGate{lease: lease}
+}
+
+// Activate empieza a atender con h (al tomar el liderazgo)
+func (g *LeaderGate) Activate(h http.Handler) {
+	g.active.Store(h)
+}
+
+func (g *LeaderGate) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h, ok := g.active.Load().(http.Handler); ok {
+		h.ServeHTTP(w, r)
+		return
+	}
+	w.Header().Set(common.MasterRoleHeader, common.MasterRoleStandby)
+	if g.lease != nil {
+		if cur, ok := g.lease.Current(); ok { w.Header().Set(common.LeaderHeader, cur.URL) }
+	}
+	http.Error(w, "Master en standby", http.StatusServiceUnavailable)
+}
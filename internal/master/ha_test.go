@@ -0,0 +1,111 @@
+package master
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"mini-spark/internal/common"
+)
+
+func TestLease_Election(t *testing.T) {
+	dir := t.TempDir()
+	ttl := 300 * time.Millisecond
+	a := NewLease(dir, "master-a", "http://a:8080", ttl)
+	b := NewLease(dir, "master-b", "http://b:8080", ttl)
+
+	if ok, err := a.TryAcquire(); !ok || err != nil { t.Fatalf("A debió tomar el lease libre: %v %v", ok, err) }
+	if ok, _ := b.TryAcquire(); ok { t.Fatalf("B no puede tomar un lease vigente de A") }
+	if cur, ok := b.Current(); !ok || cur.URL != "http://a:8080" {
+		t.Errorf("B debe ver a A como líder: %+v", cur)
+	}
+	if ok, _ := a.TryAcquire(); !ok { t.Errorf("A debe poder renovar su propio lease") }
+
+	// A deja de renovar: al vencer, B toma el liderazgo y A ya no puede recuperarlo
+	time.Sleep(ttl + 50*time.Millisecond)
+	if ok, _ := b.TryAcquire(); !ok { t.Fatalf("B debió tomar el lease vencido") }
+	if ok, _ := a.TryAcquire(); ok { t.Errorf("A no puede volver a tomar el lease de B") }
+
+	// B se detiene ordenadamente: A no espera al vencimiento
+	b.Release()
+	if ok, _ := a.TryAcquire(); !ok { t.Errorf("A debió tomar el lease liberado") }
+
+	// Un candado huérfano (Master muerto a mitad de escribir) no bloquea para siempre
+	lockPath := a.path + ".lock"
+	os.WriteFile(lockPath, nil, 0644)
+	old := time.Now().Add(-time.Minute)
+	os.Chtimes(lockPath, old, old)
+	if ok, err := a.TryAcquire(); !ok || err != nil { t.Errorf("El candado viejo debió descartarse: %v %v", ok, err) }
+}
+
+func TestLease_KeepAliveYieldsBeforeExpiry(t *testing.T) {
+	dir := t.TempDir()
+	ttl := 600 * time.Millisecond
+	a := NewLease(dir, "master-a", "http://a:8080", ttl)
+	if ok, err := a.TryAcquire(); !ok || err != nil { t.Fatalf("A debió tomar el lease libre: %v %v", ok, err) }
+	cur, _ := a.Current()
+
+	// Un candado reciente que nadie suelta: A no puede renovar y debe ceder antes del vencimiento
+	os.WriteFile(a.path+".lock", nil, 0644)
+	lost := make(chan time.Time, 1)
+	go a.KeepAlive(func() { lost <- time.Now() })
+
+	select {
+	case at := <-lost:
+		if at.UnixMilli() >= cur.Expires {
+			t.Errorf("A cedió el liderazgo recién al vencer el lease (%d >= %d): el standby ya podía tomarlo", at.UnixMilli(), cur.Expires)
+		}
+	case <-time.After(2 * ttl):
+		t.Fatalf("KeepAlive no cedió el liderazgo sin poder renovar")
+	}
+}
+
+func TestLeaderGate_ClientsFollowLeader(t *testing.T) {
+	leader := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "leader")
+	}))
+	defer leader.Close()
+
+	dir := t.TempDir()
+	if ok, _ := NewLease(dir, "master-a", leader.URL, time.Minute).TryAcquire(); !ok { t.Fatalf("No se pudo tomar el lease") }
+	standby := httptest.NewServer(NewLeaderGate(NewLease(dir, "master-b", "http://standby", time.Minute)))
+	defer standby.Close()
+
+	get := func(base string) (*http.Request, error) { return http.NewRequest(http.MethodGet, base+"/api/v1/pools", nil) }
+
+	// El standby responde 503 con la URL del líder
+	resp, err := http.Get(standby.URL + "/api/v1/pools")
+	if err != nil { t.Fatalf("Error: %v", err) }
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable || resp.Header.Get(common.LeaderHeader) != leader.URL {
+		t.Fatalf("Respuesta del standby: %d, líder %q", resp.StatusCode, resp.Header.Get(common.LeaderHeader))
+	}
+
+	// Un cliente configurado con el standby y un Master caído llega al líder por la pista
+	masters := common.NewMasterList(standby.URL + ",http://127.0.0.1:1")
+	resp, err = masters.Do(get)
+	if err != nil { t.Fatalf("No se llegó al líder: %v", err) }
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "leader" || masters.Leader() != leader.URL {
+		t.Errorf("Se esperaba seguir al líder %s, respondió %q (líder conocido %s)", leader.URL, body, masters.Leader())
+	}
+
+	// Sin ningún Master líder, Do devuelve error
+	waiting := httptest.NewServer(NewLeaderGate(nil))
+	defer waiting.Close()
+	if resp, err := common.NewMasterList(waiting.URL).Do(get); err == nil {
+		resp.Body.Close()
+		t.Errorf("Se esperaba error sin Master líder")
+	}
+
+	// El gate activado atiende con el handler del Master
+	gate := NewLeaderGate(nil)
+	gate.Activate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusTeapot) }))
+	rec := httptest.NewRecorder()
+	gate.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusTeapot { t.Errorf("El gate activado no delegó: %d", rec.Code) }
+}
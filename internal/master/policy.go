@@ -0,0 +1,218 @@
+package master
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+
+	"mini-spark/internal/common"
+)
+
+// ==========================================
+// POLÍTICAS DE PLANIFICACIÓN
+// ==========================================
+// El Scheduler decide CUÁNDO asignar (cada tick, con la cola y los workers vivos); la política decide
+// QUÉ tarea va a QUÉ worker. Se elige con el flag -policy del Master.
+
+// Capacidad de los workers
+const (
+	DefaultWorkerSlots  = 4   // Slots de un worker que no anuncia los suyos
+	MemoryHighWatermark = 0.9 // Sobre esta fracción de su memoria el worker no recibe tareas nuevas
+)
+
+// WorkerSnapshot es la foto de un worker vivo al momento de asignar
+type WorkerSnapshot struct {
+	Worker  common.Heartbeat
+	Slots   int           // Tareas simultáneas que admite
+	Running []common.Task // Intentos que el Scheduler le asignó (incluidas copias especulativas)
+}
+
+// Used devuelve los slots ocupados: los intentos asignados o los que reporta el worker, lo que sea mayor
+// (el heartbeat llega con retraso y el worker puede seguir con intentos que el Master ya descartó).
+func (w *WorkerSnapshot) Used() int {
+	return max(len(w.Running), w.Worker.ActiveTasks)
+}
+
+// Free devuelve los slots libres del worker (0 si está sin memoria)
+func (w *WorkerSnapshot) Free() int {
+	if w.Worker.MemCapacityMB > 0 && float64(w.Worker.MemUsageMB) >= MemoryHighWatermark*float64(w.Worker.MemCapacityMB) {
+		return 0
+	}
+	return w.Slots - w.Used()
+}
+
+// Assignment asigna la tarea Pending[TaskIndex] al worker WorkerID
+type Assignment struct {
+	TaskIndex int
+	WorkerID  string
+}
+
+// SchedulingPolicy reparte las tareas pendientes (en orden de cola) entre los workers.
+// Las tareas que no asigna siguen en la cola para el próximo tick. No debe asignar más tareas
+// a un worker que sus slots libres (el Scheduler descarta las que no caben).
+type SchedulingPolicy interface {
+	Name() string
+	Assign(pending []common.Task, workers []WorkerSnapshot) []Assignment
+}
+
+// Políticas disponibles
+const (
+	PolicyLeastLoaded = "least-loaded"
+	PolicyRoundRobin  = "round-robin"
+	PolicyLocality    = "locality"
+	PolicyFairShare   = "fair-share"
+)
+
+// NewSchedulingPolicy crea la política por nombre
+func NewSchedulingPolicy(name string) (SchedulingPolicy, error) {
+	switch name {
+	case PolicyLeastLoaded, "":
+		return LeastLoadedPolicy{}, nil
+	case PolicyRoundRobin:
+		return &RoundRobinPolicy{}, nil
+	case PolicyLocality:
+		return LocalityPolicy{}, nil
+	case PolicyFairShare:
+		return FairSharePolicy{}, nil
+	}
+	return nil, fmt.Errorf("política de planificación desconocida: %q (opciones: %s, %s, %s, %s)",
+		name, PolicyLeastLoaded, PolicyRoundRobin, PolicyLocality, PolicyFairShare)
+}
+
+// leastLoaded devuelve el índice del worker con menor fracción de slots ocupados y algún slot libre,
+// sin contar exclude; -1 si todos están llenos. A igual carga desempata por ID (estable).
+func leastLoaded(workers []WorkerSnapshot, exclude string) int {
+	best := -1
+	for i := range workers {
+		w := &workers[i]
+		if w.Worker.WorkerID == exclude || w.Free() <= 0 { continue }
+		if best < 0 { best = i; continue }
+		b := &workers[best]
+		// used/slots < b.used/b.slots, sin divisiones
+		x, y := w.Used()*b.Slots, b.Used()*w.Slots
+		if x < y || (x == y && w.Worker.WorkerID < b.Worker.WorkerID) { best = i }
+	}
+	return best
+}
+
+// place registra la asignación en la foto del worker (para las siguientes decisiones del mismo tick)
+func place(workers []WorkerSnapshot, w int, pending []common.Task, i int, out []Assignment) []Assignment {
+	workers[w].Running = append(workers[w].Running, pending[i])
+	return append(out, Assignment{TaskIndex: i, WorkerID: workers[w].Worker.WorkerID})
+}
+
+// LeastLoadedPolicy asigna cada tarea, en orden de cola, al worker menos cargado
+type LeastLoadedPolicy struct{}
+
+func (LeastLoadedPolicy) Name() string { return PolicyLeastLoaded }
+
+func (LeastLoadedPolicy) Assign(pending []common.Task, workers []WorkerSnapshot) []Assignment {
+	var out []Assignment
+	for i := range pending {
+		w := leastLoaded(workers, "")
+		if w < 0 { break }
+		out = place(workers, w, pending, i, out)
+	}
+	return out
+}
+
+// RoundRobinPolicy rota entre los workers con slots libres, sin mirar su carga
+type RoundRobinPolicy struct {
+	next int
+}
+
+func (*RoundRobinPolicy) Name() string { return PolicyRoundRobin }
+
+func (p *RoundRobinPolicy) Assign(pending []common.Task, workers []WorkerSnapshot) []Assignment {
+	var out []Assignment
+	for i := range pending {
+		w := -1
+		for range workers {
+			candidate := p.next % len(workers)
+			p.next++
+			if workers[candidate].Free() > 0 { w = candidate; break }
+		}
+		if w < 0 { break }
+		out = place(workers, w, pending, i, out)
+	}
+	return out
+}
+
+// LocalityPolicy lleva cada tarea que lee shuffle al worker que sirve más de sus entradas, así parte
+// de la descarga es local. Las tareas sin entradas en ningún worker con slots van al menos cargado.
+type LocalityPolicy struct{}
+
+func (LocalityPolicy) Name() string { return PolicyLocality }
+
+// shuffleHosts cuenta cuántas entradas de shuffle de la tarea sirve cada worker (http://<worker>/shuffle?...)
+func shuffleHosts(task common.Task) map[string]int {
+	hosts := make(map[string]int)
+	for _, raw := range task.InputPartition.ShuffleMap {
+		if u, err := url.Parse(raw); err == nil { hosts[u.Host]++ }
+	}
+	return hosts
+}
+
+func (LocalityPolicy) Assign(pending []common.Task, workers []WorkerSnapshot) []Assignment {
+	var out []Assignment
+	for i, task := range pending {
+		hosts := shuffleHosts(task)
+		w, local := -1, 0
+		for j := range workers {
+			if n := hosts[workers[j].Worker.WorkerID]; n > local && workers[j].Free() > 0 { w, local = j, n }
+		}
+		if w < 0 { w = leastLoaded(workers, "") }
+		if w < 0 { break }
+		out = place(workers, w, pending, i, out)
+	}
+	return out
+}
+
+// FairSharePolicy reparte los slots por igual entre los Jobs: cada slot libre va al Job con menos
+// tareas en curso (su primera tarea en cola), así un Job grande no acapara el clúster.
+// Solo compite con los Jobs del mismo pool y prioridad que la primera tarea sin asignar de la cola,
+// para no deshacer el orden entre pools y prioridades que arma el Scheduler.
+type FairSharePolicy struct{}
+
+func (FairSharePolicy) Name() string { return PolicyFairShare }
+
+func (FairSharePolicy) Assign(pending []common.Task, workers []WorkerSnapshot) []Assignment {
+	running := make(map[string]int)
+	for _, w := range workers {
+		for _, task := range w.Running { running[task.JobID]++ }
+	}
+
+	// Cola de cada Job en orden de llegada
+	queues := make(map[string][]int)
+	var jobs []string
+	for i, task := range pending {
+		if _, seen := queues[task.JobID]; !seen { jobs = append(jobs, task.JobID) }
+		queues[task.JobID] = append(queues[task.JobID], i)
+	}
+
+	var out []Assignment
+	taken := make([]bool, len(pending))
+	next := 0 // Primera tarea sin asignar de la cola
+	for {
+		w := leastLoaded(workers, "")
+		if w < 0 { break }
+		for next < len(pending) && taken[next] { next++ }
+		if next == len(pending) { break }
+
+		head := pending[next]
+		job := head.JobID
+		for _, id := range jobs {
+			if len(queues[id]) == 0 { continue }
+			first := pending[queues[id][0]]
+			if taskPool(first) == taskPool(head) && first.Priority == head.Priority && running[id] < running[job] { job = id }
+		}
+
+		i := queues[job][0]
+		queues[job] = queues[job][1:]
+		taken[i] = true
+		running[job]++
+		out = place(workers, w, pending, i, out)
+	}
+	sort.Slice(out, func(a, b int) bool { return out[a].TaskIndex < out[b].TaskIndex })
+	return out
+}
@@ -0,0 +1,128 @@
+package master
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"mini-spark/internal/common"
+	"mini-spark/internal/storage"
+)
+
+// snapshot crea la foto de un worker con n tareas ya asignadas
+func snapshot(id string, slots, running int) WorkerSnapshot {
+	w := WorkerSnapshot{Worker: common.Heartbeat{WorkerID: id}, Slots: slots}
+	for i := 0; i < running; i++ {
+		w.Running = append(w.Running, common.Task{TaskID: fmt.Sprintf("%s-prev-%d", id, i), JobID: "job-prev"})
+	}
+	return w
+}
+
+func tasksOf(jobID string, n int) []common.Task {
+	var tasks []common.Task
+	for i := 0; i < n; i++ {
+		tasks = append(tasks, common.Task{TaskID: fmt.Sprintf("%s-%d", jobID, i), JobID: jobID})
+	}
+	return tasks
+}
+
+// placement resume las asignaciones como TaskID -> WorkerID
+func placement(pending []common.Task, assignments []Assignment) map[string]string {
+	out := make(map[string]string)
+	for _, a := range assignments { out[pending[a.TaskIndex].TaskID] = a.WorkerID }
+	return out
+}
+
+func TestSchedulingPolicies(t *testing.T) {
+	t.Run("LeastLoaded respeta slots y prefiere el menos cargado", func(t *testing.T) {
+		pending := tasksOf("job", 4)
+		workers := []WorkerSnapshot{snapshot("w1", 4, 2), snapshot("w2", 2, 0)}
+		got := placement(pending, LeastLoadedPolicy{}.Assign(pending, workers))
+		want := map[string]string{"job-0": "w2", "job-1": "w1", "job-2": "w2", "job-3": "w1"}
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("Obtenido %v, esperado %v", got, want)
+		}
+	})
+
+	t.Run("RoundRobin rota y salta workers llenos", func(t *testing.T) {
+		pending := tasksOf("job", 4)
+		workers := []WorkerSnapshot{snapshot("w1", 1, 0), snapshot("w2", 4, 0), snapshot("w3", 2, 2)}
+		got := placement(pending, (&RoundRobinPolicy{}).Assign(pending, workers))
+		want := map[string]string{"job-0": "w1", "job-1": "w2", "job-2": "w2", "job-3": "w2"}
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("Obtenido %v, esperado %v", got, want)
+		}
+	})
+
+	t.Run("Locality lleva la tarea al worker con sus entradas", func(t *testing.T) {
+		pending := []common.Task{
+			{TaskID: "reduce-0", JobID: "job", InputPartition: common.TaskInput{ShuffleMap: map[string]string{
+				"a": "http://w2/shuffle?path=/x", "b": "http://w2/shuffle?path=/y", "c": "http://w1/shuffle?path=/z",
+			}}},
+			{TaskID: "map-0", JobID: "job"}, // Sin shuffle: al menos cargado
+		}
+		workers := []WorkerSnapshot{snapshot("w1", 4, 0), snapshot("w2", 4, 3)}
+		got := placement(pending, LocalityPolicy{}.Assign(pending, workers))
+		if got["reduce-0"] != "w2" || got["map-0"] != "w1" {
+			t.Errorf("Asignación sin localidad: %v", got)
+		}
+	})
+
+	t.Run("FairShare reparte los slots entre Jobs", func(t *testing.T) {
+		// El Job grande llegó primero y ya tiene tareas en curso
+		pending := append(tasksOf("big", 6), tasksOf("small", 2)...)
+		workers := []WorkerSnapshot{snapshot("w1", 4, 0)}
+		workers[0].Running = []common.Task{{TaskID: "big-prev", JobID: "big"}}
+		perJob := make(map[string]int)
+		for _, a := range (FairSharePolicy{}).Assign(pending, workers) { perJob[pending[a.TaskIndex].JobID]++ }
+		if perJob["small"] != 2 || perJob["big"] != 1 {
+			t.Errorf("Reparto injusto de 3 slots libres: %v", perJob)
+		}
+	})
+
+	t.Run("Política desconocida", func(t *testing.T) {
+		if _, err := NewSchedulingPolicy("random"); err == nil {
+			t.Errorf("Se esperaba error para una política desconocida")
+		}
+		for _, name := range []string{PolicyLeastLoaded, PolicyRoundRobin, PolicyLocality, PolicyFairShare} {
+			if p, err := NewSchedulingPolicy(name); err != nil || p.Name() != name {
+				t.Errorf("Política %s mal construida: %v", name, err)
+			}
+		}
+	})
+}
+
+// greedyPolicy manda todo al primer worker, sin mirar sus slots
+type greedyPolicy struct{}
+
+func (greedyPolicy) Name() string { return "greedy" }
+
+func (greedyPolicy) Assign(pending []common.Task, workers []WorkerSnapshot) []Assignment {
+	var out []Assignment
+	for i := range pending { out = append(out, Assignment{TaskIndex: i, WorkerID: workers[0].Worker.WorkerID}) }
+	return out
+}
+
+func TestScheduler_PolicyCannotOversubscribe(t *testing.T) {
+	registry := NewWorkerRegistry()
+	scheduler := NewScheduler(registry, storage.NewMemoryStore())
+	scheduler.SetPolicy(greedyPolicy{})
+	workerServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer workerServer.Close()
+	registry.UpdateHeartbeat(common.Heartbeat{WorkerID: "w1", Address: workerServer.Listener.Addr().String(), Slots: 2})
+
+	scheduler.mu.Lock()
+	scheduler.PendingTasks = tasksOf("job", 5)
+	scheduler.mu.Unlock()
+	scheduler.assignPendingTasks()
+
+	scheduler.mu.Lock()
+	defer scheduler.mu.Unlock()
+	if len(scheduler.RunningTasks) > 2 {
+		t.Errorf("La política superó los slots del worker: %d en curso", len(scheduler.RunningTasks))
+	}
+	if len(scheduler.PendingTasks) < 3 || scheduler.PendingTasks[0].TaskID != "job-2" {
+		t.Errorf("Las tareas descartadas deben seguir en cola en orden: %v", scheduler.PendingTasks)
+	}
+}
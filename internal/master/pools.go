@@ -0,0 +1,152 @@
+package master
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"mini-spark/internal/common"
+)
+
+// ==========================================
+// POOLS Y PRIORIDADES
+// ==========================================
+// Cada Job declara un pool (default "default") y una prioridad. Antes de pasar la cola a la política,
+// el Scheduler la ordena: los slots se reparten entre pools en proporción a su peso (el pool con menos
+// tareas en curso por unidad de peso va primero) y dentro de cada pool salen antes los Jobs de mayor
+// prioridad; a igual prioridad se respeta el orden de llegada. Así un Job chico en su propio pool no
+// espera a que termine un benchmark enviado antes.
+
+// DefaultPoolWeight es el peso de un pool que no aparece en la configuración (-pools)
+const DefaultPoolWeight = 1
+
+// PoolWeights es el peso de cada pool configurado (nombre -> peso >= 1)
+type PoolWeights map[string]int
+
+// ParsePoolWeights lee la configuración de pools "nombre=peso,nombre=peso" (vacío = sin pools configurados)
+func ParsePoolWeights(spec string) (PoolWeights, error) {
+	weights := make(PoolWeights)
+	if strings.TrimSpace(spec) == "" { return weights, nil }
+	for _, entry := range strings.Split(spec, ",") {
+		name, raw, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" { return nil, fmt.Errorf("pool mal formado: %q (se esperaba nombre=peso)", entry) }
+		weight, err := strconv.Atoi(strings.TrimSpace(raw))
+		if err != nil || weight < 1 { return nil, fmt.Errorf("peso inválido para el pool %s: %q (debe ser un entero >= 1)", name, raw) }
+		if _, dup := weights[name]; dup { return nil, fmt.Errorf("pool duplicado: %s", name) }
+		weights[name] = weight
+	}
+	return weights, nil
+}
+
+// Weight devuelve el peso del pool (DefaultPoolWeight si no está configurado)
+func (p PoolWeights) Weight(pool string) int {
+	if w, ok := p[pool]; ok { return w }
+	return DefaultPoolWeight
+}
+
+// taskPool devuelve el pool de una tarea (las planificadas sin pool van al default)
+func taskPool(task common.Task) string {
+	if task.Pool == "" { return common.DefaultPool }
+	return task.Pool
+}
+
+// fairOrder devuelve los índices de la cola en el orden en que deben recibir slots.
+// running cuenta las tareas en curso de cada pool (cada tarea elegida suma a su pool para la siguiente).
+func fairOrder(pending []common.Task, running map[string]int, weights PoolWeights) []int {
+	// Cola de cada pool: mayor prioridad primero, a igual prioridad en orden de llegada
+	queues := make(map[string][]int)
+	var pools []string
+	for i, task := range pending {
+		pool := taskPool(task)
+		if _, seen := queues[pool]; !seen { pools = append(pools, pool) }
+		queues[pool] = append(queues[pool], i)
+	}
+	for _, q := range queues {
+		sort.SliceStable(q, func(a, b int) bool { return pending[q[a]].Priority > pending[q[b]].Priority })
+	}
+	sort.Strings(pools)
+
+	load := make(map[string]int)
+	for pool, n := range running { load[pool] = n }
+
+	order := make([]int, 0, len(pending))
+	for len(order) < len(pending) {
+		best := ""
+		for _, pool := range pools {
+			if len(queues[pool]) == 0 { continue }
+			if best == "" { best = pool; continue }
+			// load/weight < best.load/best.weight, sin divisiones (a igual cuota gana el de más peso)
+			x, y := load[pool]*weights.Weight(best), load[best]*weights.Weight(pool)
+			if x < y || (x == y && weights.Weight(pool) > weights.Weight(best)) { best = pool }
+		}
+		order = append(order, queues[best][0])
+		queues[best] = queues[best][1:]
+		load[best]++
+	}
+	return order
+}
+
+// PoolStatus es el estado de un pool expuesto en GET /api/v1/pools
+type PoolStatus struct {
+	Pool    string `json:"pool"`
+	Weight  int    `json:"weight"`
+	Pending int    `json:"pending"` // Tareas en cola (profundidad del pool)
+	Running int    `json:"running"` // Intentos en curso (incluidas copias especulativas)
+	Jobs    int    `json:"jobs"`    // Jobs con tareas en cola o en curso
+}
+
+// SetPoolWeights cambia los pesos de los pools (el bucle de control ya puede estar corriendo)
+func (s *Scheduler) SetPoolWeights(weights PoolWeights) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.PoolWeights = weights
+}
+
+// runningByPool cuenta los intentos en curso de cada pool (incluidas copias especulativas)
+func (s *Scheduler) runningByPool() map[string]int {
+	running := make(map[string]int)
+	for _, task := range s.RunningTasks { running[taskPool(task)]++ }
+	for _, spec := range s.Speculative { running[taskPool(spec.Task)]++ }
+	return running
+}
+
+// PoolStats devuelve el estado de los pools configurados y de los que tienen tareas, ordenados por nombre
+func (s *Scheduler) PoolStats() []PoolStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := make(map[string]*PoolStatus)
+	jobs := make(map[string]map[string]bool)
+	get := func(pool string) *PoolStatus {
+		if st, ok := stats[pool]; ok { return st }
+		stats[pool] = &PoolStatus{Pool: pool, Weight: s.PoolWeights.Weight(pool)}
+		jobs[pool] = make(map[string]bool)
+		return stats[pool]
+	}
+	get(common.DefaultPool)
+	for pool := range s.PoolWeights { get(pool) }
+	for _, task := range s.PendingTasks {
+		get(taskPool(task)).Pending++
+		jobs[taskPool(task)][task.JobID] = true
+	}
+	for pool, n := range s.runningByPool() { get(pool).Running += n }
+	for _, task := range s.RunningTasks { jobs[taskPool(task)][task.JobID] = true }
+
+	out := make([]PoolStatus, 0, len(stats))
+	for pool, st := range stats {
+		st.Jobs = len(jobs[pool])
+		out = append(out, *st)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Pool < out[j].Pool })
+	return out
+}
+
+// GET /api/v1/pools
+func (s *MasterServer) HandleGetPools(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.Scheduler.PoolStats())
+}
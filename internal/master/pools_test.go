@@ -0,0 +1,124 @@
+package master
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"mini-spark/internal/common"
+	"mini-spark/internal/storage"
+)
+
+// pooled asigna pool y prioridad a las tareas de un Job
+func pooled(tasks []common.Task, pool string, priority int) []common.Task {
+	for i := range tasks {
+		tasks[i].Pool = pool
+		tasks[i].Priority = priority
+	}
+	return tasks
+}
+
+func TestParsePoolWeights(t *testing.T) {
+	weights, err := ParsePoolWeights("batch=1, interactive=3")
+	if err != nil { t.Fatalf("Error inesperado: %v", err) }
+	if weights.Weight("interactive") != 3 || weights.Weight("batch") != 1 || weights.Weight("otro") != DefaultPoolWeight {
+		t.Errorf("Pesos mal leídos: %v", weights)
+	}
+	for _, spec := range []string{"batch", "batch=0", "=2", "batch=x", "a=1,a=2"} {
+		if _, err := ParsePoolWeights(spec); err == nil {
+			t.Errorf("Se esperaba error para %q", spec)
+		}
+	}
+}
+
+func TestFairOrder(t *testing.T) {
+	jobsOf := func(pending []common.Task, order []int) []string {
+		var out []string
+		for _, i := range order { out = append(out, pending[i].JobID) }
+		return out
+	}
+
+	t.Run("Reparte entre pools según su peso", func(t *testing.T) {
+		pending := append(pooled(tasksOf("big", 4), "batch", 0), pooled(tasksOf("small", 4), "interactive", 0)...)
+		order := fairOrder(pending, nil, PoolWeights{"interactive": 3})
+		perJob := make(map[string]int)
+		for _, job := range jobsOf(pending, order)[:4] { perJob[job]++ }
+		if perJob["small"] != 3 || perJob["big"] != 1 {
+			t.Errorf("Reparto de los primeros 4 slots (peso 3 a 1): %v", perJob)
+		}
+	})
+
+	t.Run("Cuenta las tareas en curso de cada pool", func(t *testing.T) {
+		pending := append(pooled(tasksOf("big", 2), "batch", 0), pooled(tasksOf("small", 2), "interactive", 0)...)
+		order := fairOrder(pending, map[string]int{"interactive": 3}, nil)
+		got := fmt.Sprint(jobsOf(pending, order))
+		if got != "[big big small small]" {
+			t.Errorf("El pool con más tareas en curso debió esperar: %s", got)
+		}
+	})
+
+	t.Run("Dentro del pool sale antes la mayor prioridad", func(t *testing.T) {
+		pending := append(pooled(tasksOf("low", 2), "", 0), pooled(tasksOf("high", 1), common.DefaultPool, 5)...)
+		order := fairOrder(pending, nil, nil)
+		if got := fmt.Sprint(jobsOf(pending, order)); got != "[high low low]" {
+			t.Errorf("Orden por prioridad: %s", got)
+		}
+		if pending[order[1]].TaskID != "low-0" {
+			t.Errorf("A igual prioridad se debe respetar el orden de llegada: %v", order)
+		}
+	})
+
+	t.Run("FairShare no deshace la prioridad", func(t *testing.T) {
+		pending := append(pooled(tasksOf("low", 2), "", 0), pooled(tasksOf("high", 2), "", 5)...)
+		queue := make([]common.Task, len(pending))
+		for i, idx := range fairOrder(pending, nil, nil) { queue[i] = pending[idx] }
+		workers := []WorkerSnapshot{snapshot("w1", 2, 0)}
+		for _, a := range (FairSharePolicy{}).Assign(queue, workers) {
+			if queue[a.TaskIndex].JobID != "high" {
+				t.Errorf("Con 2 slots solo debía correr el Job prioritario: %s", queue[a.TaskIndex].TaskID)
+			}
+		}
+	})
+}
+
+func TestScheduler_PoolsSmallJobNotStarved(t *testing.T) {
+	registry := NewWorkerRegistry()
+	store := storage.NewMemoryStore()
+	scheduler := NewScheduler(registry, store)
+	scheduler.SetPoolWeights(PoolWeights{"interactive": 2})
+	workerServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer workerServer.Close()
+	registry.UpdateHeartbeat(common.Heartbeat{WorkerID: "w1", Address: workerServer.Listener.Addr().String(), Slots: 2})
+
+	// El benchmark llegó primero y llena la cola; el Job interactivo llega después
+	scheduler.mu.Lock()
+	scheduler.PendingTasks = append(pooled(tasksOf("bench", 20), "batch", 0), pooled(tasksOf("query", 1), "interactive", 0)...)
+	scheduler.mu.Unlock()
+	scheduler.assignPendingTasks()
+
+	scheduler.mu.Lock()
+	_, started := scheduler.RunningTasks["query-0"]
+	scheduler.mu.Unlock()
+	if !started {
+		t.Fatalf("La tarea del Job interactivo debió despacharse en el primer tick")
+	}
+
+	server := &MasterServer{Scheduler: scheduler, Registry: registry, Store: store}
+	rec := httptest.NewRecorder()
+	server.HandleGetPools(rec, httptest.NewRequest(http.MethodGet, "/api/v1/pools", nil))
+	var pools []PoolStatus
+	if err := json.NewDecoder(rec.Body).Decode(&pools); err != nil { t.Fatalf("Respuesta inválida: %v", err) }
+	byName := make(map[string]PoolStatus)
+	for _, p := range pools { byName[p.Pool] = p }
+	if byName["batch"].Pending != 19 || byName["batch"].Running != 1 || byName["batch"].Jobs != 1 {
+		t.Errorf("Estado del pool batch: %+v", byName["batch"])
+	}
+	if byName["interactive"].Pending != 0 || byName["interactive"].Running != 1 || byName["interactive"].Weight != 2 {
+		t.Errorf("Estado del pool interactive: %+v", byName["interactive"])
+	}
+	if _, ok := byName[common.DefaultPool]; !ok {
+		t.Errorf("El pool default siempre debe aparecer: %v", pools)
+	}
+}
@@ -50,6 +50,15 @@ func (r *WorkerRegistry) GetAliveWorkers() []common.Heartbeat {
 	return alive
 }
 
+// GetWorker devuelve el último heartbeat de un worker vivo
+func (r *WorkerRegistry) GetWorker(id string) (common.Heartbeat, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	w, ok := r.workers[id]
+	if !ok || isDead(w) { return common.Heartbeat{}, false }
+	return w, true
+}
+
 // DetectDeadWorkers identifica workers que han expirado y devuelve sus IDs.
 // Usado por el Scheduler para disparar replanificación.
 func (r *WorkerRegistry) DetectDeadWorkers() []string {
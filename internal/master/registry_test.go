@@ -37,13 +37,15 @@ func TestWorkerRegistry_HeartbeatAndDetection(t *testing.T) {
 	// 3. Simular Timeout (Forzar el timeout para el worker expirado)
 	t.Run("DetectDeadWorkers_Timeout", func(t *testing.T) {
 		expiredID := "worker-expired"
-		r.UpdateHeartbeat(common.Heartbeat{
+		// UpdateHeartbeat sobrescribe LastHeartbeat, así que se inserta directamente en el pasado
+		r.mu.Lock()
+		r.workers[expiredID] = common.Heartbeat{
 			WorkerID: expiredID,
 			Address:  "localhost:8083",
 			Status:   common.WorkerStatusIdle,
-			// Establecer LastHeartbeat en el pasado para forzar el timeout
 			LastHeartbeat: time.Now().Unix() - WorkerTimeoutSeconds - 1,
-		})
+		}
+		r.mu.Unlock()
 		
 		// Actualizar el worker vivo para que no muera
 		r.UpdateHeartbeat(common.Heartbeat{WorkerID: workerID, Address: address, Status: common.WorkerStatusIdle}) 
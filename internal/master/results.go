@@ -0,0 +1,205 @@
+package master
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"mini-spark/internal/common"
+)
+
+// ==========================================
+// LECTURA DE RESULTADOS PUBLICADOS
+// ==========================================
+
+const (
+	DefaultResultsPageSize = 100
+	MaxResultsPageSize     = 10000
+)
+
+// ResultsPage es una página de registros de la salida final de un Job
+type ResultsPage struct {
+	JobID      string            `json:"job_id"`
+	OutputPath string            `json:"output_path"`
+	Offset     int               `json:"offset"`
+	Limit      int               `json:"limit"`
+	Records    []json.RawMessage `json:"records"`
+	NextOffset int               `json:"next_offset"`
+	NextCursor string            `json:"next_cursor"` // Posición reanudable para pedir la página siguiente
+	HasMore    bool              `json:"has_more"`
+}
+
+// resultFiles lista las particiones publicadas de los directorios de salida, en orden (part-00000, part-00001...)
+func resultFiles(dirs []string) ([]string, error) {
+	var files []string
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil { return nil, err }
+		var parts []string
+		for _, e := range entries {
+			if !e.IsDir() && strings.HasPrefix(e.Name(), "part-") { parts = append(parts, filepath.Join(dir, e.Name())) }
+		}
+		sort.Strings(parts)
+		files = append(files, parts...)
+	}
+	return files, nil
+}
+
+// resultCursor es una posición reanudable dentro de la salida: índice de la partición y byte dentro de ella.
+// Viaja al cliente como "archivo:byte" (next_cursor) para que la página siguiente no relea desde el registro 0.
+type resultCursor struct {
+	File int
+	Byte int64
+}
+
+func (c resultCursor) String() string { return fmt.Sprintf("%d:%d", c.File, c.Byte) }
+
+// parseCursor interpreta un cursor "archivo:byte" devuelto en next_cursor
+func parseCursor(raw string) (resultCursor, error) {
+	f, b, ok := strings.Cut(raw, ":")
+	file, err1 := strconv.Atoi(f)
+	pos, err2 := strconv.ParseInt(b, 10, 64)
+	if !ok || err1 != nil || err2 != nil || file < 0 || pos < 0 { return resultCursor{}, fmt.Errorf("parámetro cursor inválido: %q", raw) }
+	return resultCursor{File: file, Byte: pos}, nil
+}
+
+// forEachResult recorre los registros de la salida en orden global desde start, saltando además los primeros skip.
+// fn recibe cada línea y el cursor que apunta justo después de ella; devuelve false para detener la lectura.
+func forEachResult(dirs []string, start resultCursor, skip int, fn func(line string, next resultCursor) bool) error {
+	files, err := resultFiles(dirs)
+	if err != nil { return err }
+
+	for i := start.File; i < len(files); i++ {
+		f, err := os.Open(files[i])
+		if err != nil { return err }
+		pos := int64(0)
+		if i == start.File && start.Byte > 0 {
+			if pos, err = f.Seek(start.Byte, io.SeekStart); err != nil {
+				f.Close()
+				return err
+			}
+		}
+		reader := bufio.NewReader(f)
+		for {
+			line, err := reader.ReadString('\n')
+			if line == "" && err != nil {
+				f.Close()
+				if err != io.EOF { return err }
+				break
+			}
+			pos += int64(len(line))
+			if skip > 0 {
+				skip--
+				continue
+			}
+			if !fn(strings.TrimRight(line, "\r\n"), resultCursor{File: i, Byte: pos}) {
+				f.Close()
+				return nil
+			}
+		}
+	}
+	return nil
+}
+
+// asJSON devuelve la línea tal cual si es JSON válido (salida JSONL) o como string JSON si no
+func asJSON(line string) json.RawMessage {
+	if json.Valid([]byte(line)) { return json.RawMessage(line) }
+	b, _ := json.Marshal(line)
+	return b
+}
+
+// readResultsPage lee hasta limit registros desde start (saltando skip) y devuelve el cursor de la página siguiente
+func readResultsPage(dirs []string, start resultCursor, skip, limit int) (records []json.RawMessage, next resultCursor, hasMore bool, err error) {
+	records = []json.RawMessage{}
+	next = start
+	err = forEachResult(dirs, start, skip, func(line string, after resultCursor) bool {
+		if len(records) == limit {
+			hasMore = true // Hay al menos un registro más allá de la página
+			return false
+		}
+		records = append(records, asJSON(line))
+		next = after
+		return true
+	})
+	return records, next, hasMore, err
+}
+
+// queryInt lee un parámetro entero no negativo de la URL (def si no viene)
+func queryInt(r *http.Request, name string, def int) (int, error) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" { return def, nil }
+	v, err := strconv.Atoi(raw)
+	if err != nil || v < 0 { return 0, fmt.Errorf("parámetro %s inválido: %q", name, raw) }
+	return v, nil
+}
+
+// GET /api/v1/jobs/{id}/results?offset=0&limit=100[&cursor=F:B][&format=ndjson]
+// Devuelve la salida publicada del Job paginada en JSON. Con format=ndjson la transmite tal cual,
+// un registro por línea (limit=0 = hasta el final), para descargas grandes.
+// cursor (el next_cursor de la página anterior) reanuda la lectura sin volver a recorrer la salida;
+// en ese caso offset solo se usa para numerar la página.
+func (s *MasterServer) HandleGetResults(w http.ResponseWriter, r *http.Request) {
+	jobID := r.PathValue("id")
+	job := s.Store.GetJob(jobID)
+	if job == nil { http.Error(w, "Job not found", 404); return }
+	if job.Status != common.JobStatusSucceeded {
+		http.Error(w, fmt.Sprintf("El Job %s no tiene resultados publicados (estado: %s)", jobID, job.Status), http.StatusConflict)
+		return
+	}
+
+	ndjson := r.URL.Query().Get("format") == "ndjson"
+	defLimit := DefaultResultsPageSize
+	if ndjson { defLimit = 0 }
+	offset, err := queryInt(r, "offset", 0)
+	if err != nil { http.Error(w, err.Error(), 400); return }
+	limit, err := queryInt(r, "limit", defLimit)
+	if err != nil { http.Error(w, err.Error(), 400); return }
+	start, skip := resultCursor{}, offset
+	if raw := r.URL.Query().Get("cursor"); raw != "" {
+		if start, err = parseCursor(raw); err != nil { http.Error(w, err.Error(), 400); return }
+		skip = 0
+	}
+
+	s.Scheduler.mu.Lock()
+	plan := s.Scheduler.planFor(job)
+	s.Scheduler.mu.Unlock()
+	if plan == nil { http.Error(w, "Plan del Job no disponible", 500); return }
+	dirs := sinkOutputDirs(job.Request, plan)
+
+	if ndjson {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		bw := bufio.NewWriter(w)
+		defer bw.Flush()
+		sent := 0
+		err := forEachResult(dirs, start, skip, func(line string, _ resultCursor) bool {
+			bw.WriteString(line + "\n")
+			sent++
+			return limit == 0 || sent < limit
+		})
+		if err != nil && sent == 0 { http.Error(w, err.Error(), 500) }
+		return
+	}
+
+	if limit == 0 || limit > MaxResultsPageSize { limit = MaxResultsPageSize }
+	records, next, hasMore, err := readResultsPage(dirs, start, skip, limit)
+	if err != nil { http.Error(w, "Error leyendo resultados: "+err.Error(), 500); return }
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ResultsPage{
+		JobID:      jobID,
+		OutputPath: job.Request.OutputPath,
+		Offset:     offset,
+		Limit:      limit,
+		Records:    records,
+		NextOffset: offset + len(records),
+		NextCursor: next.String(),
+		HasMore:    hasMore,
+	})
+}
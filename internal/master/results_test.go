@@ -0,0 +1,154 @@
+package master
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"mini-spark/internal/common"
+	"mini-spark/internal/storage"
+)
+
+func TestMasterAPI_GetResults(t *testing.T) {
+	store := storage.NewMemoryStore()
+	registry := NewWorkerRegistry()
+	server := &MasterServer{Scheduler: NewScheduler(registry, store), Registry: registry, Store: store}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/jobs/{id}/results", server.HandleGetResults)
+
+	// Salida publicada: 5 registros repartidos en dos particiones
+	out := t.TempDir()
+	os.WriteFile(filepath.Join(out, "part-00001"), []byte(`{"key":"d","value":"4"}`+"\n"+`{"key":"e","value":"5"}`+"\n"), 0644)
+	os.WriteFile(filepath.Join(out, "part-00000"), []byte(`{"key":"a","value":"1"}`+"\n"+`{"key":"b","value":"2"}`+"\n"+"texto plano\n"), 0644)
+	os.WriteFile(filepath.Join(out, common.SuccessMarker), nil, 0644)
+
+	job := common.JobRequest{JobID: "job-res", OutputPath: out, NumPartitions: 1, DAG: common.DAG{Nodes: []common.OperationNode{
+		{ID: "m", Type: common.OpTypeMap, UDFName: "map_wordcount"},
+		{ID: "r", Type: common.OpTypeReduceByKey, UDFName: "reduce_sum"},
+	}, Edges: [][]string{{"m", "r"}}}}
+	store.CreateJob(&job)
+	store.CreateJob(&common.JobRequest{JobID: "job-running"})
+	store.UpdateJobStatus("job-running", common.JobStatusRunning)
+
+	get := func(url string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest("GET", url, nil))
+		return rr
+	}
+
+	// Antes de SUCCEEDED no hay resultados que mostrar
+	if rr := get("/api/v1/jobs/job-res/results"); rr.Code != http.StatusConflict {
+		t.Errorf("Un Job sin terminar debe responder 409, obtuvo %d", rr.Code)
+	}
+	store.UpdateJobStatus("job-res", common.JobStatusSucceeded)
+
+	tests := []struct {
+		name     string
+		url      string
+		code     int
+		expected []string
+		hasMore  bool
+	}{
+		{"Primera_Pagina", "/api/v1/jobs/job-res/results?limit=2", 200, []string{`{"key":"a","value":"1"}`, `{"key":"b","value":"2"}`}, true},
+		{"Pagina_Con_Texto", "/api/v1/jobs/job-res/results?offset=2&limit=2", 200, []string{`"texto plano"`, `{"key":"d","value":"4"}`}, true},
+		{"Ultima_Pagina", "/api/v1/jobs/job-res/results?offset=4", 200, []string{`{"key":"e","value":"5"}`}, false},
+		{"Fuera_De_Rango", "/api/v1/jobs/job-res/results?offset=50", 200, nil, false},
+		{"Limit_Invalido", "/api/v1/jobs/job-res/results?limit=-1", 400, nil, false},
+		{"Cursor_Invalido", "/api/v1/jobs/job-res/results?cursor=x", 400, nil, false},
+		{"Job_Inexistente", "/api/v1/jobs/nope/results", 404, nil, false},
+		{"Job_En_Curso", "/api/v1/jobs/job-running/results", 409, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := get(tt.url)
+			if rr.Code != tt.code {
+				t.Fatalf("Esperaba código %d, obtuvo %d: %s", tt.code, rr.Code, rr.Body.String())
+			}
+			if tt.code != 200 {
+				return
+			}
+			var page ResultsPage
+			if err := json.Unmarshal(rr.Body.Bytes(), &page); err != nil {
+				t.Fatalf("Respuesta no es JSON: %v", err)
+			}
+			var got []string
+			for _, r := range page.Records {
+				got = append(got, string(r))
+			}
+			if strings.Join(got, "|") != strings.Join(tt.expected, "|") || page.HasMore != tt.hasMore {
+				t.Errorf("Página incorrecta: records=%v has_more=%t", got, page.HasMore)
+			}
+			if page.NextOffset != page.Offset+len(page.Records) {
+				t.Errorf("next_offset incorrecto: %+v", page)
+			}
+		})
+	}
+
+	// Paginando con next_cursor se recorre la salida completa sin repetir ni perder registros
+	var all []string
+	cursor := ""
+	for pages := 0; pages < 10; pages++ {
+		rr := get("/api/v1/jobs/job-res/results?limit=2&cursor=" + cursor)
+		var page ResultsPage
+		if err := json.Unmarshal(rr.Body.Bytes(), &page); rr.Code != 200 || err != nil {
+			t.Fatalf("Página con cursor %q falló (%d): %s", cursor, rr.Code, rr.Body.String())
+		}
+		for _, r := range page.Records {
+			all = append(all, string(r))
+		}
+		cursor = page.NextCursor
+		if !page.HasMore { break }
+	}
+	if len(all) != 5 || all[2] != `"texto plano"` || all[4] != `{"key":"e","value":"5"}` {
+		t.Errorf("Recorrido por cursor incorrecto: %v", all)
+	}
+
+	// Descarga completa en NDJSON
+	rr := get("/api/v1/jobs/job-res/results?format=ndjson&offset=1")
+	if lines := strings.Split(strings.TrimSpace(rr.Body.String()), "\n"); rr.Code != 200 || len(lines) != 4 || lines[1] != "texto plano" {
+		t.Errorf("NDJSON incorrecto (%d): %q", rr.Code, rr.Body.String())
+	}
+}
+
+func TestMasterAPI_CancelJob(t *testing.T) {
+	store := storage.NewMemoryStore()
+	registry := NewWorkerRegistry()
+	server := &MasterServer{Scheduler: NewScheduler(registry, store), Registry: registry, Store: store}
+	mux := http.NewServeMux()
+	mux.HandleFunc("DELETE /api/v1/jobs/{id}", server.HandleCancelJob)
+
+	job := createTestJob("job-cancel-api")
+	job.OutputPath = t.TempDir()
+	store.CreateJob(&job)
+	server.Scheduler.SubmitJob(&job)
+
+	cases := []struct {
+		name   string
+		jobID  string
+		expect int
+	}{
+		{"Cancela un Job en curso", job.JobID, http.StatusOK},
+		{"Segunda cancelación", job.JobID, http.StatusConflict},
+		{"Job inexistente", "no-existe", http.StatusNotFound},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			mux.ServeHTTP(rr, httptest.NewRequest("DELETE", "/api/v1/jobs/"+tc.jobID, nil))
+			if rr.Code != tc.expect {
+				t.Fatalf("Se esperaba %d, obtuvo %d (%s)", tc.expect, rr.Code, rr.Body.String())
+			}
+			if tc.expect == http.StatusOK {
+				var resp map[string]string
+				json.NewDecoder(rr.Body).Decode(&resp)
+				if resp["status"] != common.JobStatusCancelled {
+					t.Errorf("Respuesta inesperada: %v", resp)
+				}
+			}
+		})
+	}
+}
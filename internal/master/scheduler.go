@@ -3,176 +3,484 @@ package master
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"maps"
 	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 	"mini-spark/internal/common"
+	"mini-spark/internal/dag"
 	"mini-spark/internal/storage"
 )
 
 type Scheduler struct {
 	mu             sync.Mutex
-	PendingTasks   []common.Task       // Cola prioritaria (FIFO simple por ahora)
+	PendingTasks   []common.Task       // Cola en orden de llegada (se ordena por pool y prioridad al asignar)
 	RunningTasks   map[string]common.Task // TaskID -> Task (Para reintentos si falla worker)
 	AssignedWorker map[string]string   // TaskID -> WorkerID
+	AwaitingTasks  map[string]common.Task // TaskID -> Task que espera que se recalculen entradas perdidas
+	Speculative    map[string]speculativeAttempt // TaskID -> copia especulativa en curso
+	dispatchedAt   map[string]time.Time // TaskID -> despacho del intento principal (para detectar rezagadas)
+	pendingMerges  map[string]common.Task // TaskID -> merge que espera el recálculo de sus partes (conserva sus reintentos)
 	
 	Registry *WorkerRegistry
-	Store    *storage.JobStore
+	Store    storage.JobStore
+	Plans    map[string]*dag.Plan // JobID -> DAG planificado en stages
+	Policy   SchedulingPolicy     // Reparto de tareas entre workers (default: least-loaded)
+	PoolWeights PoolWeights       // Peso de cada pool en el reparto de slots (sin configurar = 1)
 	
-	workerIdx int // Para Round-Robin
+	attemptSeq int // Último número de intento asignado (nombra los archivos temporales de salida)
 }
 
-func NewScheduler(r *WorkerRegistry, s *storage.JobStore) *Scheduler {
+func NewScheduler(r *WorkerRegistry, s storage.JobStore) *Scheduler {
 	sch := &Scheduler{
 		Registry:       r,
 		Store:          s,
 		PendingTasks:   make([]common.Task, 0),
 		RunningTasks:   make(map[string]common.Task),
 		AssignedWorker: make(map[string]string),
+		AwaitingTasks:  make(map[string]common.Task),
+		Speculative:    make(map[string]speculativeAttempt),
+		dispatchedAt:   make(map[string]time.Time),
+		pendingMerges:  make(map[string]common.Task),
+		Plans:          make(map[string]*dag.Plan),
+		Policy:         LeastLoadedPolicy{},
+		PoolWeights:    make(PoolWeights),
 	}
 	// Iniciar bucle de control en fondo
 	go sch.ControlLoop()
 	return sch
 }
 
-// SubmitJob convierte el DAG inicial en tareas de la primera etapa (Source Nodes)
+// SetPolicy cambia la política de planificación (el bucle de control ya puede estar corriendo)
+func (s *Scheduler) SetPolicy(policy SchedulingPolicy) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Policy = policy
+}
+
+// SubmitJob planifica el DAG del Job y encola las tareas de todos sus nodos raíz (Source Nodes)
 func (s *Scheduler) SubmitJob(job *common.JobRequest) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	
 	log.Printf("[Scheduler] Planificando Job %s (%s)", job.JobID, job.Name)
+	if job.OutputPath == "" { job.OutputPath = defaultOutputPath(job.JobID) }
+
+	plan, err := dag.NewPlan(job.DAG)
+	if err == nil && plan.Len() == 0 { err = fmt.Errorf("el DAG no tiene nodos") }
+	if err != nil {
+		log.Printf("[Scheduler] DAG inválido para Job %s: %v", job.JobID, err)
+		s.Store.UpdateJobStatus(job.JobID, common.JobStatusFailed)
+		return
+	}
+	s.Plans[job.JobID] = plan
 	s.Store.UpdateJobStatus(job.JobID, common.JobStatusRunning)
 
-	// 1. Identificar nodos raíz (sin dependencias entrantes en el DAG)
-	// Simplificación Académica: Asumimos que el nodo[0] es siempre el source.
-	// En un DAG real haríamos un análisis topológico.
-	rootNode := job.DAG.Nodes[0]
-	
-	s.enqueueStageTasks(job, rootNode, nil)
+	// Todos los stages raíz (sin aristas entrantes) arrancan en paralelo.
+	// Se planifican todos antes de encolar: si una entrada no se puede resolver, no se lanza nada.
+	var rootTasks [][]common.Task
+	for _, rootID := range plan.Roots() {
+		root, _ := plan.Stage(rootID)
+		tasks, err := stageTasks(job, plan, root, nil, nil)
+		if err != nil {
+			log.Printf("[Scheduler] Entrada inválida para Job %s: %v", job.JobID, err)
+			s.Store.UpdateJobStatus(job.JobID, common.JobStatusFailed)
+			return
+		}
+		rootTasks = append(rootTasks, tasks)
+	}
+	for i, rootID := range plan.Roots() {
+		root, _ := plan.Stage(rootID)
+		s.enqueue(root, rootTasks[i])
+	}
 }
 
-func (s *Scheduler) enqueueStageTasks(job *common.JobRequest, node common.OperationNode, prevStageReports []common.TaskReport) {
-    // 1. Determinar input (File o Shuffle)
-    inputType := common.SourceTypeFile
-    if prevStageReports != nil {
-        inputType = common.SourceTypeShuffle
-    }
+// planFor devuelve el plan del Job, reconstruyéndolo desde la definición si no está en memoria.
+func (s *Scheduler) planFor(job *storage.JobState) *dag.Plan {
+	if plan, ok := s.Plans[job.Request.JobID]; ok { return plan }
+	plan, err := dag.NewPlan(job.Request.DAG)
+	if err != nil { return nil }
+	s.Plans[job.Request.JobID] = plan
+	return plan
+}
+
+// stagePartitions devuelve el número de tareas de un nodo (o el default global del Job).
+func stagePartitions(job *common.JobRequest, node common.OperationNode) int {
+	if node.NumPartitions > 0 { return node.NumPartitions }
+	return job.NumPartitions
+}
+
+// stageOutput decide el destino de un stage: shuffle particionado si alguien consume su salida,
+// o salida final (una part-NNNNN por tarea en el output_path del Job) si es un stage terminal del DAG.
+func stageOutput(job *common.JobRequest, plan *dag.Plan, stage dag.Stage) common.TaskOutput {
+	children := plan.Children(stage.ID)
+	if len(children) == 1 {
+		// Lo lee un SORT_BY_KEY: un solo bloque ordenado por tarea (el reparto es por rangos, al leer)
+		if child, _ := plan.Stage(children[0]); child.Head().Type == common.OpTypeSortByKey {
+			order := child.Head().Order
+			if order == "" { order = common.SortAscending }
+			return common.TaskOutput{
+				Type:          common.OutputTypeShuffle,
+				Path:          filepath.Join(common.JobShuffleDir(job.JobID), stage.ID),
+				NumPartitions: 1,
+				Sort:          order,
+			}
+		}
+	}
+	if len(children) == 0 {
+		return common.TaskOutput{
+			Type:          common.OutputTypeFinal,
+			Path:          sinkOutputDir(job, plan, stage.ID),
+			NumPartitions: 1,
+		}
+	}
+	// Se particiona según el hijo más ancho; los hijos con menos tareas agrupan particiones (p % n).
+	// Un lado replicado (broadcast join marcado en el spec) se lee completo en cada tarea: le basta una.
+	numParts := 0
+	for _, childID := range children {
+		child, _ := plan.Stage(childID)
+		n := stagePartitions(job, child.Head())
+		if child.Head().Broadcast != "" || slices.Contains(plan.BroadcastParents(childID), stage.ID) { n = 1 }
+		if n > numParts { numParts = n }
+	}
+	return common.TaskOutput{
+		Type:          common.OutputTypeShuffle,
+		Path:          filepath.Join(common.JobShuffleDir(job.JobID), stage.ID),
+		NumPartitions: numParts,
+	}
+}
+
+// sourceInput construye la entrada de un nodo fuente: su propio InputSpec o, si no declara uno,
+// el InputPath global del Job.
+func sourceInput(job *common.JobRequest, node common.OperationNode) common.TaskInput {
+	input := common.TaskInput{
+		SourceType: common.SourceTypeFile,
+		Path:       job.InputPath,
+		Format:     common.InputFormatText,
+	}
+	if node.Input != nil {
+		input.Path = node.Input.Path
+		input.SkipHeader = node.Input.SkipHeader
+		input.IncludeFileName = node.Input.IncludeFileName
+		if node.Input.Format != "" { input.Format = node.Input.Format }
+	}
+	return input
+}
 
-    if node.NumPartitions == 0 { node.NumPartitions = job.NumPartitions } // Default global
+// sourcePatterns devuelve las rutas (archivos, directorios o globs) que lee un nodo fuente.
+func sourcePatterns(job *common.JobRequest, node common.OperationNode) []string {
+	if node.Input != nil { return node.Input.Patterns() }
+	return common.InputSpec{Path: job.InputPath, Paths: job.InputPaths}.Patterns()
+}
+
+// sourceSplits resuelve las rutas de un nodo fuente y reparte sus bytes entre n tareas.
+// Con un único archivo que el Master no ve, devuelve nil: cada worker lo recorrerá entero (reparto por línea).
+// Un directorio, glob o lista que no se puede resolver es un error: no hay forma de repartirlo.
+func sourceSplits(patterns []string, n int) ([][]common.FileSplit, error) {
+	files, err := dag.ResolveInputFiles(patterns)
+	if err != nil {
+		if len(patterns) == 1 && !dag.IsGlob(patterns[0]) && os.IsNotExist(err) {
+			log.Printf("[Scheduler] No se pudo calcular splits de %s (%v). Se usará lectura completa por tarea.", patterns[0], err)
+			return nil, nil
+		}
+		return nil, err
+	}
+	return dag.PackSplits(files, n), nil
+}
+
+// enqueueStageTasks planifica las tareas de un stage y las encola. Si la entrada del stage no se
+// puede resolver, el Job se marca como fallido.
+func (s *Scheduler) enqueueStageTasks(job *common.JobRequest, plan *dag.Plan, stage dag.Stage, prevStageReports []common.TaskReport) {
+	tasks, err := stageTasks(job, plan, stage, prevStageReports, s.stageLayout(job, stage, prevStageReports))
+	if err != nil {
+		log.Printf("[Scheduler] No se pudo planificar la etapa %s del Job %s: %v", stage.ID, job.JobID, err)
+		s.Store.UpdateJobStatus(job.JobID, common.JobStatusFailed)
+		return
+	}
+	s.attachBroadcast(job.JobID, plan, stage.ID, tasks)
+	s.enqueue(stage, tasks)
+}
+
+func (s *Scheduler) enqueue(stage dag.Stage, tasks []common.Task) {
+	if len(tasks) == 0 { return }
+	s.PendingTasks = append(s.PendingTasks, tasks...)
+	log.Printf("[Scheduler] Encoladas %d tareas para etapa %s (Input: %s, Operaciones: %d)", len(tasks), stage.ID, tasks[0].InputPartition.SourceType, len(stage.Nodes))
+}
+
+// stageInputs junta los reportes de todos los padres de un stage (la ubicación actual de su shuffle).
+// Un stage fuente no tiene entradas: devuelve nil.
+func (s *Scheduler) stageInputs(jobID string, plan *dag.Plan, stageID string) []common.TaskReport {
+	var inputs []common.TaskReport
+	for _, parentID := range plan.Parents(stageID) {
+		inputs = append(inputs, s.stageOutputs(jobID, parentID)...)
+	}
+	return inputs
+}
+
+// broadcastInputs devuelve la ubicación actual de los padres broadcast de un stage (nil si no tiene)
+func (s *Scheduler) broadcastInputs(jobID string, plan *dag.Plan, stageID string) map[string]string {
+	parents := plan.BroadcastParents(stageID)
+	if len(parents) == 0 { return nil }
+	broadcast := make(map[string]string)
+	for _, parentID := range parents {
+		for _, rep := range s.stageOutputs(jobID, parentID) {
+			for _, meta := range rep.ShuffleOutput {
+				broadcast[rep.WorkerID+"-"+meta.Path] = fmt.Sprintf("http://%s/shuffle?path=%s", rep.WorkerID, meta.Path)
+			}
+		}
+	}
+	return broadcast
+}
+
+// attachBroadcast agrega a las tareas de un stage con JOINs fusionados sus padres broadcast: cada
+// tarea carga completo el lado replicado (BroadcastMap) y cruza con él los registros que produce.
+func (s *Scheduler) attachBroadcast(jobID string, plan *dag.Plan, stageID string, tasks []common.Task) {
+	broadcast := s.broadcastInputs(jobID, plan, stageID)
+	if broadcast == nil { return }
+	for i := range tasks {
+		if tasks[i].InputPartition.BroadcastMap == nil { tasks[i].InputPartition.BroadcastMap = make(map[string]string) }
+		maps.Copy(tasks[i].InputPartition.BroadcastMap, broadcast)
+	}
+}
+
+// replanTasks reconstruye solo las tareas indicadas (por TaskID) de un stage, con la ubicación
+// actual de sus entradas. Conserva el RetryCount de la versión anterior de cada tarea.
+func (s *Scheduler) replanTasks(job *common.JobRequest, plan *dag.Plan, stage dag.Stage, want map[string]common.Task) ([]common.Task, error) {
+	inputs := s.stageInputs(job.JobID, plan, stage.ID)
+	all, err := stageTasks(job, plan, stage, inputs, s.stageLayout(job, stage, inputs))
+	if err != nil { return nil, err }
+	s.attachBroadcast(job.JobID, plan, stage.ID, all)
+	var tasks []common.Task
+	for _, task := range all {
+		if prev, ok := want[task.TaskID]; ok {
+			task.RetryCount = prev.RetryCount
+			tasks = append(tasks, task)
+		}
+	}
+	return tasks, nil
+}
+
+// stageTaskID nombra la tarea de la partición i de un stage (igual en cada replanificación)
+func stageTaskID(jobID, stageID string, i int) string {
+	return fmt.Sprintf("%s-%s-%d", jobID, stageID, i)
+}
+
+// stageTasks crea las tareas del stage: una por partición si lee archivos, o una por elemento del
+// layout si lee shuffle (nil = una por partición). La cabeza del stage es la operación
+// de la tarea y las operaciones narrow fusionadas viajan en Task.Pipeline.
+func stageTasks(job *common.JobRequest, plan *dag.Plan, stage dag.Stage, prevStageReports []common.TaskReport, layout []common.ShuffleSlice) ([]common.Task, error) {
+    node := stage.Head()
+    node.NumPartitions = stagePartitions(job, node)
+    pipeline := stage.Pipeline()
+    output := stageOutput(job, plan, stage)
 
     var tasks []common.Task
     
-    // Caso MAP (Source)
+    // Caso MAP (Source): sin reportes de padres se lee de archivo
     if prevStageReports == nil {
+        input := sourceInput(job, node)
+        // Rangos de bytes por tarea (de uno o varios archivos); nil = cada worker recorre el archivo entero
+        splits, err := sourceSplits(sourcePatterns(job, node), node.NumPartitions)
+        if err != nil { return nil, err }
         for i := 0; i < node.NumPartitions; i++ {
+            taskInput := input
+            if splits != nil { taskInput.Splits = splits[i] }
             tasks = append(tasks, common.Task{
-                TaskID:    fmt.Sprintf("%s-%s-%d", job.JobID, node.ID, i),
+                TaskID:    stageTaskID(job.JobID, stage.ID, i),
                 JobID:     job.JobID,
-                StageID:   node.ID,
+                StageID:   stage.ID,
 				PartitionIndex: i,
+				TimeoutSec: job.TaskTimeoutSec,
+				Pool:       job.Pool,
+				Priority:   job.Priority,
                 Operation: node,
-                InputPartition: common.TaskInput{
-                    SourceType: inputType, // <--- CORRECCIÓN: Usamos la variable aquí
-                    Path:       job.InputPath,
-                },
-                OutputTarget: common.TaskOutput{
-                    Type:       common.OutputTypeShuffle,
-                    Path:       fmt.Sprintf("./data/outputs/%s/%s", job.JobID, node.ID),
-                    NumPartitions: node.NumPartitions,
-                },
+                Pipeline:  pipeline,
+                InputPartition: taskInput,
+                OutputTarget: output,
             })
         }
     } else {
-        // Caso REDUCE/JOIN (Shuffle)
-        for i := 0; i < node.NumPartitions; i++ {
-            shuffleMap := make(map[string]string)
-            // Buscar en los reportes anteriores quién tiene datos para la partición 'i'
-            for _, rep := range prevStageReports {
-                for _, meta := range rep.ShuffleOutput {
-                    if meta.PartitionKey == i {
-                        // Construir URL de descarga
-                        url := fmt.Sprintf("http://%s/shuffle?path=%s", rep.WorkerID, meta.Path)
-                        shuffleMap[rep.WorkerID+"-"+meta.Path] = url
-                    }
+        // Caso REDUCE/JOIN (Shuffle). Las tareas de merge se lanzan cuando terminan sus partes (launchMerges).
+        if layout == nil { layout = defaultLayout(node.NumPartitions) }
+        for i, slice := range layout {
+            if len(slice.MergeOf) > 0 { continue }
+            tasks = append(tasks, reduceTask(job, plan, stage, layout, i, prevStageReports))
+        }
+    }
+    return tasks, nil
+}
+
+// reduceTask crea la tarea i del layout de un stage que lee shuffle. Busca en los reportes de entrada
+// quién tiene datos para sus particiones; si el padre escribió más particiones que el stage, la
+// partición p se lee como p % N. Una tarea de merge lee completos los resultados de sus partes.
+func reduceTask(job *common.JobRequest, plan *dag.Plan, stage dag.Stage, layout []common.ShuffleSlice, i int, inputs []common.TaskReport) common.Task {
+    node := stage.Head()
+    node.NumPartitions = stagePartitions(job, node)
+    slice := layout[i]
+    pipeline := stage.Pipeline()
+    output := stageOutput(job, plan, stage)
+    if slice.Partial {
+        // La parte reduce sin aplicar el pipeline: el resultado final lo da el merge
+        pipeline, output = nil, partialOutput(job, stage)
+    }
+    merge := len(slice.MergeOf) > 0
+    if merge { node.UDFName = node.CombineUDF }
+    // BROADCAST_JOIN: el lado replicado viaja aparte; el worker lo carga antes de recorrer el otro
+    var broadcast map[string]string
+    node.Broadcast = ""
+    if slice.Broadcast {
+        broadcast = make(map[string]string)
+        node.Broadcast = replicatedSide(node, slice.Source)
+    }
+
+    shuffleMap := make(map[string]string)
+    for _, rep := range inputs {
+        if !merge && !slice.ReadsFrom(rep) { continue }
+        urls := shuffleMap
+        if slice.Broadcast && rep.StageID != slice.Source { urls = broadcast }
+        for _, meta := range rep.ShuffleOutput {
+            if merge || slice.Includes(meta.PartitionKey % node.NumPartitions) {
+                // Construir URL de descarga
+                url := fmt.Sprintf("http://%s/shuffle?path=%s", rep.WorkerID, meta.Path)
+                if slice.KeyRange != nil {
+                    // SORT_BY_KEY: solo el tramo del bloque ordenado que puede tener claves del rango
+                    start, end := sortedBlockRange(meta, slice.KeyRange, common.KeyLess(node.Order))
+                    if start >= end { continue }
+                    url += fmt.Sprintf("&start=%d&end=%d", start, end)
                 }
+                urls[rep.WorkerID+"-"+meta.Path] = url
             }
-            
-            tasks = append(tasks, common.Task{
-                TaskID:    fmt.Sprintf("%s-%s-%d", job.JobID, node.ID, i),
-                JobID:     job.JobID,
-                StageID:   node.ID,
-                Operation: node,
-                InputPartition: common.TaskInput{
-                    SourceType: inputType, 
-                    ShuffleMap: shuffleMap,
-                },
-                OutputTarget: common.TaskOutput{
-                    Type:       common.OutputTypeLocalSpill,
-                    Path:       fmt.Sprintf("/tmp/spark/%s/output", job.JobID),
-                    NumPartitions: 1,
-                },
-            })
         }
     }
-    
-    s.PendingTasks = append(s.PendingTasks, tasks...)
-    log.Printf("[Scheduler] Encoladas %d tareas para etapa %s (Input: %s)", len(tasks), node.ID, inputType)
+
+    return common.Task{
+        TaskID:    stageTaskID(job.JobID, stage.ID, i),
+        JobID:     job.JobID,
+        StageID:   stage.ID,
+		PartitionIndex: outputIndex(layout, i),
+		TimeoutSec: job.TaskTimeoutSec,
+		Pool:       job.Pool,
+		Priority:   job.Priority,
+        Operation: node,
+        Pipeline:  pipeline,
+        InputPartition: common.TaskInput{
+            SourceType: common.SourceTypeShuffle,
+            ShuffleMap: shuffleMap,
+            BroadcastMap: broadcast,
+            KeyRange:     slice.KeyRange,
+        },
+        OutputTarget: output,
+    }
 }
 
 // ControlLoop ejecuta el ciclo principal de orquestación
 func (s *Scheduler) ControlLoop() {
 	ticker := time.NewTicker(500 * time.Millisecond)
 	for range ticker.C {
-		// 1. Verificar Workers Muertos
-		deadWorkers := s.Registry.DetectDeadWorkers()
-		if len(deadWorkers) > 0 {
-			s.handleDeadWorkers(deadWorkers)
-		}
+		s.tick()
+	}
+}
+
+// tick ejecuta un único ciclo de orquestación (separado del ticker para poder probarlo)
+func (s *Scheduler) tick() {
+	// 1. Verificar Workers Muertos
+	deadWorkers := s.Registry.DetectDeadWorkers()
+	if len(deadWorkers) > 0 {
+		s.handleDeadWorkers(deadWorkers)
+	}
 
-		// 2. Asignar Tareas Pendientes
-		s.assignPendingTasks()
+	// 2. Asignar Tareas Pendientes
+	s.assignPendingTasks()
+
+	// 3. Copias especulativas de las tareas rezagadas
+	s.speculate()
+}
+
+// workerSnapshots toma la foto de los workers vivos (ordenados por ID) con los intentos que tienen asignados
+func (s *Scheduler) workerSnapshots() []WorkerSnapshot {
+	alive := s.Registry.GetAliveWorkers()
+	sort.Slice(alive, func(i, j int) bool { return alive[i].WorkerID < alive[j].WorkerID })
+
+	index := make(map[string]int)
+	workers := make([]WorkerSnapshot, len(alive))
+	for i, w := range alive {
+		slots := w.Slots
+		if slots <= 0 { slots = DefaultWorkerSlots }
+		workers[i] = WorkerSnapshot{Worker: w, Slots: slots}
+		index[w.WorkerID] = i
 	}
+	for taskID, workerID := range s.AssignedWorker {
+		if i, ok := index[workerID]; ok { workers[i].Running = append(workers[i].Running, s.RunningTasks[taskID]) }
+	}
+	for _, spec := range s.Speculative {
+		if i, ok := index[spec.WorkerID]; ok { workers[i].Running = append(workers[i].Running, spec.Task) }
+	}
+	return workers
 }
 
+// assignPendingTasks despacha las tareas que la política asigna a workers con slots libres.
+// La política recibe la cola ordenada por pools y prioridades (ver fairOrder).
+// Las que no caben esperan en el Master (no en la cola interna de un worker, donde correría su timeout).
 func (s *Scheduler) assignPendingTasks() {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	if len(s.PendingTasks) == 0 { return }
+	workers := s.workerSnapshots()
+	if len(workers) == 0 { return }
 
-	workers := s.Registry.GetAliveWorkers()
-	if len(workers) == 0 {
-		// log.Println("[Scheduler] No hay workers disponibles.")
-		return
+	byID := make(map[string]*WorkerSnapshot)
+	for i := range workers { byID[workers[i].Worker.WorkerID] = &workers[i] }
+	free := make(map[string]int)
+	for id, w := range byID { free[id] = w.Free() }
+
+	// La política trabaja sobre su propia copia de la foto
+	view := make([]WorkerSnapshot, len(workers))
+	for i, w := range workers {
+		view[i] = w
+		view[i].Running = append([]common.Task(nil), w.Running...)
 	}
 
-	// Política Round-Robin
-	capacity := len(workers)
-	
-	// Intentar asignar tantas tareas como sea posible
-	activeAssignable := 0 
-	for len(s.PendingTasks) > 0 {
-		// Selección de Worker
-		worker := workers[s.workerIdx % capacity]
-		s.workerIdx++
-		
-		// TODO: Load Awareness (Si active_tasks > X, saltar worker)
-		
-		task := s.PendingTasks[0]
+	order := fairOrder(s.PendingTasks, s.runningByPool(), s.PoolWeights)
+	queue := make([]common.Task, len(order))
+	for i, idx := range order { queue[i] = s.PendingTasks[idx] }
+
+	assigned := make(map[int]bool) // Índices de PendingTasks ya despachados
+	for _, a := range s.Policy.Assign(queue, view) {
+		target, ok := byID[a.WorkerID]
+		if !ok || a.TaskIndex < 0 || a.TaskIndex >= len(queue) || assigned[order[a.TaskIndex]] || free[a.WorkerID] <= 0 {
+			continue // Asignación inválida o a un worker sin slots: la tarea sigue en cola
+		}
+		free[a.WorkerID]--
+		assigned[order[a.TaskIndex]] = true
+
+		task := queue[a.TaskIndex]
+		s.attemptSeq++
+		task.Attempt = s.attemptSeq
 		
 		// Llamada asíncrona para no bloquear el loop
-		go s.dispatchTask(task, worker)
+		go s.dispatchTask(task, target.Worker)
 		
 		// Mover de Pending a Running
 		s.RunningTasks[task.TaskID] = task
-		s.AssignedWorker[task.TaskID] = worker.WorkerID
-		s.PendingTasks = s.PendingTasks[1:]
-		
-		activeAssignable++
-		if activeAssignable >= capacity * 2 { break } // No saturar en un solo tick
+		s.AssignedWorker[task.TaskID] = target.Worker.WorkerID
+		s.dispatchedAt[task.TaskID] = time.Now()
+	}
+
+	if len(assigned) == 0 { return }
+	pending := make([]common.Task, 0, len(s.PendingTasks)-len(assigned))
+	for i, task := range s.PendingTasks {
+		if !assigned[i] { pending = append(pending, task) }
 	}
+	s.PendingTasks = pending
 }
 
 func (s *Scheduler) dispatchTask(task common.Task, worker common.Heartbeat) {
@@ -184,23 +492,154 @@ func (s *Scheduler) dispatchTask(task common.Task, worker common.Heartbeat) {
 	// Si falla el envío HTTP inmediato (Connection Refused), re-encolar
 	if err != nil || resp.StatusCode != 200 {
 		log.Printf("[Scheduler] Fallo enviando tarea %s a %s: %v", task.TaskID, worker.Address, err)
-		s.HandleTaskFailure(task, "Dispatch Error")
+		if !s.dropFailedAttempt(task.TaskID, task.Attempt) {
+			s.HandleTaskFailure(task, "Dispatch Error")
+		}
 		if resp != nil { resp.Body.Close() }
 		return
 	}
 	defer resp.Body.Close()
 }
 
+// CommitTaskOutput confirma la salida final de un intento exitoso antes de registrarlo.
+// Si el commit falla, la tarea debe tratarse como fallida (su partición no quedó publicada).
+func (s *Scheduler) CommitTaskOutput(report common.TaskReport) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job := s.Store.GetJob(report.JobID)
+	if job == nil || report.OutputPath == "" { return nil }
+	plan := s.planFor(job)
+	if plan == nil { return nil }
+	if _, ok := plan.Stage(report.StageID); !ok || len(plan.Children(report.StageID)) > 0 { return nil }
+
+	// Solo se mueven archivos de intento dentro del directorio temporal del stage
+	dir := sinkOutputDir(job.Request, plan, report.StageID)
+	tempDir := filepath.Join(dir, common.OutputTempDir)
+	if !strings.HasPrefix(filepath.Clean(report.OutputPath), tempDir+string(filepath.Separator)) {
+		return fmt.Errorf("salida %s fuera del directorio temporal %s", report.OutputPath, tempDir)
+	}
+	return commitTaskOutput(dir, report.OutputPath)
+}
+
+// jobFinished indica si el Job ya no debe avanzar (terminado, fallido o cancelado)
+func jobFinished(status string) bool {
+	switch status {
+	case common.JobStatusSucceeded, common.JobStatusFailed, common.JobStatusCancelled:
+		return true
+	}
+	return false
+}
+
+var (
+	ErrJobNotFound = errors.New("job no encontrado")
+	ErrJobFinished = errors.New("el job ya terminó")
+)
+
+// CancelJob detiene un Job: descarta sus tareas pendientes, olvida las que están en curso,
+// lo marca CANCELLED y pide a todos los workers vivos que aborten sus tareas y borren su shuffle.
+func (s *Scheduler) CancelJob(jobID string) error {
+	s.mu.Lock()
+	job := s.Store.GetJob(jobID)
+	if job == nil {
+		s.mu.Unlock()
+		return ErrJobNotFound
+	}
+	if jobFinished(job.Status) {
+		s.mu.Unlock()
+		return fmt.Errorf("%w (estado: %s)", ErrJobFinished, job.Status)
+	}
+
+	// 1. Sacar de la cola las tareas que aún no se despacharon
+	pending := make([]common.Task, 0, len(s.PendingTasks))
+	for _, task := range s.PendingTasks {
+		if task.JobID != jobID { pending = append(pending, task) }
+	}
+	removed := len(s.PendingTasks) - len(pending)
+	s.PendingTasks = pending
+
+	// 2. Olvidar las que están en curso: sus reportes tardíos se ignorarán
+	running := 0
+	for taskID, task := range s.RunningTasks {
+		if task.JobID == jobID {
+			delete(s.RunningTasks, taskID)
+			delete(s.AssignedWorker, taskID)
+			running++
+		}
+	}
+
+	for taskID, task := range s.AwaitingTasks {
+		if task.JobID == jobID { delete(s.AwaitingTasks, taskID) }
+	}
+	for taskID, task := range s.pendingMerges {
+		if task.JobID == jobID { delete(s.pendingMerges, taskID) }
+	}
+	for taskID, spec := range s.Speculative {
+		if spec.Task.JobID == jobID { delete(s.Speculative, taskID) }
+	}
+
+	s.Store.UpdateJobStatus(jobID, common.JobStatusCancelled)
+	var dirs []string
+	if plan := s.planFor(job); plan != nil { dirs = sinkOutputDirs(job.Request, plan) }
+	s.mu.Unlock()
+
+	log.Printf("[Scheduler] Job %s CANCELADO (%d tareas pendientes descartadas, %d en curso abortadas)", jobID, removed, running)
+
+	// 3. Limpiar la salida sin publicar y avisar a los workers
+	for _, dir := range dirs { abortJobOutput(dir) }
+	for _, worker := range s.Registry.GetAliveWorkers() {
+		go s.sendAbort(worker, jobID)
+	}
+	return nil
+}
+
+// sendAbort pide a un worker que aborte las tareas de un Job y borre sus archivos intermedios
+func (s *Scheduler) sendAbort(worker common.Heartbeat, jobID string) {
+	req, _ := http.NewRequest(http.MethodDelete, fmt.Sprintf("http://%s/jobs/%s", worker.Address, jobID), nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		log.Printf("[Scheduler] No se pudo avisar la cancelación de %s a %s: %v", jobID, worker.WorkerID, err)
+		return
+	}
+	resp.Body.Close()
+}
+
+// abortCancelledJobs reenvía el aborto de los Jobs cancelados que un worker todavía anuncia en su heartbeat
+// (p. ej. uno que estuvo caído o aislado durante la cancelación y nunca recibió el aviso)
+func (s *Scheduler) abortCancelledJobs(worker common.Heartbeat) {
+	for _, jobID := range worker.Jobs {
+		if job := s.Store.GetJob(jobID); job != nil && job.Status == common.JobStatusCancelled {
+			log.Printf("[Scheduler] Worker %s aún tiene estado del Job cancelado %s: reenviando aborto", worker.WorkerID, jobID)
+			go s.sendAbort(worker, jobID)
+		}
+	}
+}
+
+// failJob marca el Job como fallido y descarta su salida sin publicar
+func (s *Scheduler) failJob(jobID string) {
+	s.Store.UpdateJobStatus(jobID, common.JobStatusFailed)
+	job := s.Store.GetJob(jobID)
+	if job == nil { return }
+	if plan := s.planFor(job); plan != nil {
+		for _, dir := range sinkOutputDirs(job.Request, plan) { abortJobOutput(dir) }
+	}
+}
+
 // HandleTaskCompletion se llama desde la API cuando llega un reporte
 func (s *Scheduler) HandleTaskCompletion(report common.TaskReport) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	if report.Status == common.TaskStatusSuccess { s.settleSpeculation(report) }
 	delete(s.RunningTasks, report.TaskID)
 	delete(s.AssignedWorker, report.TaskID)
+	delete(s.dispatchedAt, report.TaskID)
 
 	if report.Status == common.TaskStatusSuccess {
-		log.Printf("[Scheduler] Tarea Completada: %s", report.TaskID)
+		log.Printf("[Scheduler] Tarea Completada: %s (intento %d)", report.TaskID, report.Attempt)
+		// La partición ya tiene su intento ganador: descartar reintentos que sigan en cola
+		// (p. ej. re-encolada al dar por muerto a un worker que luego reportó)
+		s.dropPending(report.TaskID)
 		s.checkStageCompletion(report.JobID, report.StageID)
 	} else {
 		// ?Recuperar tarea original (necesitamos la definición completa para reintentar)
@@ -214,9 +653,28 @@ func (s *Scheduler) HandleTaskCompletion(report common.TaskReport) {
 	}
 }
 
+// dropPending quita de la cola las copias pendientes de una tarea
+func (s *Scheduler) dropPending(taskID string) {
+	pending := make([]common.Task, 0, len(s.PendingTasks))
+	for _, task := range s.PendingTasks {
+		if task.TaskID != taskID { pending = append(pending, task) }
+	}
+	s.PendingTasks = pending
+}
+
 func (s *Scheduler) HandleTaskFailure(task common.Task, reason string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+
+	// Un Job cancelado no reintenta (p. ej. fallo de despacho de una tarea ya descartada),
+	// ni una partición que ya tiene un intento exitoso registrado
+	job := s.Store.GetJob(task.JobID)
+	_, succeeded := s.Store.SuccessfulReport(task.JobID, task.StageID, task.TaskID)
+	if (job != nil && job.Status == common.JobStatusCancelled) || succeeded {
+		delete(s.RunningTasks, task.TaskID)
+		delete(s.AssignedWorker, task.TaskID)
+		return
+	}
 	
 	task.RetryCount++
 	if task.RetryCount <= common.MaxTaskRetries {
@@ -226,22 +684,128 @@ func (s *Scheduler) HandleTaskFailure(task common.Task, reason string) {
 		s.PendingTasks = append([]common.Task{task}, s.PendingTasks...)
 	} else {
 		log.Printf("[Scheduler] Tarea %s FALLÓ DEFINITIVAMENTE tras %d intentos. Abortando Job.", task.TaskID, task.RetryCount)
-		s.Store.UpdateJobStatus(task.JobID, common.JobStatusFailed)
+		s.failJob(task.JobID)
 	}
 	
 	delete(s.RunningTasks, task.TaskID)
 	delete(s.AssignedWorker, task.TaskID)
 }
 
+// HandleFetchFailure trata una tarea que no pudo descargar el shuffle de un padre. Las salidas de
+// ese worker se dan por perdidas: por linaje se recalculan solo las particiones afectadas de los
+// stages padre, y la tarea queda en espera hasta que sus padres vuelvan a estar completos.
+func (s *Scheduler) HandleFetchFailure(task common.Task, failure common.ShuffleFetchFailure) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.RunningTasks, task.TaskID)
+	delete(s.AssignedWorker, task.TaskID)
+
+	job := s.Store.GetJob(task.JobID)
+	if job == nil || jobFinished(job.Status) { return }
+	plan := s.planFor(job)
+	if plan == nil { return }
+
+	// Cuenta como reintento: acota los ciclos si un shuffle se pierde una y otra vez
+	task.RetryCount++
+	if task.RetryCount > common.MaxTaskRetries {
+		log.Printf("[Scheduler] Tarea %s FALLÓ DEFINITIVAMENTE tras %d intentos (fetch de %s). Abortando Job.", task.TaskID, task.RetryCount, failure.WorkerID)
+		s.failJob(task.JobID)
+		return
+	}
+
+	// Un merge lee los resultados parciales de su propio stage: se recalculan las partes perdidas
+	// y el merge se vuelve a lanzar cuando terminen (launchMerges), con los reintentos que ya lleva
+	if s.isMergeTask(task) {
+		s.pendingMerges[task.TaskID] = task
+		stage, _ := plan.Stage(task.StageID)
+		want := make(map[string]common.Task)
+		for _, rep := range s.Store.RemoveWorkerOutputs(task.JobID, task.StageID, failure.WorkerID) { want[rep.TaskID] = common.Task{} }
+		tasks, err := s.replanTasks(job.Request, plan, stage, want)
+		if err != nil {
+			log.Printf("[Lineage] No se pudo replanificar el stage %s del Job %s: %v", task.StageID, task.JobID, err)
+			s.failJob(task.JobID)
+			return
+		}
+		log.Printf("[Lineage] Resultados parciales de %s perdidos en %s: recalculando %d tareas", task.TaskID, failure.WorkerID, len(tasks))
+		s.PendingTasks = append(tasks, s.PendingTasks...)
+		s.launchMerges(job.Request, plan, stage)
+		return
+	}
+
+	for _, parentID := range plan.Dependencies(task.StageID) {
+		// Otra tarea ya pudo haber reportado la misma pérdida: entonces no queda nada que quitar
+		lost := s.Store.RemoveWorkerOutputs(task.JobID, parentID, failure.WorkerID)
+		if len(lost) == 0 { continue }
+
+		parent, _ := plan.Stage(parentID)
+		want := make(map[string]common.Task)
+		for _, rep := range lost { want[rep.TaskID] = common.Task{} }
+		tasks, err := s.replanTasks(job.Request, plan, parent, want)
+		if err != nil {
+			log.Printf("[Lineage] No se pudo replanificar el stage %s del Job %s: %v", parentID, task.JobID, err)
+			s.failJob(task.JobID)
+			return
+		}
+		log.Printf("[Lineage] Shuffle del stage %s perdido en %s: recalculando %d de sus tareas", parentID, failure.WorkerID, len(tasks))
+		s.PendingTasks = append(tasks, s.PendingTasks...)
+		s.launchMerges(job.Request, plan, parent) // Un merge perdido cuyas partes siguen disponibles
+	}
+
+	log.Printf("[Lineage] Tarea %s en espera de sus entradas (%s:%s no disponible)", task.TaskID, failure.WorkerID, failure.Path)
+	s.AwaitingTasks[task.TaskID] = task
+	s.resumeAwaitingTasks(job.Request, plan)
+}
+
+// resumeAwaitingTasks vuelve a encolar las tareas en espera cuyos stages padre ya están completos,
+// replanificadas con la nueva ubicación de su shuffle.
+func (s *Scheduler) resumeAwaitingTasks(job *common.JobRequest, plan *dag.Plan) {
+	byStage := make(map[string]map[string]common.Task)
+	for taskID, task := range s.AwaitingTasks {
+		if task.JobID != job.JobID { continue }
+		ready := true
+		for _, parentID := range plan.Dependencies(task.StageID) {
+			if !s.Store.IsStageCompleted(job.JobID, parentID) { ready = false }
+		}
+		if !ready { continue }
+		if byStage[task.StageID] == nil { byStage[task.StageID] = make(map[string]common.Task) }
+		byStage[task.StageID][taskID] = task
+		delete(s.AwaitingTasks, taskID)
+	}
+
+	for stageID, want := range byStage {
+		stage, _ := plan.Stage(stageID)
+		tasks, err := s.replanTasks(job, plan, stage, want)
+		if err != nil {
+			log.Printf("[Lineage] No se pudo replanificar el stage %s del Job %s: %v", stageID, job.JobID, err)
+			s.failJob(job.JobID)
+			return
+		}
+		log.Printf("[Lineage] Entradas del stage %s recuperadas: reintentando %d tareas", stageID, len(tasks))
+		s.PendingTasks = append(tasks, s.PendingTasks...)
+		s.launchMerges(job, plan, stage)
+	}
+}
+
 func (s *Scheduler) handleDeadWorkers(deadIDs []string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	for _, deadID := range deadIDs {
+		// Las copias especulativas de este worker se pierden sin más: el original sigue en curso
+		for taskID, spec := range s.Speculative {
+			if spec.WorkerID == deadID { delete(s.Speculative, taskID) }
+		}
+
 		// Buscar todas las tareas corriendo en este worker
 		for taskID, workerID := range s.AssignedWorker {
 			if workerID == deadID {
 				task, exists := s.RunningTasks[taskID]
+				if _, copied := s.Speculative[taskID]; exists && copied {
+					log.Printf("[FaultTolerance] Worker %s murió. La copia especulativa de %s continúa como intento principal", deadID, taskID)
+					s.promoteSpeculative(taskID)
+					continue
+				}
 				if exists {
 					log.Printf("[FaultTolerance] Worker %s murió. Re-encolando tarea %s", deadID, taskID)
 					// Re-encolar sin incrementar retry (no es culpa de la tarea)
@@ -254,45 +818,164 @@ func (s *Scheduler) handleDeadWorkers(deadIDs []string) {
 	}
 }
 
+// finishJob publica la salida final del Job: solo entonces cuenta como exitoso
+func (s *Scheduler) finishJob(job *common.JobRequest, plan *dag.Plan) {
+	for _, dir := range sinkOutputDirs(job, plan) {
+		if err := commitJobOutput(dir); err != nil {
+			log.Printf("[Scheduler] Commit de la salida de %s falló: %v", job.JobID, err)
+			s.failJob(job.JobID)
+			return
+		}
+	}
+	s.Store.UpdateJobStatus(job.JobID, common.JobStatusSucceeded)
+	log.Printf("=== JOB %s FINALIZADO EXITOSAMENTE ===", job.JobID)
+}
+
 func (s *Scheduler) checkStageCompletion(jobID, stageID string) {
 	job := s.Store.GetJob(jobID)
-	if job == nil { return }
+	if job == nil || jobFinished(job.Status) { return }
+
+	plan := s.planFor(job)
+	if plan == nil { return }
+
+	currentStage, ok := plan.Stage(stageID)
+	if !ok { return }
 	
 	// Verificar si todas las particiones de este stage terminaron
 	reports := s.Store.GetStageReports(jobID, stageID)
-	
-	// Buscar definición del nodo actual en el DAG
-	var currentNode common.OperationNode
-	var nextNode *common.OperationNode
-	
-	for i, node := range job.Request.DAG.Nodes {
-		if node.ID == stageID {
-			currentNode = node
-			// Buscar siguiente nodo conectado por un Edge
-			// Simplificación: Asumimos linealidad Nodes[i] -> Nodes[i+1] para Batch básico
-			if i+1 < len(job.Request.DAG.Nodes) {
-				nextNode = &job.Request.DAG.Nodes[i+1]
-			}
-			break
+	expected := s.stageTaskCount(job.Request, currentStage)
+	if len(reports) < expected {
+		s.launchMerges(job.Request, plan, currentStage)
+		return
+	}
+
+	// Solo el primer reporte que completa el stage dispara las etapas siguientes
+	if !s.Store.MarkStageCompleted(jobID, stageID) { return }
+	log.Printf("[Scheduler] Stage %s completado. %d/%d tareas.", stageID, len(reports), expected)
+
+	// Si el stage se recalculó por una pérdida de shuffle, sus consumidores en espera ya pueden seguir
+	s.resumeAwaitingTasks(job.Request, plan)
+
+	isDone := func(id string) bool { return s.Store.IsStageCompleted(jobID, id) }
+
+	// El Job termina cuando todos los stages del plan han completado
+	pending := 0
+	for _, id := range plan.TopologicalOrder() {
+		if !isDone(id) { pending++ }
+	}
+	if pending == 0 {
+		s.finishJob(job.Request, plan)
+		return
+	}
+
+	// Lanzar cada hijo cuyos padres hayan terminado todos, con el shuffle combinado de sus padres.
+	// Un hijo ya lanzado no se relanza (el padre puede volver a completarse tras un recálculo).
+	// Su layout se fija antes de marcarlo lanzado: un stage lanzado siempre sabe cuántas tareas tiene.
+	for _, childID := range plan.ReadyChildren(stageID, isDone) {
+		if s.Store.IsStageLaunched(jobID, childID) { continue }
+		child, _ := plan.Stage(childID)
+		inputs := s.stageInputs(jobID, plan, childID)
+		s.stageLayout(job.Request, child, inputs)
+		if !s.Store.MarkStageLaunched(jobID, childID) { continue }
+		s.enqueueStageTasks(job.Request, plan, child, inputs)
+	}
+}
+
+// AttemptsPerGeneration separa los números de intento de cada arranque del Master: los intentos
+// que seguían en curso al reiniciar no comparten archivos temporales con los nuevos.
+const AttemptsPerGeneration = 1000000
+
+// Recover retoma los Jobs que seguían en curso cuando el Master se detuvo, a partir del estado
+// guardado en el Store: los stages con todos sus reportes se dan por completos y las tareas sin
+// un intento exitoso registrado se vuelven a encolar. Devuelve cuántos Jobs se retomaron.
+// Los workers que sigan ejecutando tareas de antes reportarán igual: gana el primer éxito.
+func (s *Scheduler) Recover() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.attemptSeq = s.Store.Generation() * AttemptsPerGeneration
+	resumed := 0
+	for _, job := range s.Store.ListJobs() {
+		if jobFinished(job.Status) { continue }
+		plan := s.planFor(job)
+		if plan == nil {
+			log.Printf("[Scheduler] No se pudo replanificar el Job %s al recuperar: DAG inválido", job.Request.JobID)
+			s.Store.UpdateJobStatus(job.Request.JobID, common.JobStatusFailed)
+			continue
 		}
+		if job.Request.OutputPath == "" { job.Request.OutputPath = defaultOutputPath(job.Request.JobID) }
+		s.Store.UpdateJobStatus(job.Request.JobID, common.JobStatusRunning)
+		s.resumeJob(job.Request, plan)
+		resumed++
 	}
-	
-	expected := currentNode.NumPartitions
-	if expected == 0 { expected = job.Request.NumPartitions }
+	return resumed
+}
 
-	if len(reports) >= expected {
-		log.Printf("[Scheduler] Stage %s completado. %d/%d tareas.", stageID, len(reports), expected)
-		
-		if nextNode != nil {
-			// Lanzar siguiente etapa
-			go func() {
-				s.mu.Lock() // Bloquear para encolar
-				defer s.mu.Unlock()
-				s.enqueueStageTasks(job.Request, *nextNode, reports)
-			}()
-		} else {
-			s.Store.UpdateJobStatus(jobID, common.JobStatusSucceeded)
-			log.Printf("=== JOB %s FINALIZADO EXITOSAMENTE ===", jobID)
+// resumeJob reconstruye el avance de un Job recuperado y encola lo que le falta
+func (s *Scheduler) resumeJob(job *common.JobRequest, plan *dag.Plan) {
+	isDone := func(id string) bool { return s.Store.IsStageCompleted(job.JobID, id) }
+
+	// 1. Stages con todos sus reportes (el Master pudo caer antes de marcarlos)
+	for _, stageID := range plan.TopologicalOrder() {
+		stage, _ := plan.Stage(stageID)
+		if !isDone(stageID) && len(s.Store.GetStageReports(job.JobID, stageID)) >= s.stageTaskCount(job, stage) {
+			s.Store.MarkStageCompleted(job.JobID, stageID)
 		}
 	}
-}
\ No newline at end of file
+
+	// 2. Todo completo: solo faltaba publicar la salida
+	done := 0
+	for _, stageID := range plan.TopologicalOrder() {
+		if isDone(stageID) { done++ }
+	}
+	if done == plan.Len() {
+		s.finishJob(job, plan)
+		return
+	}
+
+	// 3. Encolar las tareas sin éxito registrado de cada stage listo (padres completos).
+	// Un stage ya lanzado cuyos padres perdieron salidas espera su recálculo, como antes del reinicio.
+	queued := 0
+	for _, stageID := range plan.TopologicalOrder() {
+		if isDone(stageID) { continue }
+		ready := true
+		for _, parentID := range plan.Dependencies(stageID) {
+			if !isDone(parentID) { ready = false }
+		}
+		stage, _ := plan.Stage(stageID)
+		if !ready {
+			if s.Store.IsStageLaunched(job.JobID, stageID) { s.awaitStage(job, stage) }
+			continue
+		}
+
+		inputs := s.stageInputs(job.JobID, plan, stageID)
+		all, err := stageTasks(job, plan, stage, inputs, s.stageLayout(job, stage, inputs))
+		if err != nil {
+			log.Printf("[Scheduler] No se pudo replanificar la etapa %s del Job %s: %v", stageID, job.JobID, err)
+			s.failJob(job.JobID)
+			return
+		}
+		s.attachBroadcast(job.JobID, plan, stageID, all)
+		var tasks []common.Task
+		for _, task := range all {
+			if _, ok := s.Store.SuccessfulReport(job.JobID, stageID, task.TaskID); !ok { tasks = append(tasks, task) }
+		}
+		s.Store.MarkStageLaunched(job.JobID, stageID)
+		s.enqueue(stage, tasks)
+		s.launchMerges(job, plan, stage)
+		queued += len(tasks)
+	}
+	log.Printf("[Scheduler] Job %s recuperado: %d/%d stages completos, %d tareas re-encoladas", job.JobID, done, plan.Len(), queued)
+}
+
+// awaitStage deja en espera las particiones sin éxito registrado de un stage lanzado: se replanifican
+// (con la ubicación de su shuffle) cuando sus padres vuelvan a estar completos.
+func (s *Scheduler) awaitStage(job *common.JobRequest, stage dag.Stage) {
+	layout := s.Store.StageLayout(job.JobID, stage.ID)
+	for i := 0; i < s.stageTaskCount(job, stage); i++ {
+		if layout != nil && len(layout[i].MergeOf) > 0 { continue } // Se lanza al terminar sus partes
+		taskID := stageTaskID(job.JobID, stage.ID, i)
+		if _, ok := s.Store.SuccessfulReport(job.JobID, stage.ID, taskID); ok { continue }
+		s.AwaitingTasks[taskID] = common.Task{TaskID: taskID, JobID: job.JobID, StageID: stage.ID, PartitionIndex: i}
+	}
+}
@@ -1,9 +1,13 @@
 package master
 
 import (
-	//"fmt"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -32,7 +36,7 @@ func createTestJob(jobID string) common.JobRequest {
 func TestScheduler_StageTransitionAndFaultTolerance(t *testing.T) {
 	// Reemplazamos MaxTaskRetries de la constante common si fuera necesario, o lo asumimos en 3.
 	
-	store := storage.NewJobStore()
+	store := storage.NewMemoryStore()
 	registry := NewWorkerRegistry()
 	scheduler := NewScheduler(registry, store)
 
@@ -62,6 +66,9 @@ func TestScheduler_StageTransitionAndFaultTolerance(t *testing.T) {
 		scheduler.SubmitJob(&job)
 		time.Sleep(10 * time.Millisecond) // Dejar que el scheduler encole
 
+		// Despachar las tareas MAP al worker simulado (las saca de Pending)
+		scheduler.assignPendingTasks()
+
 		// Simular éxito de las 2 tareas MAP
 		mapReports := []common.TaskReport{
 			{
@@ -110,8 +117,8 @@ func TestScheduler_StageTransitionAndFaultTolerance(t *testing.T) {
 		}
 		registry.mu.Unlock()
 
-		// Forzar el loop de control
-		scheduler.ControlLoop()
+		// Forzar un ciclo del loop de control
+		scheduler.tick()
 		time.Sleep(10 * time.Millisecond) // Dejar que el loop procese
 
 		// Verificar que la tarea fue re-encolada
@@ -139,9 +146,9 @@ func TestScheduler_StageTransitionAndFaultTolerance(t *testing.T) {
 		scheduler.HandleTaskFailure(failTask, "Fallo #1")
 		
 		// Simular dos fallos más hasta abortar (asumiendo MaxTaskRetries = 3)
-		requeuedTask := scheduler.PendingTasks[0]
-		scheduler.HandleTaskFailure(requeuedTask, "Fallo #2") // RetryCount=2
-		scheduler.HandleTaskFailure(requeuedTask, "Fallo #3") // RetryCount=3
+		// Cada fallo se aplica sobre la tarea re-encolada (la que lleva el RetryCount actualizado)
+		scheduler.HandleTaskFailure(scheduler.PendingTasks[0], "Fallo #2") // RetryCount=2
+		scheduler.HandleTaskFailure(scheduler.PendingTasks[0], "Fallo #3") // RetryCount=3
 
 		// Fallo definitivo (el cuarto intento)
 		finalTask := scheduler.PendingTasks[0] // Tarea con RetryCount=3
@@ -153,4 +160,569 @@ func TestScheduler_StageTransitionAndFaultTolerance(t *testing.T) {
 			t.Errorf("Job no abortado después de MaxTaskRetries. Estado: %s", finalStatus)
 		}
 	})
-}
\ No newline at end of file
+}
+func TestScheduler_BranchingDAG(t *testing.T) {
+	store := storage.NewMemoryStore()
+	scheduler := NewScheduler(NewWorkerRegistry(), store)
+
+	// Dos fuentes -> JOIN -> REDUCE. Los nodos se declaran en desorden: manda Edges.
+	jobID := "job-branch"
+	job := common.JobRequest{
+		JobID:         jobID,
+		OutputPath:    t.TempDir(),
+		NumPartitions: 1,
+		DAG: common.DAG{
+			Nodes: []common.OperationNode{
+				{ID: "reduce", Type: common.OpTypeReduceByKey, UDFName: "reduce_sum", NumPartitions: 1},
+				{ID: "join", Type: common.OpTypeJoin, UDFName: "join_concat", NumPartitions: 1},
+				{ID: "left", Type: common.OpTypeMap, UDFName: "map_wordcount", NumPartitions: 1},
+				{ID: "right", Type: common.OpTypeMap, UDFName: "map_wordcount", NumPartitions: 1},
+			},
+			Edges: [][]string{{"left", "join"}, {"right", "join"}, {"join", "reduce"}},
+		},
+	}
+	store.CreateJob(&job)
+
+	pendingStages := func() map[string]int {
+		scheduler.mu.Lock()
+		defer scheduler.mu.Unlock()
+		stages := make(map[string]int)
+		for _, task := range scheduler.PendingTasks {
+			stages[task.StageID]++
+		}
+		scheduler.PendingTasks = nil // Simular despacho
+		return stages
+	}
+	complete := func(stageID string) {
+		rep := common.TaskReport{
+			TaskID: jobID + "-" + stageID + "-0", JobID: jobID, StageID: stageID, Status: common.TaskStatusSuccess, WorkerID: "w1",
+			ShuffleOutput: []common.ShuffleMeta{{PartitionKey: 0, Path: "/tmp/" + stageID}},
+		}
+		store.AddTaskReport(jobID, stageID, rep)
+		scheduler.HandleTaskCompletion(rep)
+	}
+
+	scheduler.SubmitJob(&job)
+	if got := pendingStages(); len(got) != 2 || got["left"] != 1 || got["right"] != 1 {
+		t.Fatalf("Se esperaban las dos raíces encoladas, obtuvo %v", got)
+	}
+
+	complete("left")
+	if got := pendingStages(); len(got) != 0 {
+		t.Fatalf("JOIN lanzado antes de que terminaran todos sus padres: %v", got)
+	}
+
+	complete("right")
+	scheduler.mu.Lock()
+	joinTasks := append([]common.Task(nil), scheduler.PendingTasks...)
+	scheduler.mu.Unlock()
+	if len(joinTasks) != 1 || joinTasks[0].StageID != "join" {
+		t.Fatalf("Se esperaba 1 tarea JOIN, obtuvo %v", joinTasks)
+	}
+	if n := len(joinTasks[0].InputPartition.ShuffleMap); n != 2 {
+		t.Errorf("El JOIN debe leer el shuffle de ambos padres, obtuvo %d fuentes", n)
+	}
+	if joinTasks[0].OutputTarget.Type != common.OutputTypeShuffle {
+		t.Errorf("Un stage con hijos debe escribir shuffle, obtuvo %s", joinTasks[0].OutputTarget.Type)
+	}
+	pendingStages()
+
+	complete("join")
+	if got := pendingStages(); got["reduce"] != 1 {
+		t.Fatalf("Se esperaba el REDUCE encolado tras el JOIN, obtuvo %v", got)
+	}
+	if status := store.GetJob(jobID).Status; status != common.JobStatusRunning {
+		t.Errorf("El Job no debe finalizar con stages pendientes. Estado: %s", status)
+	}
+
+	complete("reduce")
+	if status := store.GetJob(jobID).Status; status != common.JobStatusSucceeded {
+		t.Errorf("El Job debió finalizar al completar el último stage. Estado: %s", status)
+	}
+}
+
+func TestScheduler_MultiSourceInputs(t *testing.T) {
+	store := storage.NewMemoryStore()
+	scheduler := NewScheduler(NewWorkerRegistry(), store)
+
+	job := common.JobRequest{
+		JobID:         "job-multi-source",
+		InputPath:     "/data/default.txt",
+		NumPartitions: 1,
+		DAG: common.DAG{
+			Nodes: []common.OperationNode{
+				{ID: "users", Type: common.OpTypeMap, UDFName: "map_parse_users", NumPartitions: 1,
+					Input: &common.InputSpec{Path: "/data/users.csv", Format: common.InputFormatCSV, SkipHeader: true}},
+				{ID: "orders", Type: common.OpTypeMap, UDFName: "map_parse_orders", NumPartitions: 2,
+					Input: &common.InputSpec{Path: "/data/orders.csv"}},
+				{ID: "legacy", Type: common.OpTypeMap, UDFName: "map_wordcount", NumPartitions: 1},
+				{ID: "join", Type: common.OpTypeJoin, UDFName: "join_users_orders", NumPartitions: 1},
+			},
+			Edges: [][]string{{"users", "join"}, {"orders", "join"}, {"legacy", "join"}},
+		},
+	}
+	store.CreateJob(&job)
+	scheduler.SubmitJob(&job)
+
+	scheduler.mu.Lock()
+	defer scheduler.mu.Unlock()
+
+	inputs := make(map[string]common.TaskInput)
+	count := make(map[string]int)
+	for _, task := range scheduler.PendingTasks {
+		inputs[task.StageID] = task.InputPartition
+		count[task.StageID]++
+	}
+
+	if count["users"] != 1 || count["orders"] != 2 || count["legacy"] != 1 || count["join"] != 0 {
+		t.Fatalf("Tareas por fuente incorrectas: %v", count)
+	}
+	if in := inputs["users"]; in.Path != "/data/users.csv" || in.Format != common.InputFormatCSV || !in.SkipHeader {
+		t.Errorf("Input de 'users' incorrecto: %+v", in)
+	}
+	if in := inputs["orders"]; in.Path != "/data/orders.csv" || in.Format != common.InputFormatText {
+		t.Errorf("Input de 'orders' incorrecto (formato por defecto TEXT): %+v", in)
+	}
+	if in := inputs["legacy"]; in.Path != "/data/default.txt" {
+		t.Errorf("Un nodo fuente sin InputSpec debe usar el InputPath del Job, obtuvo %s", in.Path)
+	}
+}
+
+func TestScheduler_FusedNarrowStage(t *testing.T) {
+	store := storage.NewMemoryStore()
+	scheduler := NewScheduler(NewWorkerRegistry(), store)
+
+	// Mismo DAG que jobs_specs/filter.json: FILTER -> MAP -> REDUCE
+	job := common.JobRequest{
+		JobID:         "job-fused",
+		InputPath:     "/data/users.csv",
+		NumPartitions: 2,
+		DAG: common.DAG{
+			Nodes: []common.OperationNode{
+				{ID: "filter-age", Type: common.OpTypeFilter, UDFName: "filter_adults", NumPartitions: 2},
+				{ID: "map-prep", Type: common.OpTypeMap, UDFName: "map_identity", NumPartitions: 2},
+				{ID: "reduce-count", Type: common.OpTypeReduceByKey, UDFName: "reduce_sum", NumPartitions: 1},
+			},
+			Edges: [][]string{{"filter-age", "map-prep"}, {"map-prep", "reduce-count"}},
+		},
+	}
+	store.CreateJob(&job)
+	scheduler.SubmitJob(&job)
+
+	scheduler.mu.Lock()
+	tasks := append([]common.Task(nil), scheduler.PendingTasks...)
+	scheduler.PendingTasks = nil
+	scheduler.mu.Unlock()
+
+	if len(tasks) != 2 {
+		t.Fatalf("Se esperaban 2 tareas del stage fusionado, obtuvo %d", len(tasks))
+	}
+	for _, task := range tasks {
+		if task.StageID != "map-prep" || task.Operation.ID != "filter-age" {
+			t.Errorf("Tarea mal formada: stage=%s op=%s", task.StageID, task.Operation.ID)
+		}
+		if len(task.Pipeline) != 1 || task.Pipeline[0].ID != "map-prep" {
+			t.Errorf("El MAP debía viajar fusionado en el Pipeline, obtuvo %v", task.Pipeline)
+		}
+		if task.OutputTarget.Type != common.OutputTypeShuffle || task.OutputTarget.NumPartitions != 1 {
+			t.Errorf("El stage fusionado debe hacer shuffle hacia el REDUCE (1 partición), obtuvo %+v", task.OutputTarget)
+		}
+	}
+
+	// Al completar el stage fusionado se lanza directamente el REDUCE
+	for i := 0; i < 2; i++ {
+		rep := common.TaskReport{
+			TaskID: tasks[i].TaskID, JobID: job.JobID, StageID: "map-prep", Status: common.TaskStatusSuccess, WorkerID: "w1",
+			ShuffleOutput: []common.ShuffleMeta{{PartitionKey: 0, Path: tasks[i].TaskID}},
+		}
+		store.AddTaskReport(job.JobID, "map-prep", rep)
+		scheduler.HandleTaskCompletion(rep)
+	}
+
+	scheduler.mu.Lock()
+	defer scheduler.mu.Unlock()
+	if len(scheduler.PendingTasks) != 1 || scheduler.PendingTasks[0].StageID != "reduce-count" {
+		t.Fatalf("Se esperaba la tarea REDUCE tras el stage fusionado, obtuvo %v", scheduler.PendingTasks)
+	}
+}
+
+func TestScheduler_ByteRangeSplits(t *testing.T) {
+	store := storage.NewMemoryStore()
+	scheduler := NewScheduler(NewWorkerRegistry(), store)
+
+	inputPath := filepath.Join(t.TempDir(), "input.txt")
+	if err := os.WriteFile(inputPath, []byte(strings.Repeat("linea\n", 10)), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	job := common.JobRequest{
+		JobID:         "job-splits",
+		InputPath:     inputPath,
+		NumPartitions: 3,
+		DAG: common.DAG{Nodes: []common.OperationNode{
+			{ID: "map", Type: common.OpTypeMap, UDFName: "map_wordcount", NumPartitions: 3},
+		}},
+	}
+	store.CreateJob(&job)
+	scheduler.SubmitJob(&job)
+
+	scheduler.mu.Lock()
+	defer scheduler.mu.Unlock()
+
+	// 60 bytes en 3 tareas: rangos contiguos de 20 bytes
+	var next int64
+	for i, task := range scheduler.PendingTasks {
+		splits := task.InputPartition.Splits
+		if len(splits) != 1 || splits[0].Path != inputPath {
+			t.Fatalf("Tarea %d con splits inesperados %v", i, splits)
+		}
+		off := splits[0].Offsets
+		if off[0] != next || off[1]-off[0] != 20 {
+			t.Errorf("Tarea %d con rango inesperado %v", i, off)
+		}
+		next = off[1]
+	}
+	if next != 60 {
+		t.Errorf("Los rangos no cubren el archivo completo: terminan en %d", next)
+	}
+}
+
+func TestScheduler_DirectoryAndGlobInputs(t *testing.T) {
+	store := storage.NewMemoryStore()
+	scheduler := NewScheduler(NewWorkerRegistry(), store)
+
+	dir := t.TempDir()
+	for i := 0; i < 4; i++ {
+		os.WriteFile(filepath.Join(dir, fmt.Sprintf("app-%02d.log", i)), []byte("linea\n"), 0644)
+	}
+	os.WriteFile(filepath.Join(dir, "_SUCCESS"), nil, 0644)
+
+	job := common.JobRequest{
+		JobID:         "job-dir",
+		InputPath:     dir,
+		NumPartitions: 2,
+		DAG: common.DAG{Nodes: []common.OperationNode{
+			{ID: "map", Type: common.OpTypeMap, UDFName: "map_wordcount", NumPartitions: 2},
+		}},
+	}
+	store.CreateJob(&job)
+	scheduler.SubmitJob(&job)
+
+	scheduler.mu.Lock()
+	// 4 archivos de 6 bytes en 2 tareas: dos archivos completos por tarea
+	if len(scheduler.PendingTasks) != 2 {
+		t.Fatalf("Se esperaban 2 tareas, hay %d", len(scheduler.PendingTasks))
+	}
+	for i, task := range scheduler.PendingTasks {
+		if len(task.InputPartition.Splits) != 2 {
+			t.Errorf("Tarea %d debería leer 2 archivos, obtuvo %v", i, task.InputPartition.Splits)
+		}
+	}
+	scheduler.mu.Unlock()
+
+	// Un glob sin coincidencias no se puede repartir: el Job falla sin encolar tareas
+	bad := common.JobRequest{
+		JobID:         "job-glob-vacio",
+		InputPath:     filepath.Join(dir, "*.gz"),
+		NumPartitions: 1,
+		DAG: common.DAG{Nodes: []common.OperationNode{
+			{ID: "map", Type: common.OpTypeMap, UDFName: "map_wordcount", NumPartitions: 1},
+		}},
+	}
+	store.CreateJob(&bad)
+	scheduler.SubmitJob(&bad)
+	if status := store.GetJob(bad.JobID).Status; status != common.JobStatusFailed {
+		t.Errorf("Se esperaba FAILED para un glob sin archivos, obtuvo %s", status)
+	}
+	scheduler.mu.Lock()
+	defer scheduler.mu.Unlock()
+	if len(scheduler.PendingTasks) != 2 {
+		t.Errorf("No debería encolarse ninguna tarea del Job fallido: hay %d pendientes", len(scheduler.PendingTasks))
+	}
+}
+
+func TestScheduler_CancelJob(t *testing.T) {
+	store := storage.NewMemoryStore()
+	registry := NewWorkerRegistry()
+	scheduler := NewScheduler(registry, store)
+
+	// Worker simulado: acepta tareas y registra los avisos de cancelación
+	aborts := make(chan string, 4)
+	workerServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			aborts <- r.URL.Path
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer workerServer.Close()
+	registry.UpdateHeartbeat(common.Heartbeat{WorkerID: "w1", Address: workerServer.Listener.Addr().String()})
+
+	victim := createTestJob("job-victim")
+	victim.OutputPath = t.TempDir()
+	other := createTestJob("job-other")
+	for _, job := range []*common.JobRequest{&victim, &other} {
+		store.CreateJob(job)
+		scheduler.SubmitJob(job)
+	}
+
+	// Despachar la primera tarea de la víctima; el resto queda pendiente
+	scheduler.mu.Lock()
+	first := scheduler.PendingTasks[0]
+	scheduler.PendingTasks = scheduler.PendingTasks[1:]
+	scheduler.RunningTasks[first.TaskID] = first
+	scheduler.AssignedWorker[first.TaskID] = "w1"
+	scheduler.mu.Unlock()
+
+	if err := scheduler.CancelJob(victim.JobID); err != nil {
+		t.Fatalf("CancelJob falló: %v", err)
+	}
+
+	scheduler.mu.Lock()
+	for _, task := range scheduler.PendingTasks {
+		if task.JobID == victim.JobID {
+			t.Errorf("Tarea pendiente del Job cancelado sigue en cola: %s", task.TaskID)
+		}
+	}
+	if len(scheduler.PendingTasks) != 2 {
+		t.Errorf("Las tareas del otro Job no deben tocarse: %d pendientes", len(scheduler.PendingTasks))
+	}
+	if _, running := scheduler.RunningTasks[first.TaskID]; running {
+		t.Errorf("La tarea en curso del Job cancelado sigue en RunningTasks")
+	}
+	scheduler.mu.Unlock()
+
+	if status := store.GetJob(victim.JobID).Status; status != common.JobStatusCancelled {
+		t.Errorf("Se esperaba CANCELLED, obtuvo %s", status)
+	}
+	select {
+	case path := <-aborts:
+		if path != "/jobs/"+victim.JobID {
+			t.Errorf("Aviso de cancelación a ruta inesperada: %s", path)
+		}
+	case <-time.After(2 * time.Second):
+		t.Errorf("El worker no recibió el aviso de cancelación")
+	}
+
+	// Reportes tardíos y fallos de la tarea abortada no reviven el Job
+	scheduler.HandleTaskFailure(first, "abortada")
+	scheduler.HandleTaskCompletion(common.TaskReport{TaskID: first.TaskID, JobID: victim.JobID, StageID: first.StageID, Status: common.TaskStatusSuccess})
+	if status := store.GetJob(victim.JobID).Status; status != common.JobStatusCancelled {
+		t.Errorf("Un reporte tardío cambió el estado del Job cancelado a %s", status)
+	}
+	if len(scheduler.PendingTasks) != 2 {
+		t.Errorf("Un fallo tardío re-encoló una tarea del Job cancelado")
+	}
+
+	// Un worker que vuelve a anunciar estado del Job cancelado (p. ej. tras estar caído) recibe de nuevo el aborto
+	scheduler.abortCancelledJobs(common.Heartbeat{WorkerID: "w1", Address: workerServer.Listener.Addr().String(), Jobs: []string{other.JobID, victim.JobID}})
+	select {
+	case path := <-aborts:
+		if path != "/jobs/"+victim.JobID {
+			t.Errorf("Reenvío del aborto a ruta inesperada: %s", path)
+		}
+	case <-time.After(2 * time.Second):
+		t.Errorf("El aborto no se reenvió al worker que anunció el Job cancelado")
+	}
+	select {
+	case path := <-aborts:
+		t.Errorf("Solo debía reenviarse el aborto del Job cancelado, llegó %s", path)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	if err := scheduler.CancelJob(victim.JobID); !errors.Is(err, ErrJobFinished) {
+		t.Errorf("Cancelar dos veces debe devolver ErrJobFinished, obtuvo %v", err)
+	}
+	if err := scheduler.CancelJob("no-existe"); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("Se esperaba ErrJobNotFound, obtuvo %v", err)
+	}
+}
+
+func TestScheduler_LineageRecompute(t *testing.T) {
+	store := storage.NewMemoryStore()
+	scheduler := NewScheduler(NewWorkerRegistry(), store)
+
+	job := createTestJob("job-lineage")
+	job.OutputPath = t.TempDir()
+	store.CreateJob(&job)
+	scheduler.SubmitJob(&job)
+
+	// Los dos MAP terminan en workers distintos; cada uno escribe las dos particiones del shuffle
+	scheduler.mu.Lock()
+	scheduler.PendingTasks = nil
+	scheduler.mu.Unlock()
+	mapReport := func(i int, worker string) common.TaskReport {
+		return common.TaskReport{
+			TaskID: fmt.Sprintf("job-lineage-stage-map-%d", i), JobID: job.JobID, StageID: "stage-map", Status: common.TaskStatusSuccess, WorkerID: worker,
+			ShuffleOutput: []common.ShuffleMeta{{PartitionKey: 0, Path: fmt.Sprintf("/tmp/m%d_p0", i)}, {PartitionKey: 1, Path: fmt.Sprintf("/tmp/m%d_p1", i)}},
+		}
+	}
+	for i, worker := range []string{"w-dead", "w-ok"} {
+		rep := mapReport(i, worker)
+		store.AddTaskReport(job.JobID, rep.StageID, rep)
+		scheduler.HandleTaskCompletion(rep)
+	}
+
+	countPending := func(taskID string) int {
+		n := 0
+		for _, task := range scheduler.PendingTasks {
+			if task.TaskID == taskID { n++ }
+		}
+		return n
+	}
+
+	// Los dos REDUCE fallan al descargar el shuffle del worker muerto
+	scheduler.mu.Lock()
+	reduces := scheduler.PendingTasks
+	scheduler.PendingTasks = nil
+	scheduler.mu.Unlock()
+	if len(reduces) != 2 { t.Fatalf("Se esperaban 2 tareas REDUCE, obtuvo %d", len(reduces)) }
+
+	failure := common.ShuffleFetchFailure{WorkerID: "w-dead", Path: "/tmp/m0_p0"}
+	scheduler.HandleFetchFailure(reduces[0], failure)
+	scheduler.HandleFetchFailure(reduces[1], failure)
+
+	scheduler.mu.Lock()
+	if countPending("job-lineage-stage-map-0") != 1 || countPending("job-lineage-stage-map-1") != 0 {
+		t.Errorf("Solo debe recalcularse el MAP perdido (una vez). Pendientes: %v", scheduler.PendingTasks)
+	}
+	if len(scheduler.AwaitingTasks) != 2 {
+		t.Errorf("Los REDUCE deben esperar al recálculo, en espera: %d", len(scheduler.AwaitingTasks))
+	}
+	scheduler.PendingTasks = nil
+	scheduler.mu.Unlock()
+	if store.IsStageCompleted(job.JobID, "stage-map") {
+		t.Errorf("El stage MAP no puede seguir completo con una salida perdida")
+	}
+
+	// El MAP recalculado termina en otro worker: los REDUCE se reintentan apuntando a él
+	rep := mapReport(0, "w-new")
+	store.AddTaskReport(job.JobID, rep.StageID, rep)
+	scheduler.HandleTaskCompletion(rep)
+
+	scheduler.mu.Lock()
+	defer scheduler.mu.Unlock()
+	if len(scheduler.AwaitingTasks) != 0 {
+		t.Errorf("Quedaron tareas en espera: %v", scheduler.AwaitingTasks)
+	}
+	if len(scheduler.PendingTasks) != 2 {
+		t.Fatalf("Se esperaban solo los 2 REDUCE reintentados (sin relanzar el stage), obtuvo %d", len(scheduler.PendingTasks))
+	}
+	for _, task := range scheduler.PendingTasks {
+		if task.StageID != "stage-reduce" || task.RetryCount != 1 {
+			t.Errorf("Tarea reintentada inesperada: %s (RetryCount %d)", task.TaskID, task.RetryCount)
+		}
+		for _, url := range task.InputPartition.ShuffleMap {
+			if strings.Contains(url, "w-dead") { t.Errorf("%s sigue apuntando al worker muerto: %s", task.TaskID, url) }
+		}
+		if len(task.InputPartition.ShuffleMap) != 2 {
+			t.Errorf("%s debe leer de los dos MAP, ShuffleMap: %v", task.TaskID, task.InputPartition.ShuffleMap)
+		}
+	}
+}
+
+func TestScheduler_SlotAwareAssignment(t *testing.T) {
+	store := storage.NewMemoryStore()
+	registry := NewWorkerRegistry()
+	scheduler := NewScheduler(registry, store)
+
+	workerServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer workerServer.Close()
+	addr := workerServer.Listener.Addr().String()
+
+	for _, hb := range []common.Heartbeat{
+		{WorkerID: "w-big", Slots: 4},
+		{WorkerID: "w-small", Slots: 1},
+		{WorkerID: "w-full", Slots: 2, ActiveTasks: 2, Status: common.WorkerStatusBusy}, // Pool lleno según su heartbeat
+		{WorkerID: "w-oom", Slots: 4, MemCapacityMB: 1000, MemUsageMB: 950},              // Sin memoria
+	} {
+		hb.Address = addr
+		registry.UpdateHeartbeat(hb)
+	}
+
+	scheduler.mu.Lock()
+	// w-big ya ejecuta una tarea: le quedan 3 slots
+	scheduler.RunningTasks["previa"] = common.Task{TaskID: "previa", JobID: "job-slots"}
+	scheduler.AssignedWorker["previa"] = "w-big"
+	for i := 0; i < 6; i++ {
+		scheduler.PendingTasks = append(scheduler.PendingTasks, common.Task{TaskID: fmt.Sprintf("t-%d", i), JobID: "job-slots"})
+	}
+	scheduler.mu.Unlock()
+
+	scheduler.assignPendingTasks()
+
+	scheduler.mu.Lock()
+	defer scheduler.mu.Unlock()
+	// La primera tarea va al menos cargado: w-small (0/1) antes que w-big (1/4)
+	if got := scheduler.AssignedWorker["t-0"]; got != "w-small" {
+		t.Errorf("La primera tarea debía ir al worker menos cargado (w-small), fue a %s", got)
+	}
+	perWorker := make(map[string]int)
+	for _, workerID := range scheduler.AssignedWorker { perWorker[workerID]++ }
+	if perWorker["w-big"] != 4 || perWorker["w-small"] != 1 || perWorker["w-full"] != 0 || perWorker["w-oom"] != 0 {
+		t.Errorf("Reparto inesperado (no debe superar los slots ni usar workers llenos): %v", perWorker)
+	}
+	if len(scheduler.PendingTasks) != 2 || scheduler.PendingTasks[0].TaskID != "t-4" {
+		t.Errorf("Las tareas sin slot deben esperar en el Master en orden, pendientes: %v", scheduler.PendingTasks)
+	}
+}
+
+func TestScheduler_RecoverAfterRestart(t *testing.T) {
+	stateDir := t.TempDir()
+	store, err := storage.OpenDurableStore(stateDir)
+	if err != nil { t.Fatalf("Error abriendo el store: %v", err) }
+	scheduler := NewScheduler(NewWorkerRegistry(), store)
+
+	job := createTestJob("job-restart")
+	job.OutputPath = t.TempDir()
+	store.CreateJob(&job)
+	scheduler.SubmitJob(&job)
+
+	// Solo termina el primer MAP antes de que el Master se caiga
+	rep := common.TaskReport{
+		TaskID: "job-restart-stage-map-0", JobID: job.JobID, StageID: "stage-map", Status: common.TaskStatusSuccess, WorkerID: "w1", Attempt: 1,
+		ShuffleOutput: []common.ShuffleMeta{{PartitionKey: 0, Path: "/tmp/m0_p0"}, {PartitionKey: 1, Path: "/tmp/m0_p1"}},
+	}
+	store.AddTaskReport(job.JobID, rep.StageID, rep)
+	scheduler.HandleTaskCompletion(rep)
+
+	// Nuevo Master sobre el mismo estado (sin Close: caída abrupta)
+	restarted, err := storage.OpenDurableStore(stateDir)
+	if err != nil { t.Fatalf("Error reabriendo el store: %v", err) }
+	defer restarted.Close()
+	recovered := NewScheduler(NewWorkerRegistry(), restarted)
+	if n := recovered.Recover(); n != 1 {
+		t.Fatalf("Se esperaba retomar 1 Job, obtuvo %d", n)
+	}
+
+	recovered.mu.Lock()
+	if len(recovered.PendingTasks) != 1 || recovered.PendingTasks[0].TaskID != "job-restart-stage-map-1" {
+		t.Errorf("Solo debe re-encolarse el MAP sin éxito registrado: %v", recovered.PendingTasks)
+	}
+	if recovered.attemptSeq < AttemptsPerGeneration {
+		t.Errorf("Los intentos del nuevo arranque no deben reutilizar números anteriores (attemptSeq %d)", recovered.attemptSeq)
+	}
+	recovered.PendingTasks = nil
+	recovered.mu.Unlock()
+
+	// Un reporte tardío de un intento previo al reinicio cuenta igual
+	late := rep
+	late.TaskID, late.WorkerID, late.Attempt = "job-restart-stage-map-1", "w2", 2
+	late.ShuffleOutput = []common.ShuffleMeta{{PartitionKey: 0, Path: "/tmp/m1_p0"}, {PartitionKey: 1, Path: "/tmp/m1_p1"}}
+	if !restarted.AddTaskReport(job.JobID, late.StageID, late) { t.Fatalf("El reporte tardío fue rechazado") }
+	recovered.HandleTaskCompletion(late)
+
+	recovered.mu.Lock()
+	reduces := recovered.PendingTasks
+	recovered.mu.Unlock()
+	if len(reduces) != 2 || reduces[0].StageID != "stage-reduce" {
+		t.Fatalf("Se esperaban los 2 REDUCE tras completar el MAP, obtuvo %v", reduces)
+	}
+	if len(reduces[0].InputPartition.ShuffleMap) != 2 {
+		t.Errorf("El REDUCE debe leer el shuffle de ambos MAP (el de antes del reinicio incluido): %v", reduces[0].InputPartition.ShuffleMap)
+	}
+	if got := restarted.GetJob(job.JobID).Status; got != common.JobStatusRunning {
+		t.Errorf("Estado del Job recuperado: %s", got)
+	}
+}
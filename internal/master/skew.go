@@ -0,0 +1,147 @@
+package master
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"sort"
+
+	"mini-spark/internal/common"
+	"mini-spark/internal/dag"
+)
+
+// ==========================================
+// CLAVES CALIENTES
+// ==========================================
+// Cada bloque de shuffle trae una muestra de sus claves más pesadas (ShuffleMeta.HotKeys). Si una
+// clave supera Adaptive.HotKeyBytes, su partición se reparte por bloques de map entre varias tareas
+// (el mismo "salting" para todas las claves de la partición):
+//   - JOIN: cada tarea lee una parte del lado grande y el lado chico completo (replicado).
+//   - REDUCE_BY_KEY: cada parte (Partial) reduce sus bloques y deja el resultado en un shuffle propio
+//     del stage; cuando terminan todas, una tarea de merge los combina con la combine_udf del nodo y
+//     escribe la salida real del stage. Los hijos del stage solo leen la salida del merge.
+
+// partitionStats suma los bytes de las particiones de una tarea y el peso de cada clave muestreada
+func partitionStats(slice common.ShuffleSlice, n int, inputs []common.TaskReport) (int64, map[string]int64) {
+	var size int64
+	keys := make(map[string]int64)
+	for _, rep := range inputs {
+		for _, meta := range rep.ShuffleOutput {
+			if !slice.Includes(meta.PartitionKey % n) { continue }
+			size += meta.Size
+			for _, kc := range meta.HotKeys { keys[kc.Key] += kc.Bytes }
+		}
+	}
+	return size, keys
+}
+
+// hotKeys devuelve las claves de más de threshold bytes, de mayor a menor
+func hotKeys(keys map[string]int64, threshold int64) []string {
+	var hot []string
+	for key, bytes := range keys {
+		if bytes > threshold { hot = append(hot, key) }
+	}
+	sort.Slice(hot, func(i, j int) bool {
+		if keys[hot[i]] != keys[hot[j]] { return keys[hot[i]] > keys[hot[j]] }
+		return hot[i] < hot[j]
+	})
+	return hot
+}
+
+// skewReason explica por qué conviene dividir las particiones de una tarea ("" = no hace falta):
+// superan MaxPartitionBytes, o superan el objetivo por culpa de una clave caliente
+func skewReason(slice common.ShuffleSlice, n int, inputs []common.TaskReport, spec *common.AdaptiveSpec) string {
+	size, keys := partitionStats(slice, n, inputs)
+	if spec.MaxPartitionBytes > 0 && size > spec.MaxPartitionBytes {
+		return fmt.Sprintf("%d bytes", size)
+	}
+	if spec.HotKeyBytes > 0 && size > spec.TargetPartitionBytes {
+		if hot := hotKeys(keys, spec.HotKeyBytes); len(hot) > 0 {
+			return fmt.Sprintf("claves calientes %v", hot)
+		}
+	}
+	return ""
+}
+
+// partialOutput es el destino de las partes de un REDUCE dividido: un shuffle de una partición
+// que solo lee la tarea de merge
+func partialOutput(job *common.JobRequest, stage dag.Stage) common.TaskOutput {
+	return common.TaskOutput{
+		Type:          common.OutputTypeShuffle,
+		Path:          filepath.Join(common.JobShuffleDir(job.JobID), stage.ID+"_partial"),
+		NumPartitions: 1,
+	}
+}
+
+// outputIndex numera la salida de la tarea i del layout sin contar las partes (no escriben salida
+// propia): los part-NNNNN de un stage final quedan consecutivos
+func outputIndex(layout []common.ShuffleSlice, i int) int {
+	idx := 0
+	for _, slice := range layout[:i] {
+		if !slice.Partial { idx++ }
+	}
+	return idx
+}
+
+// stageOutputs devuelve los reportes de un stage cuya salida leen sus hijos: los resultados
+// parciales de un REDUCE dividido solo los lee su tarea de merge
+func (s *Scheduler) stageOutputs(jobID, stageID string) []common.TaskReport {
+	reports := s.Store.GetStageReports(jobID, stageID)
+	partial := make(map[string]bool)
+	for i, slice := range s.Store.StageLayout(jobID, stageID) {
+		if slice.Partial { partial[stageTaskID(jobID, stageID, i)] = true }
+	}
+	if len(partial) == 0 { return reports }
+	var outputs []common.TaskReport
+	for _, rep := range reports {
+		if !partial[rep.TaskID] { outputs = append(outputs, rep) }
+	}
+	return outputs
+}
+
+// isMergeTask indica si la tarea combina los resultados parciales de otras del mismo stage
+func (s *Scheduler) isMergeTask(task common.Task) bool {
+	for i, slice := range s.Store.StageLayout(task.JobID, task.StageID) {
+		if len(slice.MergeOf) > 0 && stageTaskID(task.JobID, task.StageID, i) == task.TaskID { return true }
+	}
+	return false
+}
+
+// isQueued indica si la tarea ya está en cola, en curso o en espera
+func (s *Scheduler) isQueued(taskID string) bool {
+	if _, ok := s.RunningTasks[taskID]; ok { return true }
+	if _, ok := s.AwaitingTasks[taskID]; ok { return true }
+	for _, task := range s.PendingTasks {
+		if task.TaskID == taskID { return true }
+	}
+	return false
+}
+
+// launchMerges encola la tarea de merge de cada partición dividida cuyas partes ya terminaron todas.
+// Es idempotente: se llama cada vez que el stage avanza (reportes, recálculos, recuperación).
+func (s *Scheduler) launchMerges(job *common.JobRequest, plan *dag.Plan, stage dag.Stage) {
+	layout := s.Store.StageLayout(job.JobID, stage.ID)
+	for i, slice := range layout {
+		if len(slice.MergeOf) == 0 { continue }
+		taskID := stageTaskID(job.JobID, stage.ID, i)
+		if s.isQueued(taskID) { continue }
+		if _, ok := s.Store.SuccessfulReport(job.JobID, stage.ID, taskID); ok { continue }
+
+		var partials []common.TaskReport
+		for _, j := range slice.MergeOf {
+			rep, ok := s.Store.SuccessfulReport(job.JobID, stage.ID, stageTaskID(job.JobID, stage.ID, j))
+			if !ok { partials = nil; break }
+			partials = append(partials, rep)
+		}
+		if partials == nil { continue }
+		log.Printf("[Scheduler] Partes de %s terminadas: combinando %d resultados parciales", taskID, len(partials))
+		merge := reduceTask(job, plan, stage, layout, i, partials)
+		merge.InputPartition.BroadcastMap = s.broadcastInputs(job.JobID, plan, stage.ID) // El pipeline del merge puede incluir un JOIN fusionado
+		if prev, ok := s.pendingMerges[taskID]; ok {
+			// Relanzado tras perder parciales: los reintentos se acumulan para no ciclar sin límite
+			merge.RetryCount = prev.RetryCount
+			delete(s.pendingMerges, taskID)
+		}
+		s.PendingTasks = append(s.PendingTasks, merge)
+	}
+}
@@ -0,0 +1,110 @@
+package master
+
+import (
+	"testing"
+
+	"mini-spark/internal/common"
+	"mini-spark/internal/storage"
+)
+
+func TestHotKeys(t *testing.T) {
+	keys := map[string]int64{"adultos": 900, "ninos": 200, "otros": 10}
+	if hot := hotKeys(keys, 100); len(hot) != 2 || hot[0] != "adultos" || hot[1] != "ninos" {
+		t.Errorf("Claves calientes esperadas [adultos ninos], obtuvo %v", hot)
+	}
+	if hot := hotKeys(keys, 1000); len(hot) != 0 {
+		t.Errorf("Ninguna clave supera el umbral, obtuvo %v", hot)
+	}
+}
+
+func TestScheduler_HotKeySplitAndMerge(t *testing.T) {
+	store := storage.NewMemoryStore()
+	scheduler := NewScheduler(NewWorkerRegistry(), store)
+
+	job := createTestJob("job-hot")
+	job.OutputPath = t.TempDir()
+	job.DAG.Nodes[1].CombineUDF = "reduce_add"
+	job.Adaptive = &common.AdaptiveSpec{TargetPartitionBytes: 100, HotKeyBytes: 150}
+	store.CreateJob(&job)
+	scheduler.SubmitJob(&job)
+
+	scheduler.mu.Lock()
+	scheduler.PendingTasks = nil
+	scheduler.mu.Unlock()
+	// La clave "adultos" concentra la partición 0 en los dos MAP
+	for i := 0; i < 2; i++ {
+		rep := mapOutput(job.JobID, "stage-map", i, map[int]int64{0: 120, 1: 10})
+		for j := range rep.ShuffleOutput {
+			if rep.ShuffleOutput[j].PartitionKey == 0 { rep.ShuffleOutput[j].HotKeys = []common.KeyCount{{Key: "adultos", Records: 10, Bytes: 110}} }
+		}
+		store.AddTaskReport(job.JobID, rep.StageID, rep)
+		scheduler.HandleTaskCompletion(rep)
+	}
+
+	// Partición 0: dos partes (una por MAP) y su merge; partición 1: una tarea normal
+	if layout := store.StageLayout(job.JobID, "stage-reduce"); len(layout) != 4 || len(layout[2].MergeOf) != 2 {
+		t.Fatalf("Layout inesperado: %+v", layout)
+	}
+	scheduler.mu.Lock()
+	queued := scheduler.PendingTasks
+	scheduler.PendingTasks = nil
+	scheduler.mu.Unlock()
+	if len(queued) != 3 { t.Fatalf("Se esperaban 2 partes y 1 tarea normal en cola (el merge espera), obtuvo %d", len(queued)) }
+	for _, part := range queued[:2] {
+		if part.OutputTarget.Type != common.OutputTypeShuffle || part.OutputTarget.NumPartitions != 1 || len(part.InputPartition.ShuffleMap) != 1 {
+			t.Errorf("La parte %s debe leer un solo bloque y dejar un resultado parcial: %+v", part.TaskID, part.OutputTarget)
+		}
+	}
+	if queued[2].PartitionIndex != 1 {
+		t.Errorf("Las partes no numeran salida: la tarea de la partición 1 debe escribir part 1, escribe %d", queued[2].PartitionIndex)
+	}
+
+	complete := func(task common.Task, worker string) {
+		rep := common.TaskReport{TaskID: task.TaskID, JobID: job.JobID, StageID: task.StageID, Status: common.TaskStatusSuccess, WorkerID: worker,
+			ShuffleOutput: []common.ShuffleMeta{{PartitionKey: 0, Path: "/tmp/" + task.TaskID + "_partial"}}}
+		store.AddTaskReport(job.JobID, rep.StageID, rep)
+		scheduler.HandleTaskCompletion(rep)
+	}
+	popMerge := func() common.Task {
+		t.Helper()
+		scheduler.mu.Lock()
+		defer scheduler.mu.Unlock()
+		if len(scheduler.PendingTasks) != 1 { t.Fatalf("Se esperaba solo el merge en cola, hay %d tareas", len(scheduler.PendingTasks)) }
+		merge := scheduler.PendingTasks[0]
+		scheduler.PendingTasks = nil
+		return merge
+	}
+
+	complete(queued[0], "w-a")
+	scheduler.mu.Lock()
+	if len(scheduler.PendingTasks) != 0 { t.Errorf("El merge no puede lanzarse con una parte sin terminar") }
+	scheduler.mu.Unlock()
+	complete(queued[1], "w-b")
+	merge := popMerge()
+	if merge.Operation.UDFName != "reduce_add" || len(merge.InputPartition.ShuffleMap) != 2 || merge.OutputTarget.Type != common.OutputTypeFinal {
+		t.Errorf("El merge debe combinar los 2 parciales con la combine_udf hacia la salida final: %+v", merge)
+	}
+	if outs := scheduler.stageOutputs(job.JobID, "stage-reduce"); len(outs) != 0 {
+		t.Errorf("Los resultados parciales no son salida del stage: %+v", outs)
+	}
+
+	// El merge no encuentra el parcial de w-a: se recalcula esa parte y el merge vuelve a esperarla
+	scheduler.HandleFetchFailure(merge, common.ShuffleFetchFailure{WorkerID: "w-a", Path: "/tmp/" + queued[0].TaskID + "_partial"})
+	scheduler.mu.Lock()
+	if len(scheduler.PendingTasks) != 1 || scheduler.PendingTasks[0].TaskID != queued[0].TaskID {
+		t.Fatalf("Solo debía recalcularse la parte perdida: %v", scheduler.PendingTasks)
+	}
+	scheduler.PendingTasks = nil
+	scheduler.mu.Unlock()
+	complete(queued[0], "w-c")
+	merge = popMerge()
+	if merge.RetryCount != 1 {
+		t.Errorf("El merge relanzado debe conservar su reintento (RetryCount 1), tiene %d", merge.RetryCount)
+	}
+
+	complete(merge, "w-c")
+	complete(queued[2], "w-c")
+	if !store.IsStageCompleted(job.JobID, "stage-reduce") {
+		t.Errorf("El stage debía completarse con las partes, el merge y la tarea normal")
+	}
+}
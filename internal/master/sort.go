@@ -0,0 +1,80 @@
+package master
+
+import (
+	"sort"
+
+	"mini-spark/internal/common"
+)
+
+// ==========================================
+// SORT_BY_KEY (PARTICIONES POR RANGO)
+// ==========================================
+// El padre de un SORT_BY_KEY escribe un único bloque por tarea, ordenado por clave, con un índice de
+// claves y offsets cada ~1/64 del bloque (ShuffleMeta.KeyIndex). Cada entrada del índice es una muestra
+// que pesa los bytes hasta la siguiente: con todas, el Master corta el espacio de claves en rangos de
+// bytes parecidos. La tarea i lee de cada bloque solo los bytes que pueden tener claves de su rango,
+// mezcla esos tramos ya ordenados y escribe part-i: los part-NNNNN quedan en orden global.
+
+// rangeBoundaries elige hasta n-1 claves de corte (en el orden de less) que reparten por igual los
+// bytes de los bloques ordenados. Con pocas claves distintas puede devolver menos cortes.
+func rangeBoundaries(inputs []common.TaskReport, n int, less func(a, b string) bool) []string {
+	type sample struct {
+		key   string
+		bytes int64
+	}
+	var samples []sample
+	var total int64
+	for _, rep := range inputs {
+		for _, meta := range rep.ShuffleOutput {
+			for j, entry := range meta.KeyIndex {
+				next := meta.Size
+				if j+1 < len(meta.KeyIndex) { next = meta.KeyIndex[j+1].Offset }
+				samples = append(samples, sample{entry.Key, next - entry.Offset})
+				total += next - entry.Offset
+			}
+		}
+	}
+	if len(samples) == 0 { return nil }
+	sort.SliceStable(samples, func(i, j int) bool { return less(samples[i].key, samples[j].key) })
+
+	var bounds []string
+	var acc int64
+	prev := samples[0].key
+	for j := 0; j+1 < len(samples) && len(bounds) < n-1; j++ {
+		acc += samples[j].bytes
+		if acc*int64(n) < total*int64(len(bounds)+1) { continue }
+		// El rango siguiente empieza en la próxima muestra; los cortes repetidos se descartan
+		if key := samples[j+1].key; less(prev, key) {
+			bounds = append(bounds, key)
+			prev = key
+		}
+	}
+	return bounds
+}
+
+// sortLayout crea una tarea por rango de claves. Todas leen la partición 0: el bloque ordenado de cada
+// tarea del padre.
+func sortLayout(n int, order string, inputs []common.TaskReport) []common.ShuffleSlice {
+	edges := append([]string{""}, rangeBoundaries(inputs, n, common.KeyLess(order))...)
+	edges = append(edges, "")
+	layout := make([]common.ShuffleSlice, len(edges)-1)
+	for i := range layout {
+		layout[i] = common.ShuffleSlice{Partitions: []int{0}, KeyRange: []string{edges[i], edges[i+1]}}
+	}
+	return layout
+}
+
+// sortedBlockRange devuelve los bytes [start, end) de un bloque ordenado que pueden tener claves del
+// rango: antes de la última entrada del índice menor al inicio, y desde la primera que no es menor al
+// fin, todas las claves caen fuera
+func sortedBlockRange(meta common.ShuffleMeta, keyRange []string, less func(a, b string) bool) (int64, int64) {
+	start, end := int64(0), meta.Size
+	for _, entry := range meta.KeyIndex {
+		if keyRange[0] != "" && less(entry.Key, keyRange[0]) { start = entry.Offset }
+		if keyRange[1] != "" && !less(entry.Key, keyRange[1]) {
+			end = entry.Offset
+			break
+		}
+	}
+	return start, end
+}
@@ -0,0 +1,84 @@
+package master
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"mini-spark/internal/common"
+	"mini-spark/internal/dag"
+)
+
+// sortedOutput arma el reporte de una tarea que escribió un bloque ordenado de 10 bytes por clave
+func sortedOutput(jobID, stageID string, i int, keys ...string) common.TaskReport {
+	meta := common.ShuffleMeta{Path: fmt.Sprintf("/tmp/%s_%d", stageID, i), Size: int64(10 * len(keys))}
+	for j, key := range keys { meta.KeyIndex = append(meta.KeyIndex, common.KeyOffset{Key: key, Offset: int64(10 * j)}) }
+	return common.TaskReport{
+		TaskID: stageTaskID(jobID, stageID, i), JobID: jobID, StageID: stageID, Status: common.TaskStatusSuccess,
+		WorkerID: fmt.Sprintf("w%d", i), ShuffleOutput: []common.ShuffleMeta{meta},
+	}
+}
+
+func TestRangeBoundaries(t *testing.T) {
+	inputs := []common.TaskReport{
+		sortedOutput("job-sort", "words", 0, "a", "c", "e", "g"),
+		sortedOutput("job-sort", "words", 1, "b", "d", "f", "h"),
+	}
+	if got := rangeBoundaries(inputs, 4, common.KeyLess(common.SortAscending)); strings.Join(got, ",") != "c,e,g" {
+		t.Errorf("Cortes ascendentes esperados c,e,g, obtuvo %v", got)
+	}
+	if got := rangeBoundaries(inputs, 2, common.KeyLess(common.SortDescending)); strings.Join(got, ",") != "d" {
+		t.Errorf("Corte descendente esperado d, obtuvo %v", got)
+	}
+	// Una sola clave no se puede cortar: un único rango
+	same := []common.TaskReport{sortedOutput("job-sort", "words", 0, "x", "x", "x")}
+	if got := rangeBoundaries(same, 3, common.KeyLess(common.SortAscending)); len(got) != 0 {
+		t.Errorf("Sin claves distintas no hay cortes, obtuvo %v", got)
+	}
+}
+
+func TestScheduler_SortByKeyRanges(t *testing.T) {
+	job := &common.JobRequest{
+		JobID: "job-sort", NumPartitions: 2, OutputPath: t.TempDir(),
+		DAG: common.DAG{
+			Nodes: []common.OperationNode{
+				{ID: "words", Type: common.OpTypeMap, UDFName: "map_wc"},
+				{ID: "sorted", Type: common.OpTypeSortByKey, Order: common.SortDescending},
+			},
+			Edges: [][]string{{"words", "sorted"}},
+		},
+	}
+	plan, err := dag.NewPlan(job.DAG)
+	if err != nil { t.Fatalf("Plan inválido: %v", err) }
+
+	words, _ := plan.Stage("words")
+	if out := stageOutput(job, plan, words); out.NumPartitions != 1 || out.Sort != common.SortDescending {
+		t.Errorf("El padre de un SORT_BY_KEY debe escribir un bloque ordenado DESC: %+v", out)
+	}
+
+	inputs := []common.TaskReport{
+		sortedOutput(job.JobID, "words", 0, "z", "y", "x", "w"),
+		sortedOutput(job.JobID, "words", 1, "c", "b", "a"),
+	}
+	stage, _ := plan.Stage("sorted")
+	layout := planLayout(job, stage, inputs)
+	// 70 bytes en total: el corte en "c" deja z..w (40 bytes) y c..a (30 bytes)
+	if len(layout) != 2 || strings.Join(layout[0].KeyRange, ",") != ",c" || strings.Join(layout[1].KeyRange, ",") != "c," {
+		t.Fatalf("Rangos descendentes inesperados: %+v", layout)
+	}
+	tasks, err := stageTasks(job, plan, stage, inputs, layout)
+	if err != nil { t.Fatalf("Error planificando: %v", err) }
+
+	// El primer rango está todo en el bloque 0; el bloque 1 no tiene claves suyas
+	if urls := tasks[0].InputPartition.ShuffleMap; len(urls) != 1 || !strings.HasSuffix(urls["w0-/tmp/words_0"], "&start=0&end=40") {
+		t.Errorf("Tramos inesperados para el primer rango: %v", urls)
+	}
+	// Del bloque 0 el segundo rango solo relee el último tramo (w, descartado al filtrar)
+	urls := tasks[1].InputPartition.ShuffleMap
+	if len(urls) != 2 || !strings.HasSuffix(urls["w0-/tmp/words_0"], "&start=30&end=40") || !strings.HasSuffix(urls["w1-/tmp/words_1"], "&start=0&end=30") {
+		t.Errorf("Tramos inesperados para el segundo rango: %v", urls)
+	}
+	if tasks[1].PartitionIndex != 1 || strings.Join(tasks[1].InputPartition.KeyRange, ",") != "c," {
+		t.Errorf("La tarea del segundo rango debe escribir part 1 con su rango: %+v", tasks[1])
+	}
+}
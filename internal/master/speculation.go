@@ -0,0 +1,163 @@
+package master
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"mini-spark/internal/common"
+)
+
+// ==========================================
+// EJECUCIÓN ESPECULATIVA
+// ==========================================
+// Cuando la mayor parte de un stage terminó, las tareas que tardan mucho más que la mediana del stage
+// (rezagadas) reciben una copia en otro worker. Gana el primer intento que reporta éxito; el otro se aborta.
+
+const (
+	SpeculationQuantile   = 0.75            // Fracción del stage que debe haber terminado para especular
+	SpeculationMultiplier = 1.5             // Rezagada = lleva más de 1.5x la mediana de duración del stage
+	SpeculationMinRuntime = 2 * time.Second // Nunca se especula sobre tareas más cortas que esto
+)
+
+// speculativeAttempt es la copia en curso de una tarea rezagada
+type speculativeAttempt struct {
+	Task     common.Task
+	WorkerID string
+	Started  time.Time
+}
+
+// median devuelve la mediana de las duraciones de los reportes
+func median(reports []common.TaskReport) time.Duration {
+	durations := make([]int64, len(reports))
+	for i, rep := range reports { durations[i] = rep.DurationMs }
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	return time.Duration(durations[len(durations)/2]) * time.Millisecond
+}
+
+// stragglerThreshold devuelve a partir de cuánto tiempo una tarea del stage se considera rezagada,
+// o 0 si todavía no terminaron suficientes tareas para compararla.
+func (s *Scheduler) stragglerThreshold(jobID, stageID string) time.Duration {
+	job := s.Store.GetJob(jobID)
+	if job == nil || jobFinished(job.Status) { return 0 }
+	plan := s.planFor(job)
+	if plan == nil { return 0 }
+	stage, ok := plan.Stage(stageID)
+	if !ok { return 0 }
+
+	reports := s.Store.GetStageReports(jobID, stageID)
+	expected := s.stageTaskCount(job.Request, stage)
+	if len(reports) == 0 || len(reports) >= expected || float64(len(reports)) < SpeculationQuantile*float64(expected) {
+		return 0
+	}
+	return max(time.Duration(float64(median(reports))*SpeculationMultiplier), SpeculationMinRuntime)
+}
+
+// speculate lanza copias de las tareas rezagadas en un worker distinto al que las ejecuta
+func (s *Scheduler) speculate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	workers := s.workerSnapshots()
+	if len(workers) < 2 { return } // Una copia en el mismo worker no ayuda
+
+	now := time.Now()
+	thresholds := make(map[string]time.Duration) // Job/Stage -> umbral (0 = no especular)
+	for taskID, task := range s.RunningTasks {
+		if _, copied := s.Speculative[taskID]; copied { continue }
+
+		key := task.JobID + "/" + task.StageID
+		threshold, ok := thresholds[key]
+		if !ok {
+			threshold = s.stragglerThreshold(task.JobID, task.StageID)
+			thresholds[key] = threshold
+		}
+		started, ok := s.dispatchedAt[taskID]
+		if threshold == 0 || !ok || now.Sub(started) < threshold { continue }
+
+		// La copia va al worker menos cargado que no ejecuta el original, solo si tiene un slot libre
+		w := leastLoaded(workers, s.AssignedWorker[taskID])
+		if w < 0 { continue }
+		target := &workers[w]
+
+		spec := task
+		s.attemptSeq++
+		spec.Attempt = s.attemptSeq
+		s.Speculative[taskID] = speculativeAttempt{Task: spec, WorkerID: target.Worker.WorkerID, Started: now}
+		target.Running = append(target.Running, spec)
+		log.Printf("[Speculation] Tarea %s rezagada (%s > %s): copia (intento %d) en %s",
+			taskID, now.Sub(started).Round(time.Millisecond), threshold, spec.Attempt, target.Worker.WorkerID)
+		go s.dispatchTask(spec, target.Worker)
+	}
+}
+
+// settleSpeculation se llama con el primer éxito de una tarea: aborta el intento que perdió la carrera.
+func (s *Scheduler) settleSpeculation(report common.TaskReport) {
+	spec, ok := s.Speculative[report.TaskID]
+	if !ok { return }
+	delete(s.Speculative, report.TaskID)
+
+	if report.Attempt != spec.Task.Attempt {
+		go s.sendKill(spec.WorkerID, report.TaskID, spec.Task.Attempt)
+		return
+	}
+	log.Printf("[Speculation] La copia de %s (intento %d) terminó antes que el original", report.TaskID, spec.Task.Attempt)
+	if orig, running := s.RunningTasks[report.TaskID]; running {
+		go s.sendKill(s.AssignedWorker[report.TaskID], report.TaskID, orig.Attempt)
+	}
+}
+
+// isLiveAttempt indica si el intento es el que corre la tarea o su copia especulativa. Cualquier otro es
+// un intento ya reemplazado (su worker se dio por muerto, venció su timeout o el Master se reinició).
+func (s *Scheduler) isLiveAttempt(taskID string, attempt int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if task, ok := s.RunningTasks[taskID]; ok && task.Attempt == attempt { return true }
+	if spec, ok := s.Speculative[taskID]; ok && spec.Task.Attempt == attempt { return true }
+	return false
+}
+
+// dropFailedAttempt resuelve el fallo de un intento de una tarea con copia especulativa: si el otro
+// intento sigue en curso, la tarea no se reintenta. Devuelve false si la tarea no tenía copia.
+func (s *Scheduler) dropFailedAttempt(taskID string, attempt int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	spec, ok := s.Speculative[taskID]
+	if !ok { return false }
+	if attempt == spec.Task.Attempt {
+		// Falló la copia: el original sigue su curso
+		delete(s.Speculative, taskID)
+		return true
+	}
+	if orig, running := s.RunningTasks[taskID]; running && orig.Attempt == attempt {
+		// Falló el original: la copia pasa a ser el intento principal
+		s.promoteSpeculative(taskID)
+		return true
+	}
+	return false
+}
+
+// promoteSpeculative convierte la copia de una tarea en su intento principal
+func (s *Scheduler) promoteSpeculative(taskID string) {
+	spec := s.Speculative[taskID]
+	delete(s.Speculative, taskID)
+	s.RunningTasks[taskID] = spec.Task
+	s.AssignedWorker[taskID] = spec.WorkerID
+	s.dispatchedAt[taskID] = spec.Started
+}
+
+// sendKill pide a un worker que aborte un intento que ya no hace falta
+func (s *Scheduler) sendKill(workerID, taskID string, attempt int) {
+	worker, ok := s.Registry.GetWorker(workerID)
+	if !ok { return }
+	req, _ := http.NewRequest(http.MethodDelete, fmt.Sprintf("http://%s/tasks/%s?attempt=%d", worker.Address, taskID, attempt), nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		log.Printf("[Speculation] No se pudo abortar %s (intento %d) en %s: %v", taskID, attempt, workerID, err)
+		return
+	}
+	resp.Body.Close()
+}
@@ -0,0 +1,127 @@
+package master
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"mini-spark/internal/common"
+	"mini-spark/internal/storage"
+)
+
+func TestScheduler_SpeculativeExecution(t *testing.T) {
+	store := storage.NewMemoryStore()
+	registry := NewWorkerRegistry()
+	scheduler := NewScheduler(registry, store)
+
+	// Dos workers simulados que registran los intentos que se les abortan
+	kills := make(chan string, 4)
+	for _, id := range []string{"w1", "w2"} {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodDelete { kills <- id + " " + r.URL.RequestURI() }
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer server.Close()
+		registry.UpdateHeartbeat(common.Heartbeat{WorkerID: id, Address: server.Listener.Addr().String()})
+	}
+
+	job := createTestJob("job-spec")
+	job.OutputPath = t.TempDir()
+	job.DAG.Nodes[0].NumPartitions = 4
+	store.CreateJob(&job)
+	scheduler.SubmitJob(&job)
+	scheduler.assignPendingTasks()
+
+	// Tres de los cuatro MAP terminan rápido; el cuarto sigue corriendo
+	straggler := "job-spec-stage-map-3"
+	for i := 0; i < 3; i++ {
+		rep := common.TaskReport{TaskID: fmt.Sprintf("job-spec-stage-map-%d", i), JobID: job.JobID, StageID: "stage-map",
+			Status: common.TaskStatusSuccess, WorkerID: "w1", DurationMs: 100}
+		store.AddTaskReport(job.JobID, rep.StageID, rep)
+		scheduler.HandleTaskCompletion(rep)
+	}
+
+	// Recién despachada no es rezagada
+	scheduler.speculate()
+	if len(scheduler.Speculative) != 0 {
+		t.Fatalf("No debe especularse sobre una tarea que aún no supera el umbral")
+	}
+
+	// Lleva mucho más que la mediana del stage: se lanza una copia en el otro worker
+	scheduler.mu.Lock()
+	scheduler.dispatchedAt[straggler] = time.Now().Add(-10 * time.Second)
+	original := scheduler.RunningTasks[straggler]
+	originalWorker := scheduler.AssignedWorker[straggler]
+	scheduler.mu.Unlock()
+	scheduler.speculate()
+
+	scheduler.mu.Lock()
+	spec, ok := scheduler.Speculative[straggler]
+	scheduler.mu.Unlock()
+	if !ok {
+		t.Fatalf("No se lanzó una copia especulativa de la tarea rezagada")
+	}
+	if spec.WorkerID == originalWorker || spec.Task.Attempt == original.Attempt {
+		t.Errorf("La copia debe ser otro intento en otro worker: %+v (original en %s)", spec, originalWorker)
+	}
+	scheduler.speculate()
+	if len(scheduler.Speculative) != 1 {
+		t.Errorf("Una tarea no debe tener más de una copia")
+	}
+
+	// Gana la copia: el original se aborta y el stage se completa una sola vez
+	rep := common.TaskReport{TaskID: straggler, JobID: job.JobID, StageID: "stage-map", Status: common.TaskStatusSuccess,
+		WorkerID: spec.WorkerID, Attempt: spec.Task.Attempt, DurationMs: 100}
+	store.AddTaskReport(job.JobID, rep.StageID, rep)
+	scheduler.HandleTaskCompletion(rep)
+
+	expected := fmt.Sprintf("%s /tasks/%s?attempt=%d", originalWorker, straggler, original.Attempt)
+	select {
+	case kill := <-kills:
+		if kill != expected { t.Errorf("Se abortó el intento equivocado: %s (esperado %s)", kill, expected) }
+	case <-time.After(2 * time.Second):
+		t.Errorf("El intento perdedor no fue abortado")
+	}
+	if len(scheduler.Speculative) != 0 || !store.IsStageCompleted(job.JobID, "stage-map") {
+		t.Errorf("La tarea debía quedar resuelta y el stage completo")
+	}
+}
+
+func TestScheduler_SpeculativeAttemptFailure(t *testing.T) {
+	scheduler := NewScheduler(NewWorkerRegistry(), storage.NewMemoryStore())
+	task := common.Task{TaskID: "t-0", JobID: "j", StageID: "s", Attempt: 1}
+	copyTask := task
+	copyTask.Attempt = 2
+
+	reset := func() {
+		scheduler.RunningTasks[task.TaskID] = task
+		scheduler.AssignedWorker[task.TaskID] = "w1"
+		scheduler.Speculative[task.TaskID] = speculativeAttempt{Task: copyTask, WorkerID: "w2"}
+	}
+
+	// Falla la copia: el original sigue como intento principal
+	reset()
+	if !scheduler.dropFailedAttempt(task.TaskID, 2) || scheduler.RunningTasks[task.TaskID].Attempt != 1 {
+		t.Errorf("El fallo de la copia no debe afectar al original")
+	}
+
+	// Falla el original: la copia toma su lugar sin re-encolar
+	reset()
+	if !scheduler.dropFailedAttempt(task.TaskID, 1) {
+		t.Fatalf("El fallo del original con copia en curso no debe reintentarse")
+	}
+	if scheduler.RunningTasks[task.TaskID].Attempt != 2 || scheduler.AssignedWorker[task.TaskID] != "w2" || len(scheduler.Speculative) != 0 {
+		t.Errorf("La copia no fue promovida: %+v en %s", scheduler.RunningTasks[task.TaskID], scheduler.AssignedWorker[task.TaskID])
+	}
+	if len(scheduler.PendingTasks) != 0 {
+		t.Errorf("No debe re-encolarse nada")
+	}
+
+	// Sin copia: el fallo sigue el camino normal de reintentos
+	delete(scheduler.Speculative, task.TaskID)
+	if scheduler.dropFailedAttempt(task.TaskID, 2) {
+		t.Errorf("Sin copia especulativa el fallo debe tratarse como siempre")
+	}
+}
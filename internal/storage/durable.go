@@ -0,0 +1,295 @@
+package storage
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"mini-spark/internal/common"
+)
+
+// ==========================================
+// PERSISTENCIA DEL ESTADO DEL MASTER
+// ==========================================
+// DurableStore aplica cada cambio en memoria y lo agrega a un log de escritura anticipada
+// (<dir>/wal.log, un registro JSON por línea, sincronizado a disco). Cada SnapshotEvery registros
+// vuelca el estado completo a <dir>/snapshot.json y vacía el WAL. Al abrir, carga el snapshot y
+// reaplica los registros posteriores (Seq mayor al del snapshot), así un reinicio no pierde Jobs.
+
+const (
+	walFile      = "wal.log"
+	snapshotFile = "snapshot.json"
+
+	DefaultSnapshotEvery = 1000 // Registros del WAL entre snapshots
+)
+
+// Operaciones del WAL
+const (
+	opCreateJob      = "create_job"
+	opJobStatus      = "job_status"
+	opTaskReport     = "task_report"
+	opStageCompleted = "stage_completed"
+	opStageLaunched  = "stage_launched"
+	opStageLayout    = "stage_layout"
+	opRemoveOutputs  = "remove_outputs"
+	opGeneration     = "generation"
+)
+
+// walRecord es un cambio de estado del Master
+type walRecord struct {
+	Seq       int64              `json:"seq"`
+	Op        string             `json:"op"`
+	JobID     string             `json:"job_id,omitempty"`
+	StageID   string             `json:"stage_id,omitempty"`
+	Status    string             `json:"status,omitempty"`
+	WorkerID  string             `json:"worker_id,omitempty"`
+	StartTime int64              `json:"start_time,omitempty"`
+	Request   *common.JobRequest `json:"request,omitempty"`
+	Report    *common.TaskReport `json:"report,omitempty"`
+	Layout    []common.ShuffleSlice `json:"layout,omitempty"`
+}
+
+// snapshot es el estado completo del Master hasta el registro Seq del WAL
+type snapshot struct {
+	Seq        int64                `json:"seq"`
+	Generation int                  `json:"generation"`
+	Jobs       map[string]*JobState `json:"jobs"`
+}
+
+// DurableStore es un MemoryStore cuyos cambios sobreviven a un reinicio del Master
+type DurableStore struct {
+	*MemoryStore
+
+	mu            sync.Mutex // Serializa los cambios: el orden del WAL es el orden en memoria
+	dir           string
+	wal           *os.File
+	seq           int64 // Último registro escrito
+	walRecords    int   // Registros desde el último snapshot
+	generation    int
+	fenced        bool // El Master perdió el liderazgo: ya no se escribe en disco
+	SnapshotEvery int
+}
+
+// OpenDurableStore carga el estado guardado en dir (o lo crea vacío) y registra un nuevo arranque
+func OpenDurableStore(dir string) (*DurableStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil { return nil, err }
+	s := &DurableStore{MemoryStore: NewMemoryStore(), dir: dir, SnapshotEvery: DefaultSnapshotEvery}
+
+	if err := s.loadSnapshot(); err != nil { return nil, err }
+	valid, err := s.replayWAL()
+	if err != nil { return nil, err }
+
+	s.wal, err = os.OpenFile(filepath.Join(dir, walFile), os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil { return nil, err }
+	// Un registro a medio escribir (caída durante un append) se descarta
+	if err := s.wal.Truncate(valid); err != nil { s.wal.Close(); return nil, err }
+	if _, err := s.wal.Seek(valid, io.SeekStart); err != nil { s.wal.Close(); return nil, err }
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.generation++
+	s.append(walRecord{Op: opGeneration})
+	log.Printf("[Store] Estado cargado desde %s: %d Jobs (arranque #%d)", dir, len(s.Jobs), s.generation)
+	return s, nil
+}
+
+// loadSnapshot carga el último snapshot, si existe
+func (s *DurableStore) loadSnapshot() error {
+	data, err := os.ReadFile(filepath.Join(s.dir, snapshotFile))
+	if os.IsNotExist(err) { return nil }
+	if err != nil { return err }
+
+	var snap snapshot
+	if err := json.Unmarshal(data, &snap); err != nil { return fmt.Errorf("snapshot corrupto: %w", err) }
+	if snap.Jobs != nil { s.Jobs = snap.Jobs }
+	s.seq = snap.Seq
+	s.generation = snap.Generation
+	return nil
+}
+
+// replayWAL reaplica los registros posteriores al snapshot. Devuelve el tamaño de la parte válida
+// del WAL: lo que sigue a un registro ilegible es la cola de una escritura interrumpida.
+func (s *DurableStore) replayWAL() (int64, error) {
+	f, err := os.Open(filepath.Join(s.dir, walFile))
+	if os.IsNotExist(err) { return 0, nil }
+	if err != nil { return 0, err }
+	defer f.Close()
+
+	reader := bufio.NewReader(f)
+	var valid int64
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			if len(line) > 0 { log.Printf("[Store] Descartando registro incompleto al final del WAL") }
+			return valid, nil
+		}
+		if err != nil { return 0, err }
+
+		var rec walRecord
+		if err := json.Unmarshal(line, &rec); err != nil {
+			log.Printf("[Store] Registro ilegible en el WAL (offset %d), se descarta el resto: %v", valid, err)
+			return valid, nil
+		}
+		valid += int64(len(line))
+		if rec.Seq <= s.seq { continue } // Ya incluido en el snapshot
+		s.apply(rec)
+		s.seq = rec.Seq
+		s.walRecords++
+	}
+}
+
+// apply reaplica un registro sobre el estado en memoria
+func (s *DurableStore) apply(rec walRecord) {
+	switch rec.Op {
+	case opCreateJob:
+		if rec.Request != nil { s.MemoryStore.createJob(rec.Request, rec.StartTime) }
+	case opJobStatus:
+		s.MemoryStore.UpdateJobStatus(rec.JobID, rec.Status)
+	case opTaskReport:
+		if rec.Report != nil { s.MemoryStore.AddTaskReport(rec.JobID, rec.StageID, *rec.Report) }
+	case opStageCompleted:
+		s.MemoryStore.MarkStageCompleted(rec.JobID, rec.StageID)
+	case opStageLaunched:
+		s.MemoryStore.MarkStageLaunched(rec.JobID, rec.StageID)
+	case opStageLayout:
+		s.MemoryStore.SetStageLayout(rec.JobID, rec.StageID, rec.Layout)
+	case opRemoveOutputs:
+		s.MemoryStore.RemoveWorkerOutputs(rec.JobID, rec.StageID, rec.WorkerID)
+	case opGeneration:
+		s.generation++
+	}
+}
+
+// append escribe un registro al WAL y lo sincroniza a disco (requiere s.mu).
+// Un error de escritura no deshace el cambio en memoria: el Master sigue, pero sin garantía de recuperarlo.
+func (s *DurableStore) append(rec walRecord) {
+	if s.fenced { return }
+	s.seq++
+	rec.Seq = s.seq
+	data, err := json.Marshal(rec)
+	if err == nil {
+		_, err = s.wal.Write(append(data, '\n'))
+	}
+	if err == nil { err = s.wal.Sync() }
+	if err != nil {
+		log.Printf("[Store] ERROR escribiendo el WAL (%s %s): %v", rec.Op, rec.JobID, err)
+		return
+	}
+
+	s.walRecords++
+	if s.SnapshotEvery > 0 && s.walRecords >= s.SnapshotEvery {
+		if err := s.writeSnapshot(); err != nil { log.Printf("[Store] ERROR escribiendo snapshot: %v", err) }
+	}
+}
+
+// writeSnapshot vuelca el estado completo y vacía el WAL (requiere s.mu).
+// El snapshot se escribe aparte y se renombra: una caída a mitad deja el anterior intacto.
+func (s *DurableStore) writeSnapshot() error {
+	s.MemoryStore.mu.RLock()
+	data, err := json.Marshal(snapshot{Seq: s.seq, Generation: s.generation, Jobs: s.Jobs})
+	s.MemoryStore.mu.RUnlock()
+	if err != nil { return err }
+
+	tmp := filepath.Join(s.dir, snapshotFile+".tmp")
+	f, err := os.Create(tmp)
+	if err != nil { return err }
+	if _, err := f.Write(data); err != nil { f.Close(); return err }
+	if err := f.Sync(); err != nil { f.Close(); return err }
+	if err := f.Close(); err != nil { return err }
+	if err := os.Rename(tmp, filepath.Join(s.dir, snapshotFile)); err != nil { return err }
+
+	// Los registros del WAL ya están en el snapshot (y se saltarían por Seq si el truncado no llega a disco)
+	if err := s.wal.Truncate(0); err != nil { return err }
+	if _, err := s.wal.Seek(0, io.SeekStart); err != nil { return err }
+	s.walRecords = 0
+	return nil
+}
+
+func (s *DurableStore) CreateJob(req *common.JobRequest) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.MemoryStore.CreateJob(req)
+	job := s.MemoryStore.GetJob(req.JobID)
+	s.append(walRecord{Op: opCreateJob, JobID: req.JobID, Request: req, StartTime: job.StartTime})
+}
+
+func (s *DurableStore) UpdateJobStatus(jobID, status string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.MemoryStore.GetJob(jobID) == nil { return }
+	s.MemoryStore.UpdateJobStatus(jobID, status)
+	s.append(walRecord{Op: opJobStatus, JobID: jobID, Status: status})
+}
+
+func (s *DurableStore) AddTaskReport(jobID, stageID string, report common.TaskReport) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.MemoryStore.AddTaskReport(jobID, stageID, report) { return false }
+	s.append(walRecord{Op: opTaskReport, JobID: jobID, StageID: stageID, Report: &report})
+	return true
+}
+
+func (s *DurableStore) MarkStageCompleted(jobID, stageID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.MemoryStore.MarkStageCompleted(jobID, stageID) { return false }
+	s.append(walRecord{Op: opStageCompleted, JobID: jobID, StageID: stageID})
+	return true
+}
+
+func (s *DurableStore) MarkStageLaunched(jobID, stageID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.MemoryStore.MarkStageLaunched(jobID, stageID) { return false }
+	s.append(walRecord{Op: opStageLaunched, JobID: jobID, StageID: stageID})
+	return true
+}
+
+func (s *DurableStore) SetStageLayout(jobID, stageID string, layout []common.ShuffleSlice) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.MemoryStore.SetStageLayout(jobID, stageID, layout) { return false }
+	s.append(walRecord{Op: opStageLayout, JobID: jobID, StageID: stageID, Layout: layout})
+	return true
+}
+
+func (s *DurableStore) RemoveWorkerOutputs(jobID, stageID, workerID string) []common.TaskReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	lost := s.MemoryStore.RemoveWorkerOutputs(jobID, stageID, workerID)
+	if len(lost) > 0 { s.append(walRecord{Op: opRemoveOutputs, JobID: jobID, StageID: stageID, WorkerID: workerID}) }
+	return lost
+}
+
+// Generation devuelve cuántas veces arrancó el Master sobre este estado (incluido el actual)
+func (s *DurableStore) Generation() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.generation
+}
+
+// Fence detiene de inmediato las escrituras a disco: este Master perdió el liderazgo y otro puede estar
+// escribiendo el mismo estado. Los cambios posteriores quedan solo en memoria y Close ya no guarda snapshot.
+func (s *DurableStore) Fence() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fenced { return }
+	s.fenced = true
+	s.wal.Close()
+	log.Printf("[Store] Escrituras a %s detenidas: este Master ya no es el líder", s.dir)
+}
+
+// Close guarda un snapshot final y cierra el WAL
+func (s *DurableStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fenced { return nil }
+	err := s.writeSnapshot()
+	if cerr := s.wal.Close(); err == nil { err = cerr }
+	return err
+}
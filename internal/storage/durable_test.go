@@ -0,0 +1,144 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"mini-spark/internal/common"
+)
+
+func report(taskID, worker string) common.TaskReport {
+	return common.TaskReport{TaskID: taskID, JobID: "job-1", StageID: "map", Status: common.TaskStatusSuccess, WorkerID: worker}
+}
+
+// fillStore deja un Job con avance parcial: un stage completo que luego perdió una salida
+func fillStore(s JobStore) {
+	s.CreateJob(&common.JobRequest{JobID: "job-1", Name: "wordcount", Pool: "batch"})
+	s.UpdateJobStatus("job-1", common.JobStatusRunning)
+	s.AddTaskReport("job-1", "map", report("map-0", "w1"))
+	s.AddTaskReport("job-1", "map", report("map-1", "w2"))
+	s.MarkStageCompleted("job-1", "map")
+	s.SetStageLayout("job-1", "reduce", []common.ShuffleSlice{{Partitions: []int{0, 1}}})
+	s.MarkStageLaunched("job-1", "reduce")
+	s.RemoveWorkerOutputs("job-1", "map", "w1")
+	s.AddTaskReport("job-1", "map", report("map-0", "w3"))
+}
+
+// checkRecovered verifica que el estado reabierto sea el que dejó fillStore
+func checkRecovered(t *testing.T, s JobStore) {
+	t.Helper()
+	job := s.GetJob("job-1")
+	if job == nil { t.Fatalf("El Job no se recuperó") }
+	if job.Status != common.JobStatusRunning || job.Request.Pool != "batch" || job.StartTime == 0 {
+		t.Errorf("Job recuperado incorrecto: %+v", job)
+	}
+	if rep, ok := s.SuccessfulReport("job-1", "map", "map-0"); !ok || rep.WorkerID != "w3" {
+		t.Errorf("map-0 debe tener el reporte del recálculo en w3: %+v", rep)
+	}
+	if n := len(s.GetStageReports("job-1", "map")); n != 2 {
+		t.Errorf("Se esperaban 2 reportes del stage map, hay %d", n)
+	}
+	if s.IsStageCompleted("job-1", "map") || !s.IsStageLaunched("job-1", "reduce") {
+		t.Errorf("Avance de stages mal recuperado: %+v %+v", job.CompletedStages, job.LaunchedStages)
+	}
+	if layout := s.StageLayout("job-1", "reduce"); len(layout) != 1 || len(layout[0].Partitions) != 2 {
+		t.Errorf("Layout del stage reduce mal recuperado: %+v", layout)
+	}
+}
+
+func TestDurableStore_Recovery(t *testing.T) {
+	t.Run("Reaplica el WAL tras una caída", func(t *testing.T) {
+		dir := t.TempDir()
+		s, err := OpenDurableStore(dir)
+		if err != nil { t.Fatalf("Error abriendo el store: %v", err) }
+		fillStore(s)
+		// Sin Close: el proceso muere y solo queda el WAL
+
+		reopened, err := OpenDurableStore(dir)
+		if err != nil { t.Fatalf("Error reabriendo el store: %v", err) }
+		defer reopened.Close()
+		checkRecovered(t, reopened)
+		if reopened.Generation() != 2 {
+			t.Errorf("Se esperaba el arranque #2, obtuvo %d", reopened.Generation())
+		}
+	})
+
+	t.Run("Combina snapshot y WAL", func(t *testing.T) {
+		dir := t.TempDir()
+		s, err := OpenDurableStore(dir)
+		if err != nil { t.Fatalf("Error abriendo el store: %v", err) }
+		s.SnapshotEvery = 4 // El snapshot cae a mitad de fillStore
+		fillStore(s)
+		if _, err := os.Stat(filepath.Join(dir, snapshotFile)); err != nil {
+			t.Fatalf("Se esperaba un snapshot: %v", err)
+		}
+
+		reopened, err := OpenDurableStore(dir)
+		if err != nil { t.Fatalf("Error reabriendo el store: %v", err) }
+		checkRecovered(t, reopened)
+		if err := reopened.Close(); err != nil { t.Fatalf("Error cerrando: %v", err) }
+
+		// Tras Close todo queda en el snapshot
+		again, err := OpenDurableStore(dir)
+		if err != nil { t.Fatalf("Error reabriendo el store: %v", err) }
+		defer again.Close()
+		checkRecovered(t, again)
+	})
+
+	t.Run("Tras Fence no se escribe en disco", func(t *testing.T) {
+		dir := t.TempDir()
+		s, err := OpenDurableStore(dir)
+		if err != nil { t.Fatalf("Error abriendo el store: %v", err) }
+		fillStore(s)
+		s.Fence()
+		// El nuevo líder ya escribe el estado: lo que haga este Master después no debe llegar al WAL
+		s.UpdateJobStatus("job-1", common.JobStatusFailed)
+		if err := s.Close(); err != nil { t.Fatalf("Close tras Fence no debe fallar: %v", err) }
+		if _, err := os.Stat(filepath.Join(dir, snapshotFile)); !os.IsNotExist(err) {
+			t.Errorf("Close tras Fence no debe escribir snapshot")
+		}
+
+		reopened, err := OpenDurableStore(dir)
+		if err != nil { t.Fatalf("Error reabriendo el store: %v", err) }
+		defer reopened.Close()
+		checkRecovered(t, reopened)
+	})
+
+	t.Run("Descarta un registro a medio escribir", func(t *testing.T) {
+		dir := t.TempDir()
+		s, err := OpenDurableStore(dir)
+		if err != nil { t.Fatalf("Error abriendo el store: %v", err) }
+		fillStore(s)
+
+		f, err := os.OpenFile(filepath.Join(dir, walFile), os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil { t.Fatalf("Error abriendo el WAL: %v", err) }
+		f.WriteString(`{"seq":999,"op":"job_st`)
+		f.Close()
+
+		reopened, err := OpenDurableStore(dir)
+		if err != nil { t.Fatalf("Error reabriendo con un WAL cortado: %v", err) }
+		checkRecovered(t, reopened)
+		// Lo que se escribe después del corte se recupera en el siguiente arranque
+		reopened.UpdateJobStatus("job-1", common.JobStatusSucceeded)
+
+		last, err := OpenDurableStore(dir)
+		if err != nil { t.Fatalf("Error reabriendo el store: %v", err) }
+		defer last.Close()
+		if job := last.GetJob("job-1"); job == nil || job.Status != common.JobStatusSucceeded {
+			t.Errorf("El cambio posterior al corte se perdió: %+v", job)
+		}
+	})
+}
+
+func TestMemoryStore_ListJobs(t *testing.T) {
+	s := NewMemoryStore()
+	s.createJob(&common.JobRequest{JobID: "b"}, 20)
+	s.createJob(&common.JobRequest{JobID: "a"}, 10)
+	s.createJob(&common.JobRequest{JobID: "c"}, 20)
+	var ids []string
+	for _, job := range s.ListJobs() { ids = append(ids, job.Request.JobID) }
+	if len(ids) != 3 || ids[0] != "a" || ids[1] != "b" || ids[2] != "c" {
+		t.Errorf("Orden de llegada incorrecto: %v", ids)
+	}
+}
@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"sort"
 	"sync"
 	"time"
 	"mini-spark/internal/common"
@@ -13,38 +14,62 @@ type JobState struct {
 	StartTime    int64
 	StageReports map[string][]common.TaskReport // Map[StageID] -> Reports
 	TaskStatus   map[string]string            // Map[TaskID] -> Status
+	CompletedStages map[string]bool           // Map[StageID] -> true si todas sus tareas terminaron
+	LaunchedStages  map[string]bool           // Map[StageID] -> true si sus tareas ya se encolaron
+	StageLayouts    map[string][]common.ShuffleSlice // Map[StageID] -> shuffle que lee cada tarea (fijado al lanzarlo)
 }
 
-type JobStore struct {
+// MemoryStore guarda el estado de los Jobs solo en memoria (se pierde al reiniciar el Master)
+type MemoryStore struct {
 	mu   sync.RWMutex
 	Jobs map[string]*JobState
 }
 
-func NewJobStore() *JobStore {
-	return &JobStore{
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
 		Jobs: make(map[string]*JobState),
 	}
 }
 
-func (s *JobStore) CreateJob(req *common.JobRequest) {
+func (s *MemoryStore) CreateJob(req *common.JobRequest) {
+	s.createJob(req, time.Now().Unix())
+}
+
+func (s *MemoryStore) createJob(req *common.JobRequest, startTime int64) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.Jobs[req.JobID] = &JobState{
 		Request:      req,
 		Status:       common.JobStatusAccepted,
-		StartTime:    time.Now().Unix(),
+		StartTime:    startTime,
 		StageReports: make(map[string][]common.TaskReport),
 		TaskStatus:   make(map[string]string),
+		CompletedStages: make(map[string]bool),
+		LaunchedStages:  make(map[string]bool),
+		StageLayouts:    make(map[string][]common.ShuffleSlice),
 	}
 }
 
-func (s *JobStore) GetJob(jobID string) *JobState {
+func (s *MemoryStore) GetJob(jobID string) *JobState {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 	return s.Jobs[jobID]
 }
 
-func (s *JobStore) UpdateJobStatus(jobID, status string) {
+// ListJobs devuelve todos los Jobs en orden de llegada
+func (s *MemoryStore) ListJobs() []*JobState {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	jobs := make([]*JobState, 0, len(s.Jobs))
+	for _, job := range s.Jobs { jobs = append(jobs, job) }
+	sort.Slice(jobs, func(i, j int) bool {
+		if jobs[i].StartTime != jobs[j].StartTime { return jobs[i].StartTime < jobs[j].StartTime }
+		return jobs[i].Request.JobID < jobs[j].Request.JobID
+	})
+	return jobs
+}
+
+func (s *MemoryStore) UpdateJobStatus(jobID, status string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	if job, ok := s.Jobs[jobID]; ok {
@@ -52,23 +77,129 @@ func (s *JobStore) UpdateJobStatus(jobID, status string) {
 	}
 }
 
-func (s *JobStore) AddTaskReport(jobID, stageID string, report common.TaskReport) {
+// AddTaskReport registra el reporte de un intento. Cada partición (TaskID) registra un único éxito:
+// si ya tiene uno, el reporte (duplicado, o tardío de un intento reemplazado) se ignora y devuelve false.
+func (s *MemoryStore) AddTaskReport(jobID, stageID string, report common.TaskReport) bool {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	job, ok := s.Jobs[jobID]
-	if !ok { return }
+	if !ok { return false }
+	if job.TaskStatus[report.TaskID] == common.TaskStatusSuccess { return false }
 
 	job.TaskStatus[report.TaskID] = report.Status
 	if report.Status == common.TaskStatusSuccess {
 		job.StageReports[stageID] = append(job.StageReports[stageID], report)
 	}
+	return true
+}
+
+// SuccessfulReport devuelve el reporte exitoso registrado para una partición, si lo hay
+func (s *MemoryStore) SuccessfulReport(jobID, stageID, taskID string) (common.TaskReport, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if job, ok := s.Jobs[jobID]; ok {
+		for _, rep := range job.StageReports[stageID] {
+			if rep.TaskID == taskID { return rep, true }
+		}
+	}
+	return common.TaskReport{}, false
 }
 
-func (s *JobStore) GetStageReports(jobID, stageID string) []common.TaskReport {
+func (s *MemoryStore) GetStageReports(jobID, stageID string) []common.TaskReport {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 	if job, ok := s.Jobs[jobID]; ok {
 		return job.StageReports[stageID]
 	}
 	return nil
-}
\ No newline at end of file
+}
+
+// MarkStageCompleted marca un stage como terminado. Devuelve true solo la primera vez,
+// de modo que el Scheduler lance las etapas siguientes una única vez.
+func (s *MemoryStore) MarkStageCompleted(jobID, stageID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.Jobs[jobID]
+	if !ok || job.CompletedStages[stageID] { return false }
+	job.CompletedStages[stageID] = true
+	return true
+}
+
+func (s *MemoryStore) IsStageCompleted(jobID, stageID string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if job, ok := s.Jobs[jobID]; ok {
+		return job.CompletedStages[stageID]
+	}
+	return false
+}
+
+// MarkStageLaunched marca un stage como encolado. Devuelve true solo la primera vez: un padre que
+// vuelve a completarse tras recalcular particiones perdidas no relanza a sus hijos.
+func (s *MemoryStore) MarkStageLaunched(jobID, stageID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.Jobs[jobID]
+	if !ok || job.LaunchedStages[stageID] { return false }
+	job.LaunchedStages[stageID] = true
+	return true
+}
+
+func (s *MemoryStore) IsStageLaunched(jobID, stageID string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if job, ok := s.Jobs[jobID]; ok {
+		return job.LaunchedStages[stageID]
+	}
+	return false
+}
+
+// SetStageLayout fija qué parte del shuffle lee cada tarea de un stage. Devuelve true solo la primera
+// vez: los recálculos posteriores replanifican las mismas tareas aunque cambien los tamaños.
+func (s *MemoryStore) SetStageLayout(jobID, stageID string, layout []common.ShuffleSlice) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.Jobs[jobID]
+	if !ok || job.StageLayouts[stageID] != nil { return false }
+	if job.StageLayouts == nil { job.StageLayouts = make(map[string][]common.ShuffleSlice) } // Estado guardado antes de existir los layouts
+	job.StageLayouts[stageID] = layout
+	return true
+}
+
+// StageLayout devuelve el layout fijado de un stage (nil si todavía no se lanzó)
+func (s *MemoryStore) StageLayout(jobID, stageID string) []common.ShuffleSlice {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if job, ok := s.Jobs[jobID]; ok {
+		return job.StageLayouts[stageID]
+	}
+	return nil
+}
+
+// RemoveWorkerOutputs descarta los reportes exitosos de un stage producidos por un worker cuyas
+// salidas se perdieron. Si quita alguno, el stage deja de estar completado. Devuelve los quitados.
+func (s *MemoryStore) RemoveWorkerOutputs(jobID, stageID, workerID string) []common.TaskReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.Jobs[jobID]
+	if !ok { return nil }
+
+	var kept, lost []common.TaskReport
+	for _, rep := range job.StageReports[stageID] {
+		if rep.WorkerID == workerID {
+			lost = append(lost, rep)
+		} else {
+			kept = append(kept, rep)
+		}
+	}
+	if len(lost) == 0 { return nil }
+	job.StageReports[stageID] = kept
+	job.CompletedStages[stageID] = false
+	for _, rep := range lost { job.TaskStatus[rep.TaskID] = common.TaskStatusPending }
+	return lost
+}
+
+// Generation siempre es 0: el estado en memoria nace y muere con cada arranque
+func (s *MemoryStore) Generation() int { return 0 }
+
+func (s *MemoryStore) Close() error { return nil }
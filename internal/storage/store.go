@@ -0,0 +1,31 @@
+package storage
+
+import (
+	"mini-spark/internal/common"
+)
+
+// JobStore guarda el estado de los Jobs del Master: definición, estado, reportes exitosos
+// y avance de sus stages. MemoryStore lo mantiene solo en memoria; DurableStore además lo
+// persiste en disco (WAL + snapshots) para retomar los Jobs tras reiniciar el Master.
+type JobStore interface {
+	CreateJob(req *common.JobRequest)
+	GetJob(jobID string) *JobState
+	ListJobs() []*JobState // Todos los Jobs, en orden de llegada
+	UpdateJobStatus(jobID, status string)
+
+	AddTaskReport(jobID, stageID string, report common.TaskReport) bool
+	SuccessfulReport(jobID, stageID, taskID string) (common.TaskReport, bool)
+	GetStageReports(jobID, stageID string) []common.TaskReport
+	RemoveWorkerOutputs(jobID, stageID, workerID string) []common.TaskReport
+
+	MarkStageCompleted(jobID, stageID string) bool
+	IsStageCompleted(jobID, stageID string) bool
+	MarkStageLaunched(jobID, stageID string) bool
+	IsStageLaunched(jobID, stageID string) bool
+	SetStageLayout(jobID, stageID string, layout []common.ShuffleSlice) bool
+	StageLayout(jobID, stageID string) []common.ShuffleSlice
+
+	// Generation cuenta los arranques del Master sobre este estado, incluido el actual (0 = solo en memoria)
+	Generation() int
+	Close() error
+}
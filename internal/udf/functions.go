@@ -3,8 +3,9 @@ package udf
 import (
 	"encoding/json"
 	"fmt"
+	"path/filepath"
+	"strconv"
 	"strings"
-	//!"time"
 	"mini-spark/internal/common"
 )
 
@@ -16,6 +17,8 @@ type UDFReduceFn func(string, []string) Record
 type UDFFlatMapFn func(Record) []Record 
 // UDFJoinFn recibe key, lista de valores izq, lista de valores der
 type UDFJoinFn func(key string, left []string, right []string) []Record
+// UDFJoinPairFn formatea un par ya cruzado por el motor. En joins externos el lado ausente llega como nil.
+type UDFJoinPairFn func(key string, left *string, right *string) Record
 
 var UDFRegistry = map[string]interface{}{
 	"to_uppercase": UDFMapFn(func(r Record) []Record {
@@ -44,6 +47,16 @@ var UDFRegistry = map[string]interface{}{
 		}
 		return res
 	}),
+	"join_pair_concat": UDFJoinPairFn(func(key string, left *string, right *string) Record {
+		// Ejemplo Join por pares: el lado ausente (outer join) se escribe como "null"
+		side := func(v *string) string {
+			if v == nil { return "null" }
+			return *v
+		}
+		kv := common.KeyValue{Key: key, Value: fmt.Sprintf("%s|%s", side(left), side(right))}
+		b, _ := json.Marshal(kv)
+		return Record(b)
+	}),
 	"map_wordcount": UDFMapFn(func(r Record) []Record {
 		clean := strings.Map(func(r rune) rune {
 			if strings.ContainsRune(".,;?!-", r) { return -1 }
@@ -65,56 +78,42 @@ var UDFRegistry = map[string]interface{}{
 		b, _ := json.Marshal(kv)
 		return Record(b)
 	}),
+	// Combina conteos parciales de una clave (combine_udf de reduce_sum al dividir claves calientes)
+	"reduce_add": UDFReduceFn(func(key string, values []string) Record {
+		total := 0
+		for _, v := range values {
+			n, _ := strconv.Atoi(v)
+			total += n
+		}
+		kv := common.KeyValue{Key: key, Value: fmt.Sprintf("%d", total)}
+		b, _ := json.Marshal(kv)
+		return Record(b)
+	}),
 	//Funciones para JOIN
-	// MAP: Lee líneas CSV y etiqueta según el tipo
-	// Entrada esperada: "U,1,Alice"  o  "O,100,1,Laptop"
-	"map_parse_tables": UDFMapFn(func(r Record) []Record {
-		line := string(r)
-		parts := strings.Split(line, ",")
+	// MAP multi-fuente: cada tabla llega en su propio archivo y el Executor sabe de qué lado viene cada valor
+	// Entrada esperada: "ID,Nombre" -> Key: ID, Value: Nombre
+	"map_parse_users": UDFMapFn(func(r Record) []Record {
+		parts := strings.Split(string(r), ",")
+		if len(parts) < 2 { return nil }
+		kv := common.KeyValue{Key: parts[0], Value: parts[1]}
+		b, _ := json.Marshal(kv)
+		return []Record{Record(b)}
+	}),
+	// Entrada esperada: "OrderID,UserID,Producto" -> Key: UserID, Value: Producto
+	"map_parse_orders": UDFMapFn(func(r Record) []Record {
+		parts := strings.Split(string(r), ",")
 		if len(parts) < 3 { return nil }
-
-		tipo := parts[0] // U (User) o O (Order)
-		var key, val string
-
-		if tipo == "U" {
-			// Formato: U,ID,Name -> Key: ID, Value: L:Name
-			key = parts[1]
-			val = "L:" + parts[2]
-		} else if tipo == "O" {
-			// Formato: O,OrderID,UserID,Product -> Key: UserID, Value: R:Product
-			key = parts[2]
-			val = "R:" + parts[3]
-		} else {
-			return nil
-		}
-
-		kv := common.KeyValue{Key: key, Value: val}
+		kv := common.KeyValue{Key: parts[1], Value: parts[2]}
 		b, _ := json.Marshal(kv)
 		return []Record{Record(b)}
 	}),
 
-	// JOIN: Recibe valores mezclados, los separa y cruza
+	// JOIN: left = usuarios, right = productos (separados por el Executor)
 	"join_users_orders": UDFJoinFn(func(key string, left []string, right []string) []Record {
-		// NOTA: Nuestro Executor actual pasa todo en la lista 'left' (values).
-		// Debemos separar manualmente aquí.
-		var users []string
-		var products []string
-
-		// Separar L (Usuarios) y R (Productos)
-		// Executor pasa 'left' como la lista combinada de valores
-		allValues := left 
-		for _, v := range allValues {
-			if strings.HasPrefix(v, "L:") {
-				users = append(users, v[2:]) // Quitar prefijo "L:"
-			} else if strings.HasPrefix(v, "R:") {
-				products = append(products, v[2:]) // Quitar prefijo "R:"
-			}
-		}
-
 		// INNER JOIN: Producto Cartesiano
 		var results []Record
-		for _, u := range users {
-			for _, p := range products {
+		for _, u := range left {
+			for _, p := range right {
 				// Salida: "Usuario compró Producto"
 				finalStr := fmt.Sprintf("%s compro %s", u, p)
 				kv := common.KeyValue{Key: key, Value: finalStr}
@@ -124,6 +123,15 @@ var UDFRegistry = map[string]interface{}{
 		}
 		return results
 	}),
+	// JOIN por pares: sirve para JOIN y para los joins externos (usuarios sin pedidos, pedidos huérfanos)
+	"join_users_orders_pair": UDFJoinPairFn(func(key string, left *string, right *string) Record {
+		user, product := "(sin usuario)", "(nada)"
+		if left != nil { user = *left }
+		if right != nil { product = *right }
+		kv := common.KeyValue{Key: key, Value: fmt.Sprintf("%s compro %s", user, product)}
+		b, _ := json.Marshal(kv)
+		return Record(b)
+	}),
 	// Filtra CSV donde la edad (columna 2) sea >= 18
     "filter_adults": UDFFilterFn(func(r Record) bool {
         parts := strings.Split(string(r), ",")
@@ -132,6 +140,13 @@ var UDFRegistry = map[string]interface{}{
         fmt.Sscanf(parts[2], "%d", &age)
         return age >= 18
     }),
+    // Entrada con include_file_name: "archivo\tlínea" -> Key: nombre del archivo, Value: 1 (con reduce_sum = líneas por archivo)
+    "map_lines_per_file": UDFMapFn(func(r Record) []Record {
+        file, _ := SplitFileRecord(r)
+        kv := common.KeyValue{Key: filepath.Base(file), Value: "1"}
+        b, _ := json.Marshal(kv)
+        return []Record{Record(b)}
+    }),
     // Identidad: Pasa el registro tal cual (útil para encadenar)
     "map_identity": UDFMapFn(func(r Record) []Record {
         // Retornamos clave "keep" para agrupar todo o el mismo contenido
@@ -142,6 +157,14 @@ var UDFRegistry = map[string]interface{}{
     }),
 }
 
+// SplitFileRecord separa un registro leído con include_file_name ("archivo\tlínea") en sus dos partes.
+// Si el registro no trae archivo, file queda vacío y line es el registro completo.
+func SplitFileRecord(r Record) (file string, line Record) {
+	f, l, ok := strings.Cut(string(r), "\t")
+	if !ok { return "", r }
+	return f, Record(l)
+}
+
 // Helpers para obtener funciones con cast seguro
 func GetMapFunction(name string) (UDFMapFn, error) {
 	if fn, ok := UDFRegistry[name].(UDFMapFn); ok { return fn, nil }
@@ -162,4 +185,8 @@ func GetReduceFunction(name string) (UDFReduceFn, error) {
 func GetJoinFunction(name string) (UDFJoinFn, error) {
 	if fn, ok := UDFRegistry[name].(UDFJoinFn); ok { return fn, nil }
 	return nil, fmt.Errorf("join function %s not found", name)
-}
\ No newline at end of file
+}
+func GetJoinPairFunction(name string) (UDFJoinPairFn, error) {
+	if fn, ok := UDFRegistry[name].(UDFJoinPairFn); ok { return fn, nil }
+	return nil, fmt.Errorf("join pair function %s not found", name)
+}
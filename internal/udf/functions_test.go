@@ -1,7 +1,10 @@
 package udf
 
 import (
+	"encoding/json"
 	"testing"
+
+	"mini-spark/internal/common"
 	// SE ELIMINA: "strings" importado y no usado
 )
 
@@ -56,6 +59,21 @@ func TestUDFImplementations(t *testing.T) {
 			})
 		}
 	})
+
+	// --- Test de UDF Reduce: reduce_add combina los conteos parciales de reduce_sum ---
+	t.Run("Reduce_add_combina_reduce_sum", func(t *testing.T) {
+		count := UDFRegistry["reduce_sum"].(UDFReduceFn)
+		add := UDFRegistry["reduce_add"].(UDFReduceFn)
+
+		whole := count("k", []string{"a", "b", "c", "d", "e"})
+		partA, partB := count("k", []string{"a", "b"}), count("k", []string{"c", "d", "e"})
+		var kvA, kvB common.KeyValue
+		json.Unmarshal([]byte(partA), &kvA)
+		json.Unmarshal([]byte(partB), &kvB)
+		if merged := add("k", []string{kvA.Value, kvB.Value}); merged != whole {
+			t.Errorf("reduce_add de los parciales = %s, reduce_sum completo = %s", merged, whole)
+		}
+	})
 }
 
 // TestGetUDFFunctions verifica la recuperación de funciones y el manejo de errores.
@@ -2,11 +2,18 @@ package worker
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"os"
+	"sort"
 	"runtime" // NECESARIO PARA MÉTRICAS REALES
+	"strconv"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -18,34 +25,65 @@ import (
 // =========================================================
 
 var (
-	MasterURL   string
-	MyID        string
-	activeTasks int32
+	Masters       *common.MasterList // Masters conocidos (líder y standby); se sigue al líder
+	MyID          string
+	PoolSize      = 4    // Hilos del pool (tareas simultáneas); se anuncian al Master como slots
+	MemCapacityMB uint64 // Memoria anunciada al Master para tareas (0 = sin límite)
+	activeTasks   int32
 )
 
+// Tareas en curso (para poder abortarlas por Job) y Jobs que el Master canceló
+var (
+	tasksMu       sync.Mutex
+	runningTasks  = make(map[taskAttempt]runningTask) // Intento -> tarea en curso
+	cancelledJobs = make(map[string]time.Time)   // JobID -> momento de la cancelación (se olvida tras CancelledJobTTL)
+)
+
+// taskAttempt identifica un intento: un worker puede correr dos intentos de la misma tarea
+// (un reintento tras darse por muerto o por timeout mientras el anterior sigue en curso)
+type taskAttempt struct {
+	taskID  string
+	attempt int
+}
+
+type runningTask struct {
+	jobID   string
+	cancel  context.CancelFunc
+}
+
 // Constantes de configuración
 const (
 	HeartbeatInterval = 3 * time.Second
 	ShufflePathPrefix = "/shuffle"
 	// REQUERIMIENTO PDF: Límite de tiempo por tarea.
-	// En prod esto vendría en el JobRequest, aquí usamos un default seguro.
+	// Default si el Job no declara task_timeout_sec.
 	TaskTimeout       = 60 * time.Second 
+	// Reintentos de un reporte si no hay Master líder (p. ej. durante un cambio de líder)
+	ReportRetries       = 10
+	ReportRetryInterval = 2 * time.Second
+	// Tiempo que se recuerda un Job cancelado para rechazar asignaciones tardías. Pasado ese plazo
+	// basta con el Master: ya no despacha sus tareas y reenvía el aborto si el worker vuelve a anunciarlo.
+	CancelledJobTTL = 10 * time.Minute
 )
 
 // =========================================================
 // INICIO DEL SERVIDOR
 // =========================================================
 
-func StartServer(port int, masterAddress string) {
-	MasterURL = masterAddress
+func StartServer(port int, masterAddress string, slots int, memCapacityMB uint64) {
+	Masters = common.NewMasterList(masterAddress)
 	MyID = fmt.Sprintf("localhost:%d", port)
+	if slots > 0 { PoolSize = slots }
+	MemCapacityMB = memCapacityMB
 
-	// Inicializar el Ejecutor con 4 hilos (Requerimiento: Pool Configurable)
-	InitExecutor(4)
+	// Inicializar el Ejecutor (Requerimiento: Pool Configurable)
+	InitExecutor(PoolSize)
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("POST /tasks", HandleTaskAssignment)
 	mux.HandleFunc("GET "+ShufflePathPrefix, handleShuffleFetch)
+	mux.HandleFunc("DELETE /jobs/{id}", HandleAbortJob)
+	mux.HandleFunc("DELETE /tasks/{id}", HandleKillTask)
 	
 	mux.HandleFunc("GET /health", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
@@ -54,7 +92,7 @@ func StartServer(port int, masterAddress string) {
 
 	go startHeartbeatLoop()
 
-	log.Printf("[Worker %s] Listo en :%d (Pool: 4 threads, Timeout: %s)", MyID, port, TaskTimeout)
+	log.Printf("[Worker %s] Listo en :%d (Pool: %d threads, Memoria: %d MB, Timeout: %s)", MyID, port, PoolSize, MemCapacityMB, TaskTimeout)
 	if err := http.ListenAndServe(fmt.Sprintf(":%d", port), mux); err != nil {
 		log.Fatal(err)
 	}
@@ -74,15 +112,117 @@ func HandleTaskAssignment(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "JSON inválido", 400); return
 	}
 
+	// Registrar la tarea antes de responder: una cancelación que llegue después ya la encuentra
+	ctx, ok := registerTask(task)
+	if !ok {
+		http.Error(w, "Job cancelado", http.StatusConflict); return
+	}
+
 	atomic.AddInt32(&activeTasks, 1)
 	log.Printf("[Worker] Recibida tarea %s (Op: %s)", task.TaskID, task.Operation.Type)
 
 	// Ejecución asíncrona para no bloquear al Master
-	go runTaskAsync(task)
+	go runTaskAsync(ctx, task)
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// DELETE /jobs/{id}: el Master canceló el Job. Se abortan sus tareas en curso,
+// se rechazan las que lleguen después y se borran sus archivos de shuffle.
+func HandleAbortJob(w http.ResponseWriter, r *http.Request) {
+	jobID := r.PathValue("id")
+	if !common.IsSafeJobID(jobID) {
+		http.Error(w, "Job ID inválido", 400); return
+	}
 
+	tasksMu.Lock()
+	cancelledJobs[jobID] = time.Now()
+	aborted := 0
+	for _, rt := range runningTasks {
+		if rt.jobID == jobID {
+			rt.cancel()
+			aborted++
+		}
+	}
+	tasksMu.Unlock()
+
+	if err := os.RemoveAll(common.JobShuffleDir(jobID)); err != nil {
+		log.Printf("[Worker] No se pudo borrar el shuffle de %s: %v", jobID, err)
+	}
+	log.Printf("[Worker] Job %s cancelado por el Master: %d tareas abortadas", jobID, aborted)
+	w.WriteHeader(http.StatusOK)
+}
+
+// DELETE /tasks/{id}?attempt=N: otro intento de la tarea ya terminó (ejecución especulativa).
+// Se aborta sin reportar solo el intento indicado: los demás intentos de la tarea siguen su curso.
+func HandleKillTask(w http.ResponseWriter, r *http.Request) {
+	taskID := r.PathValue("id")
+	attempt, err := strconv.Atoi(r.URL.Query().Get("attempt"))
+	if err != nil {
+		http.Error(w, "Falta el intento a abortar", 400); return
+	}
+
+	tasksMu.Lock()
+	rt, ok := runningTasks[taskAttempt{taskID, attempt}]
+	if ok { rt.cancel() }
+	tasksMu.Unlock()
+
+	if !ok {
+		http.Error(w, "Intento no encontrado", 404); return
+	}
+	log.Printf("[Worker] Tarea %s (intento %d) abortada: otro intento terminó antes", taskID, attempt)
 	w.WriteHeader(http.StatusOK)
 }
 
+// registerTask da de alta una tarea en curso. Devuelve false si su Job ya fue cancelado.
+func registerTask(task common.Task) (context.Context, bool) {
+	tasksMu.Lock()
+	defer tasksMu.Unlock()
+	if _, cancelled := cancelledJobs[task.JobID]; cancelled { return nil, false }
+	ctx, cancel := context.WithCancel(context.Background())
+	runningTasks[taskAttempt{task.TaskID, task.Attempt}] = runningTask{jobID: task.JobID, cancel: cancel}
+	return ctx, true
+}
+
+// pruneCancelledJobs olvida los Jobs cancelados hace más de CancelledJobTTL
+func pruneCancelledJobs(now time.Time) {
+	tasksMu.Lock()
+	defer tasksMu.Unlock()
+	for jobID, at := range cancelledJobs {
+		if now.Sub(at) >= CancelledJobTTL { delete(cancelledJobs, jobID) }
+	}
+}
+
+// heldJobs lista los Jobs de los que este worker guarda estado: tareas en curso o archivos de shuffle.
+// Viaja en el heartbeat para que el Master reenvíe el aborto de los que ya cancelló.
+func heldJobs() []string {
+	seen := make(map[string]bool)
+	tasksMu.Lock()
+	for _, rt := range runningTasks { seen[rt.jobID] = true }
+	tasksMu.Unlock()
+	if entries, err := os.ReadDir(common.ShuffleRoot); err == nil {
+		for _, e := range entries {
+			if e.IsDir() { seen[e.Name()] = true }
+		}
+	}
+
+	jobs := make([]string, 0, len(seen))
+	for jobID := range seen { jobs = append(jobs, jobID) }
+	sort.Strings(jobs)
+	return jobs
+}
+
+// unregisterTask da de baja el intento al terminar, sin tocar otros intentos de la misma tarea
+func unregisterTask(task common.Task) {
+	tasksMu.Lock()
+	defer tasksMu.Unlock()
+	key := taskAttempt{task.TaskID, task.Attempt}
+	if rt, ok := runningTasks[key]; ok {
+		rt.cancel()
+		delete(runningTasks, key)
+	}
+}
+
 func handleShuffleFetch(w http.ResponseWriter, r *http.Request) {
 	path := r.URL.Query().Get("path")
 	if path == "" {
@@ -90,15 +230,42 @@ func handleShuffleFetch(w http.ResponseWriter, r *http.Request) {
 	}
 	// TODO: Validar que el path sea seguro (dentro de /tmp/mini-spark)
 	log.Printf("[Shuffle] Sirviendo %s a %s", path, r.RemoteAddr)
-	http.ServeFile(w, r, path)
+	if r.URL.Query().Get("start") == "" && r.URL.Query().Get("end") == "" {
+		http.ServeFile(w, r, path)
+		return
+	}
+
+	// Tramo [start, end) de un bloque ordenado (lo pide un SORT_BY_KEY para leer solo su rango de claves)
+	start, err1 := strconv.ParseInt(r.URL.Query().Get("start"), 10, 64)
+	end, err2 := strconv.ParseInt(r.URL.Query().Get("end"), 10, 64)
+	if err1 != nil || err2 != nil || start < 0 || end < start {
+		http.Error(w, "Invalid range", 400); return
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		http.Error(w, "Not found", 404); return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		http.Error(w, err.Error(), 500); return
+	}
+	http.ServeContent(w, r, path, info.ModTime(), io.NewSectionReader(f, start, end-start))
 }
 
 // =========================================================
 // LÓGICA DE EJECUCIÓN CON TIMEOUT (Requerimiento PDF)
 // =========================================================
 
-func runTaskAsync(task common.Task) {
+// taskTimeout devuelve el límite de ejecución de la tarea: el del Job o el default del worker
+func taskTimeout(task common.Task) time.Duration {
+	if task.TimeoutSec > 0 { return time.Duration(task.TimeoutSec) * time.Second }
+	return TaskTimeout
+}
+
+func runTaskAsync(ctx context.Context, task common.Task) {
 	defer atomic.AddInt32(&activeTasks, -1)
+	defer unregisterTask(task)
 	
 	// Canales para manejar resultado o timeout
 	done := make(chan struct{})
@@ -106,10 +273,14 @@ func runTaskAsync(task common.Task) {
 	var err error
 	
 	startTime := time.Now()
+	timeout := taskTimeout(task)
+	execCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
 
 	go func() {
-		// Esta llamada bloquea hasta que el Pool tenga espacio y la tarea termine
-		outputMeta, err = GlobalExecutor.Submit(task)
+		// Esta llamada bloquea hasta que el Pool tenga espacio y la tarea termine.
+		// Al cancelarse execCtx el ejecutor se detiene en el siguiente registro y libera su hilo.
+		outputMeta, err = GlobalExecutor.Submit(execCtx, task)
 		close(done)
 	}()
 
@@ -119,12 +290,17 @@ func runTaskAsync(task common.Task) {
 		JobID:     task.JobID,
 		StageID:   task.StageID,
 		WorkerID:  MyID,
+		Attempt:   task.Attempt,
 		Timestamp: time.Now().Unix(),
 	}
 
-	// SELECT: Esperar terminación O Timeout
+	// SELECT: Esperar terminación, cancelación del Job O Timeout
 	select {
 	case <-done:
+		if ctx.Err() != nil {
+			log.Printf("[Worker] Tarea %s ABORTADA por el Master (Job %s)", task.TaskID, task.JobID)
+			return
+		}
 		duration := time.Since(startTime).Milliseconds()
 		report.DurationMs = duration
 		
@@ -132,6 +308,11 @@ func runTaskAsync(task common.Task) {
 			log.Printf("[Worker] Tarea %s FALLÓ tras %dms: %v", task.TaskID, duration, err)
 			report.Status = common.TaskStatusFailure
 			report.ErrorMsg = err.Error()
+			// Shuffle perdido: se indica cuál para que el Master lo recalcule antes de reintentar
+			var ff *FetchFailedError
+			if errors.As(err, &ff) {
+				report.FetchFailure = &common.ShuffleFetchFailure{WorkerID: ff.WorkerID, Path: ff.Path}
+			}
 		} else {
 			log.Printf("[Worker] Tarea %s ÉXITO en %dms", task.TaskID, duration)
 			report.Status = common.TaskStatusSuccess
@@ -142,14 +323,20 @@ func runTaskAsync(task common.Task) {
 			}
 		}
 
-	case <-time.After(TaskTimeout):
+	case <-execCtx.Done():
+		if ctx.Err() != nil {
+			// CASO CANCELACIÓN: el Master ya descartó el intento (Job cancelado u otro intento ganó), no se reporta
+			log.Printf("[Worker] Tarea %s ABORTADA por el Master (Job %s)", task.TaskID, task.JobID)
+			return
+		}
 		// CASO TIMEOUT (Requerimiento PDF: Terminación si excede tiempo)
-		log.Printf("[Worker] Tarea %s EXPIRÓ (Timeout > %s)", task.TaskID, TaskTimeout)
+		log.Printf("[Worker] Tarea %s EXPIRÓ (Timeout > %s)", task.TaskID, timeout)
 		report.Status = common.TaskStatusFailure
-		report.ErrorMsg = fmt.Sprintf("Timeout execution limit exceeded (%s)", TaskTimeout)
+		report.ErrorMsg = fmt.Sprintf("Timeout execution limit exceeded (%s)", timeout)
 		report.DurationMs = time.Since(startTime).Milliseconds()
-		// Nota: En Go las goroutines no se pueden "matar" forzosamente desde fuera fácilmente 
-		// sin cooperacion, pero al menos reportamos el fallo y liberamos al Master.
+		// El ejecutor coopera: revisa execCtx entre registros y en las descargas de shuffle,
+		// borra su salida parcial y libera el hilo del pool. Una UDF bloqueada en un único
+		// registro solo se detiene al devolver ese registro.
 	}
 
 	// Enviar reporte
@@ -161,12 +348,26 @@ func runTaskAsync(task common.Task) {
 // Variable para Mocking en tests
 var ReportToMaster = func(report common.TaskReport) error {
 	data, _ := json.Marshal(report)
-	resp, err := http.Post(MasterURL+"/report", "application/json", bytes.NewBuffer(data))
+	resp, err := postToMaster("/report", data)
+	for retry := 1; err != nil && retry <= ReportRetries; retry++ {
+		log.Printf("[Worker] Sin Master líder para reportar %s (%v), reintento %d/%d", report.TaskID, err, retry, ReportRetries)
+		time.Sleep(ReportRetryInterval)
+		resp, err = postToMaster("/report", data)
+	}
 	if err != nil { return err }
 	defer resp.Body.Close()
 	return nil
 }
 
+// postToMaster envía un POST al Master líder (si el conocido cayó o pasó a standby, prueba los demás)
+func postToMaster(path string, data []byte) (*http.Response, error) {
+	return Masters.Do(func(base string) (*http.Request, error) {
+		req, err := http.NewRequest(http.MethodPost, base+path, bytes.NewReader(data))
+		if err == nil { req.Header.Set("Content-Type", "application/json") }
+		return req, err
+	})
+}
+
 // =========================================================
 // HEARTBEAT CON MÉTRICAS REALES (Requerimiento PDF)
 // =========================================================
@@ -175,30 +376,41 @@ func startHeartbeatLoop() {
 	ticker := time.NewTicker(HeartbeatInterval)
 	defer ticker.Stop()
 
+	// El primer heartbeat registra al worker (y su capacidad) sin esperar al ticker
+	sendHeartbeat()
 	for range ticker.C {
-		// 1. Obtener Métricas de Memoria Reales
-		var m runtime.MemStats
-		runtime.ReadMemStats(&m)
-		// Convertir bytes a MB
-		memUsageMB := m.Alloc / 1024 / 1024
-
-		hb := common.Heartbeat{
-			WorkerID:      MyID,
-			Address:       MyID,
-			Status:        determineStatus(),
-			ActiveTasks:   int(atomic.LoadInt32(&activeTasks)),
-			MemUsageMB:    memUsageMB, // Dato real
-			LastHeartbeat: time.Now().Unix(),
-		}
+		sendHeartbeat()
+	}
+}
 
-		data, _ := json.Marshal(hb)
-		// Ignoramos error de heartbeat (es best-effort)
-		http.Post(MasterURL+"/heartbeat", "application/json", bytes.NewBuffer(data))
+func sendHeartbeat() {
+	// 1. Obtener Métricas de Memoria Reales
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	// Convertir bytes a MB
+	memUsageMB := m.Alloc / 1024 / 1024
+
+	hb := common.Heartbeat{
+		WorkerID:      MyID,
+		Address:       MyID,
+		Status:        determineStatus(),
+		ActiveTasks:   int(atomic.LoadInt32(&activeTasks)),
+		MemUsageMB:    memUsageMB, // Dato real
+		Slots:         PoolSize,
+		MemCapacityMB: MemCapacityMB,
+		LastHeartbeat: time.Now().Unix(),
+		Jobs:          heldJobs(),
 	}
+	pruneCancelledJobs(time.Now())
+
+	data, _ := json.Marshal(hb)
+	// Ignoramos error de heartbeat (es best-effort)
+	resp, err := postToMaster("/heartbeat", data)
+	if err == nil { resp.Body.Close() }
 }
 
 func determineStatus() string {
-	if atomic.LoadInt32(&activeTasks) >= 4 { // Si el pool está lleno
+	if atomic.LoadInt32(&activeTasks) >= int32(PoolSize) { // Si el pool está lleno
 		return common.WorkerStatusBusy
 	}
 	return common.WorkerStatusIdle
@@ -164,5 +164,226 @@ func TestAPI_HandleShuffleFetch(t *testing.T) {
 	if rr.Body.String() != "contenido secreto" {
 		t.Errorf("Contenido incorrecto. Obtenido: %s", rr.Body.String())
 	}
+
+	// Tramo de un bloque ordenado (SORT_BY_KEY)
+	rr = httptest.NewRecorder()
+	handleShuffleFetch(rr, httptest.NewRequest("GET", "/shuffle?path="+tempFile+"&start=10&end=17", nil))
+	if rr.Code != http.StatusOK || rr.Body.String() != "secreto" {
+		t.Errorf("Tramo incorrecto. Código %d, obtenido: %s", rr.Code, rr.Body.String())
+	}
+}
+	*/
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"mini-spark/internal/common"
+)
+
+func TestWorkerAPI_AbortJob(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /tasks", HandleTaskAssignment)
+	mux.HandleFunc("DELETE /jobs/{id}", HandleAbortJob)
+
+	jobID := "job-abort-test"
+	shuffleDir := common.JobShuffleDir(jobID)
+	defer func() {
+		os.RemoveAll(common.ShuffleRoot)
+		os.Remove(filepath.Dir(common.ShuffleRoot)) // ./data, solo si quedó vacío
+	}()
+	os.MkdirAll(filepath.Join(shuffleDir, "stage-1"), 0755)
+	os.WriteFile(filepath.Join(shuffleDir, "stage-1", "part-0"), []byte("x"), 0644)
+
+	ctx, ok := registerTask(common.Task{TaskID: "t-1", JobID: jobID})
+	if !ok { t.Fatal("La tarea debió registrarse") }
+	defer unregisterTask(common.Task{TaskID: "t-1"})
+	otherCtx, _ := registerTask(common.Task{TaskID: "t-2", JobID: "job-sigue"})
+	defer unregisterTask(common.Task{TaskID: "t-2"})
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("DELETE", "/jobs/"+jobID, nil))
+	if rr.Code != 200 { t.Fatalf("Se esperaba 200, obtuvo %d", rr.Code) }
+
+	select {
+	case <-ctx.Done():
+	default:
+		t.Errorf("La tarea del Job cancelado no fue abortada")
+	}
+	if otherCtx.Err() != nil { t.Errorf("Se abortó una tarea de otro Job") }
+	if _, err := os.Stat(shuffleDir); !os.IsNotExist(err) {
+		t.Errorf("El shuffle del Job cancelado debía borrarse")
+	}
+
+	// Las asignaciones que lleguen después de la cancelación se rechazan
+	body, _ := json.Marshal(common.Task{TaskID: "t-3", JobID: jobID})
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("POST", "/tasks", bytes.NewReader(body)))
+	if rr.Code != http.StatusConflict { t.Errorf("Se esperaba 409 para un Job cancelado, obtuvo %d", rr.Code) }
+
+	// El heartbeat anuncia los Jobs con tareas en curso para que el Master reenvíe abortos perdidos
+	if jobs := heldJobs(); strings.Join(jobs, ",") != "job-abort-test,job-sigue" {
+		t.Errorf("Jobs anunciados incorrectos: %v", jobs)
+	}
+
+	// Pasado el TTL el Job cancelado se olvida y no crece la memoria del worker
+	pruneCancelledJobs(time.Now().Add(CancelledJobTTL))
+	tasksMu.Lock()
+	_, remembered := cancelledJobs[jobID]
+	tasksMu.Unlock()
+	if remembered { t.Errorf("El Job cancelado debía olvidarse tras CancelledJobTTL") }
+
+	// Un ID con ruta no debe permitir borrar fuera del directorio de shuffle
+	req := httptest.NewRequest("DELETE", "/jobs/x", nil)
+	req.SetPathValue("id", "../inputs")
+	rr = httptest.NewRecorder()
+	HandleAbortJob(rr, req)
+	if rr.Code != 400 { t.Errorf("Se esperaba 400 para un Job ID con ruta, obtuvo %d", rr.Code) }
+}
+
+func TestWorker_TaskTimeoutFromJob(t *testing.T) {
+	if got := taskTimeout(common.Task{}); got != TaskTimeout {
+		t.Errorf("Sin timeout del Job se esperaba el default %s, obtuvo %s", TaskTimeout, got)
+	}
+
+	// Shuffle colgado: la tarea solo puede terminar por su timeout
+	stuck := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer stuck.Close()
+
+	reports := make(chan common.TaskReport, 1)
+	original := ReportToMaster
+	ReportToMaster = func(report common.TaskReport) error { reports <- report; return nil }
+	defer func() { ReportToMaster = original }()
+
+	task := common.Task{
+		TaskID: "job-timeout-reduce-0", JobID: "job-timeout", StageID: "reduce", TimeoutSec: 1,
+		Operation:      common.OperationNode{Type: common.OpTypeReduceByKey, UDFName: "reduce_sum"},
+		InputPartition: common.TaskInput{SourceType: common.SourceTypeShuffle, ShuffleMap: map[string]string{"w1": stuck.URL}},
+		OutputTarget:   common.TaskOutput{Type: common.OutputTypeShuffle, Path: filepath.Join(t.TempDir(), "shuffle"), NumPartitions: 1},
+	}
+	ctx, ok := registerTask(task)
+	if !ok { t.Fatal("La tarea debió registrarse") }
+	go runTaskAsync(ctx, task)
+
+	select {
+	case rep := <-reports:
+		if rep.Status != common.TaskStatusFailure || !strings.Contains(rep.ErrorMsg, "Timeout") {
+			t.Errorf("Se esperaba un reporte de timeout, obtuvo %+v", rep)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("La tarea no expiró con el timeout del Job")
+	}
+
+	// El hilo del pool se libera: la tarea no queda zombi
+	deadline := time.Now().Add(2 * time.Second)
+	for len(GlobalExecutor.semaphore) > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if busy := len(GlobalExecutor.semaphore); busy != 0 {
+		t.Errorf("La tarea expirada sigue ocupando %d hilos del pool", busy)
+	}
+}
+
+func TestWorkerAPI_KillTaskAttempt(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("DELETE /tasks/{id}", HandleKillTask)
+
+	ctx, ok := registerTask(common.Task{TaskID: "t-spec", JobID: "job-spec", Attempt: 7})
+	if !ok { t.Fatal("La tarea debió registrarse") }
+	defer unregisterTask(common.Task{TaskID: "t-spec", Attempt: 7})
+
+	// Sin intento no se aborta "cualquiera": podría ser el que debe seguir
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("DELETE", "/tasks/t-spec", nil))
+	if rr.Code != http.StatusBadRequest || ctx.Err() != nil {
+		t.Fatalf("Se abortó un intento sin indicarlo (código %d)", rr.Code)
+	}
+
+	// Otro intento de la misma tarea: no se toca
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("DELETE", "/tasks/t-spec?attempt=3", nil))
+	if rr.Code != http.StatusNotFound || ctx.Err() != nil {
+		t.Fatalf("Se abortó un intento distinto al pedido (código %d)", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("DELETE", "/tasks/t-spec?attempt=7", nil))
+	if rr.Code != http.StatusOK || ctx.Err() == nil {
+		t.Errorf("El intento perdedor no fue abortado (código %d)", rr.Code)
+	}
+}
+
+func TestWorker_TwoAttemptsOfSameTask(t *testing.T) {
+	// El intento 2 espera su shuffle hasta que el intento 1 haya terminado
+	dir := t.TempDir()
+	block := filepath.Join(dir, "block")
+	os.WriteFile(block, []byte(`{"key":"a","value":"1"}`+"\n"), 0644)
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		http.ServeFile(w, r, block)
+	}))
+	defer server.Close()
+
+	reports := make(chan common.TaskReport, 2)
+	original := ReportToMaster
+	ReportToMaster = func(report common.TaskReport) error { reports <- report; return nil }
+	defer func() { ReportToMaster = original }()
+
+	base := common.Task{
+		TaskID: "job-attempts-reduce-0", JobID: "job-attempts", StageID: "reduce",
+		Operation:    common.OperationNode{Type: common.OpTypeReduceByKey, UDFName: "reduce_sum"},
+		OutputTarget: common.TaskOutput{Type: common.OutputTypeShuffle, Path: filepath.Join(dir, "shuffle"), NumPartitions: 1},
+	}
+	// Intento 1 (de un worker dado por muerto que volvió): su shuffle ya no existe y falla enseguida
+	gone := httptest.NewServer(http.NotFoundHandler())
+	defer gone.Close()
+	first := base
+	first.Attempt = 1
+	first.InputPartition = common.TaskInput{SourceType: common.SourceTypeShuffle, ShuffleMap: map[string]string{"w1": gone.URL}}
+	second := base
+	second.Attempt = 2
+	second.InputPartition = common.TaskInput{SourceType: common.SourceTypeShuffle, ShuffleMap: map[string]string{"w1": server.URL}}
+
+	secondCtx, ok := registerTask(second)
+	if !ok { t.Fatal("El intento 2 debió registrarse") }
+	go runTaskAsync(secondCtx, second)
+	firstCtx, _ := registerTask(first)
+	go runTaskAsync(firstCtx, first)
+
+	select {
+	case rep := <-reports:
+		if rep.Attempt != 1 || rep.Status != common.TaskStatusFailure {
+			t.Fatalf("Se esperaba primero el fallo del intento 1, obtuvo %+v", rep)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("El intento 1 no reportó")
+	}
+
+	// El fin del intento 1 (su baja, después del reporte) no debe abortar al intento 2
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		tasksMu.Lock()
+		_, running := runningTasks[taskAttempt{first.TaskID, first.Attempt}]
+		tasksMu.Unlock()
+		if !running { break }
+		time.Sleep(10 * time.Millisecond)
+	}
+	close(release)
+	select {
+	case rep := <-reports:
+		if rep.Attempt != 2 || rep.Status != common.TaskStatusSuccess {
+			t.Errorf("Se esperaba el éxito del intento 2, obtuvo %+v", rep)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("El intento 2 fue abortado al terminar el intento 1")
+	}
 }
-	*/
\ No newline at end of file
@@ -2,12 +2,14 @@ package worker
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
 	"fmt"
 	"hash/fnv"
 	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	//"sync"
@@ -36,24 +38,37 @@ func InitExecutor(maxThreads int) {
 	log.Printf("[Executor] Inicializado pool con %d hilos", maxThreads)
 }
 
-func (e *ExecutionManager) Submit(task common.Task) ([]common.ShuffleMeta, error) {
-	e.semaphore <- struct{}{}
+// Submit ejecuta la tarea cuando el pool tiene un hilo libre. Si ctx se cancela (timeout o Job
+// cancelado) mientras espera o ejecuta, la tarea se detiene en el siguiente registro y libera el hilo.
+func (e *ExecutionManager) Submit(ctx context.Context, task common.Task) ([]common.ShuffleMeta, error) {
+	select {
+	case e.semaphore <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
 	defer func() { <-e.semaphore }()
 
 	log.Printf("[Executor] Iniciando tarea %s (Threads activos: %d/%d)", task.TaskID, len(e.semaphore), e.maxThreads)
-	return executeTaskLogic(task)
+	metas, err := executeTaskLogic(ctx, task)
+	if err != nil && ctx.Err() != nil {
+		log.Printf("[Executor] Tarea %s detenida: %v", task.TaskID, ctx.Err())
+	}
+	return metas, err
 }
 
 // ==========================================
 // 2. LÓGICA PRINCIPAL DE EJECUCIÓN
 // ==========================================
 
-func executeTaskLogic(task common.Task) ([]common.ShuffleMeta, error) {
+func executeTaskLogic(ctx context.Context, task common.Task) ([]common.ShuffleMeta, error) {
 	switch task.Operation.Type {
 	case common.OpTypeMap, common.OpTypeFilter, common.OpTypeFlatMap:
-		return executeMapSide(task)
-	case common.OpTypeReduceByKey, common.OpTypeJoin:
-		return executeReduceSide(task)
+		return executeMapSide(ctx, task)
+	case common.OpTypeReduceByKey, common.OpTypeJoin, common.OpTypeLeftOuterJoin, common.OpTypeRightOuterJoin, common.OpTypeFullOuterJoin:
+		if task.Operation.Broadcast != "" { return executeBroadcastJoin(ctx, task) }
+		return executeReduceSide(ctx, task)
+	case common.OpTypeSortByKey:
+		return executeSortSide(ctx, task)
 	default:
 		return nil, fmt.Errorf("operación no soportada: %s", task.Operation.Type)
 	}
@@ -62,145 +77,265 @@ func executeTaskLogic(task common.Task) ([]common.ShuffleMeta, error) {
 // ------------------------------------------
 // LADO MAP (Map, Filter, FlatMap)
 // ------------------------------------------
-func executeMapSide(task common.Task) ([]common.ShuffleMeta, error) {
-	var inputStream io.ReadCloser
-	var err error
+func executeMapSide(ctx context.Context, task common.Task) (metas []common.ShuffleMeta, err error) {
+	var input *inputReader
+
+	// Configuración de Input Splitting (Solo aplica si leemos de ARCHIVO compartido sin rango de bytes)
+	// Si viene de Shuffle, procesamos TODO lo que descargamos (ya viene particionado para mí)
+	splitByLine := false
 
 	// 1. Determinar Fuente de Entrada (Archivo Local o Shuffle Remoto)
 	if task.InputPartition.SourceType == common.SourceTypeShuffle {
-		// Caso Especial: Etapa intermedia (ej. Map después de Filter)
+		// Caso Especial: Etapa narrow que lee un shuffle (ej. Filter sobre una salida con varios consumidores)
 		// Descargamos todo el shuffle a un archivo temporal para procesarlo linealmente
-		tempPath := fmt.Sprintf("/tmp/shuffle_in_%s.tmp", task.TaskID)
-		if err := downloadShuffleToTemp(task.InputPartition.ShuffleMap, tempPath); err != nil {
+		tempPath := fmt.Sprintf("/tmp/shuffle_in_%s_%d.tmp", task.TaskID, task.Attempt)
+		if err := downloadShuffleToTemp(ctx, task.InputPartition.ShuffleMap, tempPath); err != nil {
 			return nil, fmt.Errorf("error descargando input shuffle: %w", err)
 		}
-		// Abrimos el temp
-		inputStream, err = os.Open(tempPath)
 		// Borramos el temp al terminar
 		defer os.Remove(tempPath)
+		input = openInput([]common.FileSplit{{Path: tempPath, Offsets: [2]int64{0, -1}}})
 	} else {
-		// Caso Normal: Lectura de archivo local (Source)
-		inputStream, err = os.Open(task.InputPartition.Path)
+		// Caso Normal: los rangos de archivo asignados por el Scheduler
+		var splits []common.FileSplit
+		splits, splitByLine = taskSplits(task.InputPartition)
+		input = openInput(splits)
 	}
-
-	if err != nil {
-		return nil, fmt.Errorf("error leyendo input: %w", err)
-	}
-	defer inputStream.Close()
+	defer input.Close()
 
 	// 2. Preparar Writers (Salida)
 	writers, files, paths := createPartitionWriters(task)
-	defer closeWriters(writers, files)
-
-	// 3. Obtener UDF
-	var processFn func(udf.Record) []udf.Record
-	switch task.Operation.Type {
-	case common.OpTypeMap:
-		fn, err := udf.GetMapFunction(task.Operation.UDFName)
-		if err != nil { return nil, err }
-		processFn = fn
-	case common.OpTypeFlatMap:
-		fn, err := udf.GetFlatMapFunction(task.Operation.UDFName)
-		if err != nil { return nil, err }
-		processFn = fn
-	case common.OpTypeFilter:
-		fn, err := udf.GetFilterFunction(task.Operation.UDFName)
-		if err != nil { return nil, err }
-		processFn = func(r udf.Record) []udf.Record {
-			if fn(r) { return []udf.Record{r} }
-			return nil
-		}
+	samples := make(keySamples)
+	var sorter *runSorter // Lo lee un SORT_BY_KEY: los registros se escriben ordenados al final
+	if task.OutputTarget.Sort != "" {
+		sorter = newRunSorter(task.OutputTarget.Sort)
+		defer sorter.Cleanup()
 	}
+	defer func() {
+		closeWriters(writers, files)
+		if err != nil { discardOutputs(task, paths) } // Tarea fallida o abortada: no dejar archivos a medias
+	}()
+
+	// 3. Obtener UDFs: la operación de la tarea seguida de las operaciones fusionadas del stage
+	chain := append([]common.OperationNode{task.Operation}, task.Pipeline...)
+	processFn, err := taskPipeline(ctx, task, chain)
+	if err != nil { return nil, err }
 
 	// 4. Procesar
-	scanner := bufio.NewScanner(inputStream)
-	
-	// Configuración de Input Splitting (Solo aplica si leemos de ARCHIVO compartido)
-	// Si viene de Shuffle, procesamos TODO lo que descargamos (ya viene particionado para mí)
-	shouldSplit := (task.InputPartition.SourceType == common.SourceTypeFile)
-	
 	lineCounter := 0
 	totalPartitions := task.Operation.NumPartitions
 	if totalPartitions <= 0 { totalPartitions = 1 }
 
-	for scanner.Scan() {
-		// Input Splitting: Si es archivo compartido, solo leo mi parte.
-		// Si es Shuffle, leo todo lo que me mandaron.
-		if !shouldSplit || (lineCounter % totalPartitions == task.PartitionIndex) {
-			
-			line := scanner.Text()
-			results := processFn(udf.Record(line))
+	for {
+		// Punto de cancelación cooperativa: timeout o Job cancelado
+		if err := ctx.Err(); err != nil { return nil, err }
 
-			for _, res := range results {
-				partID := 0
-				if task.OutputTarget.Type == common.OutputTypeShuffle {
-					partID = getPartitionID(string(res), task.OutputTarget.NumPartitions)
-				}
-				
-				if w, ok := writers[partID]; ok {
-					w.WriteString(string(res) + "\n")
+		line, offset, ok, err := input.Next()
+		if err != nil { return nil, fmt.Errorf("error leyendo input: %w", err) }
+		if !ok { break }
+
+		// Input Splitting por módulo: solo si no hay rango de bytes asignado
+		mine := !splitByLine || (lineCounter % totalPartitions == task.PartitionIndex)
+		lineCounter++
+		if !mine || skipInputLine(task.InputPartition, offset, line) { continue }
+		if task.InputPartition.IncludeFileName { line = input.Path() + "\t" + line }
+
+		results := processFn(udf.Record(line))
+
+		for _, res := range results {
+			partID := 0
+			out := string(res)
+			if task.OutputTarget.Type == common.OutputTypeShuffle {
+				var key string
+				out, key, partID = shuffleLine(res, task.StageID, task.OutputTarget.NumPartitions)
+				if sorter != nil {
+					if key == "" { return nil, keylessSortError(task, out) }
+					if err := sorter.Add(key, out); err != nil { return nil, err }
+					continue
 				}
+				samples.add(partID, key, len(out)+1)
+			}
+			
+			if w, ok := writers[partID]; ok {
+				w.WriteString(out + "\n")
 			}
 		}
-		lineCounter++
 	}
 
-	if err := scanner.Err(); err != nil { return nil, err }
-
-	return generateMeta(writers, files, paths)
+	if sorter != nil { return sorter.finishBlock(writers, files, paths) }
+	return generateMeta(writers, files, paths, samples)
 }
 
 // ------------------------------------------
-// LADO REDUCE (ReduceByKey, Join)
+// LADO REDUCE (ReduceByKey, Join y Joins externos)
 // ------------------------------------------
-func executeReduceSide(task common.Task) ([]common.ShuffleMeta, error) {
+func executeReduceSide(ctx context.Context, task common.Task) ([]common.ShuffleMeta, error) {
 	// Agregación en Memoria con Spill
 	aggregator := NewMemoryAggregator(50 * 1024 * 1024) 
 	defer aggregator.Cleanup()
 
 	// Descargar datos
 	for _, url := range task.InputPartition.ShuffleMap {
-		if err := downloadAndMerge(url, aggregator); err != nil {
+		if err := downloadAndMerge(ctx, url, aggregator); err != nil {
 			log.Printf("[Warn] Fallo parcial shuffle %s: %v", url, err)
 			return nil, err
 		}
 	}
 
-	// Salida
-	outPath := fmt.Sprintf("%s_%s_out", task.OutputTarget.Path, task.TaskID)
-	os.MkdirAll(filepath.Dir(outPath), 0755)
-	outFile, err := os.Create(outPath)
+	// Resolver la UDF antes de abrir archivos de salida
+	var reduceFn udf.UDFReduceFn
+	var joinFn udf.UDFJoinFn
+	var err error
+	if task.Operation.Type == common.OpTypeReduceByKey {
+		reduceFn, err = udf.GetReduceFunction(task.Operation.UDFName)
+	} else {
+		joinFn, err = resolveJoinFunction(task.Operation)
+	}
 	if err != nil { return nil, err }
-	defer outFile.Close()
-	writer := bufio.NewWriter(outFile)
+
+	// Operaciones narrow fusionadas después del Reduce/Join (si las hay)
+	postFn, err := taskPipeline(ctx, task, task.Pipeline)
+	if err != nil { return nil, err }
+
+	// Salida: shuffle particionado si otra etapa consume este resultado, archivo único si es final
+	write, finish, err := openReduceOutput(task)
+	if err != nil { return nil, err }
+	emit := func(r udf.Record) {
+		for _, out := range postFn(r) { write(out) }
+	}
 
 	dataMap := aggregator.GetDataMap()
 
-	if task.Operation.Type == common.OpTypeReduceByKey {
-		reduceFn, err := udf.GetReduceFunction(task.Operation.UDFName)
-		if err != nil { return nil, err }
+	// El lado de cada valor lo da la etapa que lo produjo (KeyValue.Source):
+	// Dependencies[0] es el lado izquierdo y Dependencies[1] el derecho.
+	leftID, rightID := joinSides(task.Operation)
+	for key, values := range dataMap {
+		// Punto de cancelación cooperativa: se descarta la salida parcial
+		if err := ctx.Err(); err != nil {
+			metas, _ := finish()
+			discardOutputs(task, metaPaths(metas))
+			return nil, err
+		}
+		if reduceFn != nil {
+			emit(reduceFn(key, values))
+			continue
+		}
+		left, right := aggregator.SplitBySource(key, leftID, rightID)
+		for _, r := range joinFn(key, left, right) {
+			emit(r)
+		}
+	}
+
+	return finish()
+}
 
-		for key, values := range dataMap {
-			res := reduceFn(key, values)
-			writer.WriteString(string(res) + "\n")
+// buildPipeline compone las UDFs de una cadena de operaciones narrow en una sola función:
+// cada registro atraviesa todas las operaciones en memoria, sin escribir resultados intermedios.
+// Un broadcast join fusionado cruza contra su lado replicado en tables. Una cadena vacía es la identidad.
+func buildPipeline(nodes []common.OperationNode, tables broadcastTables) (func(udf.Record) []udf.Record, error) {
+	fns := make([]func(udf.Record) []udf.Record, 0, len(nodes))
+	for _, node := range nodes {
+		switch node.Type {
+		case common.OpTypeMap:
+			fn, err := udf.GetMapFunction(node.UDFName)
+			if err != nil { return nil, err }
+			fns = append(fns, fn)
+		case common.OpTypeFlatMap:
+			fn, err := udf.GetFlatMapFunction(node.UDFName)
+			if err != nil { return nil, err }
+			fns = append(fns, fn)
+		case common.OpTypeFilter:
+			fn, err := udf.GetFilterFunction(node.UDFName)
+			if err != nil { return nil, err }
+			fns = append(fns, func(r udf.Record) []udf.Record {
+				if fn(r) { return []udf.Record{r} }
+				return nil
+			})
+		case common.OpTypeJoin, common.OpTypeLeftOuterJoin, common.OpTypeRightOuterJoin:
+			// Broadcast join fusionado: el lado replicado ya está en memoria
+			if node.Broadcast == "" { return nil, fmt.Errorf("%s sin lado replicado no es fusionable en pipeline", node.Type) }
+			fn, err := pipelineProbe(node, tables)
+			if err != nil { return nil, err }
+			fns = append(fns, fn)
+		default:
+			return nil, fmt.Errorf("operación no fusionable en pipeline: %s", node.Type)
 		}
-	} else if task.Operation.Type == common.OpTypeJoin {
-		joinFn, err := udf.GetJoinFunction(task.Operation.UDFName)
-		if err != nil { return nil, err }
-		
-		for key, values := range dataMap {
-			// En un sistema real separaríamos Left/Right aqui.
-			// Pasamos todo y la UDF se encarga.
-			results := joinFn(key, values, []string{}) 
-			for _, r := range results {
-				writer.WriteString(string(r) + "\n")
+	}
+
+	return func(r udf.Record) []udf.Record {
+		records := []udf.Record{r}
+		for _, fn := range fns {
+			var next []udf.Record
+			for _, rec := range records {
+				next = append(next, fn(rec)...)
+			}
+			if len(next) == 0 { return nil }
+			records = next
+		}
+		return records
+	}, nil
+}
+
+// openReduceOutput prepara la salida del lado Reduce. Devuelve la función para emitir registros
+// y la función que cierra los archivos y genera los metadatos.
+func openReduceOutput(task common.Task) (func(udf.Record), func() ([]common.ShuffleMeta, error), error) {
+	if task.OutputTarget.Type == common.OutputTypeShuffle && task.OutputTarget.Sort != "" {
+		// Lo lee un SORT_BY_KEY: un único bloque ordenado por clave, con su índice
+		writers, files, paths := createPartitionWriters(task)
+		sorter := newRunSorter(task.OutputTarget.Sort)
+		var addErr error // Fallo al volcar una corrida: se informa al cerrar
+		emit := func(r udf.Record) {
+			if addErr != nil { return }
+			out, key, _ := shuffleLine(r, task.StageID, 1)
+			if key == "" { addErr = keylessSortError(task, out); return }
+			addErr = sorter.Add(key, out)
+		}
+		finish := func() ([]common.ShuffleMeta, error) {
+			defer sorter.Cleanup()
+			defer closeWriters(writers, files)
+			if addErr != nil {
+				discardOutputs(task, paths)
+				return nil, addErr
 			}
+			return sorter.finishBlock(writers, files, paths)
 		}
+		return emit, finish, nil
+	}
+	if task.OutputTarget.Type == common.OutputTypeShuffle {
+		writers, files, paths := createPartitionWriters(task)
+		samples := make(keySamples)
+		emit := func(r udf.Record) {
+			out, key, partID := shuffleLine(r, task.StageID, task.OutputTarget.NumPartitions)
+			writers[partID].WriteString(out + "\n")
+			samples.add(partID, key, len(out)+1)
+		}
+		finish := func() ([]common.ShuffleMeta, error) {
+			defer closeWriters(writers, files)
+			return generateMeta(writers, files, paths, samples)
+		}
+		return emit, finish, nil
 	}
 
-	writer.Flush()
-	info, _ := outFile.Stat()
-	return []common.ShuffleMeta{{PartitionKey: 0, Path: outPath, Size: info.Size()}}, nil
+	outPath := common.AttemptShufflePath(task.OutputTarget.Path, task.TaskID, task.Attempt, "out")
+	if task.OutputTarget.Type == common.OutputTypeFinal {
+		outPath = common.AttemptOutputPath(task.OutputTarget.Path, task.TaskID, task.Attempt, task.PartitionIndex)
+	}
+	os.MkdirAll(filepath.Dir(outPath), 0755)
+	outFile, err := os.Create(outPath)
+	if err != nil { return nil, nil, err }
+	writer := bufio.NewWriter(outFile)
+
+	emit := func(r udf.Record) {
+		writer.WriteString(string(r) + "\n")
+	}
+	finish := func() ([]common.ShuffleMeta, error) {
+		defer outFile.Close()
+		if err := writer.Flush(); err != nil { return nil, err }
+		info, err := outFile.Stat()
+		if err != nil { return nil, err }
+		return []common.ShuffleMeta{{PartitionKey: 0, Path: outPath, Size: info.Size()}}, nil
+	}
+	return emit, finish, nil
 }
 
 // ==========================================
@@ -208,7 +343,7 @@ func executeReduceSide(task common.Task) ([]common.ShuffleMeta, error) {
 // ==========================================
 
 // Helper nuevo para descargar múltiples fuentes a un solo archivo (Para Map-Shuffle)
-func downloadShuffleToTemp(shuffleMap map[string]string, destPath string) error {
+func downloadShuffleToTemp(ctx context.Context, shuffleMap map[string]string, destPath string) error {
 	f, err := os.Create(destPath)
 	if err != nil { return err }
 	defer f.Close()
@@ -216,18 +351,18 @@ func downloadShuffleToTemp(shuffleMap map[string]string, destPath string) error
 	w := bufio.NewWriter(f)
 
 	for _, url := range shuffleMap {
-		resp, err := http.Get(url)
-		if err != nil { return err } // Fail fast
+		resp, err := httpGet(ctx, url)
+		if err != nil { return fetchFailed(ctx, url, err) } // Fail fast
 		
 		if resp.StatusCode != 200 {
 			resp.Body.Close()
-			return fmt.Errorf("status %d from %s", resp.StatusCode, url)
+			return fetchFailed(ctx, url, fmt.Errorf("status %d", resp.StatusCode))
 		}
 
 		// Copiar contenido al archivo temp
 		_, err = io.Copy(w, resp.Body)
 		resp.Body.Close()
-		if err != nil { return err }
+		if err != nil { return fetchFailed(ctx, url, err) }
 		
 		// Asegurar salto de línea entre archivos
 		w.WriteString("\n") 
@@ -239,6 +374,7 @@ func downloadShuffleToTemp(shuffleMap map[string]string, destPath string) error
 // Agregador en Memoria con Spill a Disco
 type MemoryAggregator struct {
 	data      map[string][]string
+	sources   map[string][]string // Paralelo a data: etapa de origen de cada valor (para JOIN)
 	sizeBytes int64
 	limit     int64
 	spillFiles []string
@@ -246,15 +382,22 @@ type MemoryAggregator struct {
 // Crear un nuevo agregador en memoria con límite de tamaño
 func NewMemoryAggregator(limit int64) *MemoryAggregator {
 	return &MemoryAggregator{
-		data:  make(map[string][]string),
-		limit: limit,
+		data:    make(map[string][]string),
+		sources: make(map[string][]string),
+		limit:   limit,
 	}
 }
 
 // Agrega un par clave-valor al agregador en memoria
 func (m *MemoryAggregator) Add(key, value string) {
+	m.AddFrom(key, value, "")
+}
+
+// AddFrom agrega un par clave-valor recordando la etapa que lo produjo
+func (m *MemoryAggregator) AddFrom(key, value, source string) {
 	m.data[key] = append(m.data[key], value)
-	m.sizeBytes += int64(len(key) + len(value))
+	m.sources[key] = append(m.sources[key], source)
+	m.sizeBytes += int64(len(key) + len(value) + len(source))
 	if m.sizeBytes > m.limit { 
 		m.SpillToDisk() 
 	}
@@ -272,8 +415,8 @@ func (m *MemoryAggregator) SpillToDisk() {
 	
 	// Serializar cada par K/V como una línea JSON (JSON Lines - JSONL)
 	for k, vals := range m.data {
-		for _, v := range vals {
-			kv := common.KeyValue{Key: k, Value: v}
+		for i, v := range vals {
+			kv := common.KeyValue{Key: k, Value: v, Source: m.sources[k][i]}
 			b, _ := json.Marshal(kv)
 			w.WriteString(string(b) + "\n")
 		}
@@ -285,6 +428,7 @@ func (m *MemoryAggregator) SpillToDisk() {
 	// Actualizar estado del agregador
 	m.spillFiles = append(m.spillFiles, tmpName)
 	m.data = make(map[string][]string) // Vaciar la memoria
+	m.sources = make(map[string][]string)
 	m.sizeBytes = 0                    // Resetear el contador
 	log.Printf("[Executor] Spill a disco: %s", tmpName)
 }
@@ -305,6 +449,7 @@ func (m *MemoryAggregator) GetDataMap() map[string][]string {
 			var kv common.KeyValue
 			if err := json.Unmarshal(sc.Bytes(), &kv); err == nil {
 				m.data[kv.Key] = append(m.data[kv.Key], kv.Value)
+				m.sources[kv.Key] = append(m.sources[kv.Key], kv.Source)
 			}
 		}
 		f.Close()
@@ -313,26 +458,81 @@ func (m *MemoryAggregator) GetDataMap() map[string][]string {
 	return m.data
 }
 
+// SplitBySource separa los valores de una clave según la etapa de origen (lado izquierdo/derecho del JOIN).
+// Los valores de cualquier otra etapa se descartan. Debe llamarse después de GetDataMap.
+func (m *MemoryAggregator) SplitBySource(key, leftSource, rightSource string) ([]string, []string) {
+	var left, right []string
+	for i, v := range m.data[key] {
+		switch m.sources[key][i] {
+		case leftSource:
+			left = append(left, v)
+		case rightSource:
+			right = append(right, v)
+		}
+	}
+	return left, right
+}
+
 func (m *MemoryAggregator) Cleanup() {
 	for _, p := range m.spillFiles { 
 		os.Remove(p) 
 	}
 }
 
-func downloadAndMerge(url string, agg *MemoryAggregator) error {
-	resp, err := http.Get(url)
-	if err != nil { return err }
+// FetchFailedError indica que no se pudo descargar una salida de shuffle: el worker que la tenía
+// murió o la perdió. Se reporta al Master para que recalcule esa partición por linaje.
+type FetchFailedError struct {
+	WorkerID string
+	Path     string
+	Err      error
+}
+
+func (e *FetchFailedError) Error() string {
+	return fmt.Sprintf("fetch de shuffle falló (%s:%s): %v", e.WorkerID, e.Path, e.Err)
+}
+
+func (e *FetchFailedError) Unwrap() error { return e.Err }
+
+// fetchFailed envuelve el error de descarga de una URL de shuffle (http://<worker>/shuffle?path=...).
+// Si la tarea fue cancelada se devuelve el error del contexto: no es una salida perdida.
+func fetchFailed(ctx context.Context, rawURL string, err error) error {
+	if ctx.Err() != nil { return ctx.Err() }
+	ff := &FetchFailedError{Err: err}
+	if u, perr := url.Parse(rawURL); perr == nil {
+		ff.WorkerID = u.Host
+		ff.Path = u.Query().Get("path")
+	}
+	return ff
+}
+
+// httpGet hace un GET que se corta si ctx se cancela (también a mitad de la lectura del cuerpo)
+func httpGet(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil { return nil, err }
+	return http.DefaultClient.Do(req)
+}
+
+func downloadAndMerge(ctx context.Context, url string, agg *MemoryAggregator) error {
+	return streamShuffle(ctx, url, func(kv common.KeyValue) { agg.AddFrom(kv.Key, kv.Value, kv.Source) })
+}
+
+// streamShuffle descarga un bloque de shuffle y entrega cada par clave-valor a fn a medida que llega
+func streamShuffle(ctx context.Context, url string, fn func(common.KeyValue)) error {
+	resp, err := httpGet(ctx, url)
+	if err != nil { return fetchFailed(ctx, url, err) }
 	defer resp.Body.Close()
-	if resp.StatusCode != 200 { return fmt.Errorf("status %d", resp.StatusCode) }
+	if resp.StatusCode != 200 { return fetchFailed(ctx, url, fmt.Errorf("status %d", resp.StatusCode)) }
 
 	sc := bufio.NewScanner(resp.Body)
 	for sc.Scan() {
+		if err := ctx.Err(); err != nil { return err }
 		var kv common.KeyValue
 		if err := json.Unmarshal(sc.Bytes(), &kv); err == nil {
-			agg.Add(kv.Key, kv.Value)
+			fn(kv)
 		}
 	}
-	return sc.Err()
+	if err := sc.Err(); err != nil { return fetchFailed(ctx, url, err) }
+	return nil
 }
 
 func createPartitionWriters(task common.Task) (map[int]*bufio.Writer, map[int]*os.File, map[int]string) {
@@ -340,10 +540,14 @@ func createPartitionWriters(task common.Task) (map[int]*bufio.Writer, map[int]*o
 	files := make(map[int]*os.File)
 	paths := make(map[int]string)
 	numParts := task.OutputTarget.NumPartitions
-	if numParts <= 0 { numParts = 1 }
+	if numParts <= 0 || task.OutputTarget.Type == common.OutputTypeFinal { numParts = 1 }
 
 	for i := 0; i < numParts; i++ {
-		p := fmt.Sprintf("%s_%s_part_%d", task.OutputTarget.Path, task.TaskID, i)
+		p := common.AttemptShufflePath(task.OutputTarget.Path, task.TaskID, task.Attempt, fmt.Sprintf("part_%d", i))
+		if task.OutputTarget.Type == common.OutputTypeFinal {
+			// Salida final: un único archivo por intento, lo publica el Master al hacer commit
+			p = common.AttemptOutputPath(task.OutputTarget.Path, task.TaskID, task.Attempt, task.PartitionIndex)
+		}
 		os.MkdirAll(filepath.Dir(p), 0755)
 		f, _ := os.Create(p)
 		writers[i] = bufio.NewWriter(f)
@@ -353,31 +557,58 @@ func createPartitionWriters(task common.Task) (map[int]*bufio.Writer, map[int]*o
 	return writers, files, paths
 }
 
+// discardOutputs borra los archivos escritos por una tarea que no terminó. En la salida final
+// también se borra el directorio del intento (es exclusivo de la tarea).
+func discardOutputs(task common.Task, paths map[int]string) {
+	for _, p := range paths {
+		os.Remove(p)
+		if task.OutputTarget.Type == common.OutputTypeFinal { os.Remove(filepath.Dir(p)) }
+	}
+}
+
+func metaPaths(metas []common.ShuffleMeta) map[int]string {
+	paths := make(map[int]string)
+	for _, m := range metas { paths[m.PartitionKey] = m.Path }
+	return paths
+}
+
 func closeWriters(writers map[int]*bufio.Writer, files map[int]*os.File) {
 	for _, w := range writers { w.Flush() }
 	for _, f := range files { f.Close() }
 }
 
-func generateMeta(writers map[int]*bufio.Writer, files map[int]*os.File, paths map[int]string) ([]common.ShuffleMeta, error) {
+// generateMeta describe los archivos escritos, con la muestra de claves de cada partición (si hay)
+func generateMeta(writers map[int]*bufio.Writer, files map[int]*os.File, paths map[int]string, samples keySamples) ([]common.ShuffleMeta, error) {
 	var metas []common.ShuffleMeta
 	for pid, path := range paths {
 		writers[pid].Flush()
 		info, _ := files[pid].Stat()
-		metas = append(metas, common.ShuffleMeta{
+		meta := common.ShuffleMeta{
 			PartitionKey: pid,
 			Path:         path,
 			Size:         info.Size(),
-		})
+		}
+		if sketch := samples[pid]; sketch != nil { meta.HotKeys = sketch.Top(KeySampleSize) }
+		metas = append(metas, meta)
 	}
 	return metas, nil
 }
 
-func getPartitionID(jsonLine string, numPartitions int) int {
+func hashPartition(key string, numPartitions int) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32()) % numPartitions
+}
+
+// shuffleLine prepara un registro para el shuffle: lo etiqueta con la etapa que lo produjo
+// (KeyValue.Source) y calcula su partición destino. Devuelve también la clave (para la muestra).
+// Los registros que no son KeyValue van tal cual a la 0, sin clave.
+func shuffleLine(r udf.Record, source string, numPartitions int) (string, string, int) {
 	var kv common.KeyValue
-	if err := json.Unmarshal([]byte(jsonLine), &kv); err == nil && kv.Key != "" {
-		h := fnv.New32a()
-		h.Write([]byte(kv.Key))
-		return int(h.Sum32()) % numPartitions
+	if err := json.Unmarshal([]byte(r), &kv); err != nil || kv.Key == "" {
+		return string(r), "", 0
 	}
-	return 0
+	kv.Source = source
+	b, _ := json.Marshal(kv)
+	return string(b), kv.Key, hashPartition(kv.Key, numPartitions)
 }
\ No newline at end of file
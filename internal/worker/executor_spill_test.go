@@ -68,4 +68,24 @@ func TestMemoryAggregator_SpillLogic(t *testing.T) {
 			t.Errorf("Cleanup falló, el archivo de spill sigue existiendo en %s", spillPath)
 		}
 	})
-}
\ No newline at end of file
+}
+
+func TestMemoryAggregator_SplitBySourceAfterSpill(t *testing.T) {
+	// Límite mínimo: cada Add fuerza un spill, el origen debe sobrevivir al disco
+	agg := NewMemoryAggregator(1)
+	defer agg.Cleanup()
+
+	agg.AddFrom("k", "u1", "left")
+	agg.AddFrom("k", "o1", "right")
+	agg.AddFrom("k", "o2", "right")
+	agg.AddFrom("k", "x", "otra-etapa")
+
+	agg.GetDataMap()
+	left, right := agg.SplitBySource("k", "left", "right")
+	if len(left) != 1 || left[0] != "u1" {
+		t.Errorf("Lado izquierdo incorrecto: %v", left)
+	}
+	if len(right) != 2 {
+		t.Errorf("Lado derecho incorrecto: %v", right)
+	}
+}
@@ -1,7 +1,9 @@
 package worker
 
 import (
+	"context"
 	//"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest" // Necesario para simular la red
@@ -9,6 +11,7 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"mini-spark/internal/common"
 	//"mini-spark/internal/udf"
@@ -92,7 +95,7 @@ func TestExecutor_MapAndFilter(t *testing.T) {
 			udfName:           "map_wordcount",
 			numPartitions:     2,
 			expectedMetaCount: 2,
-			expectedOutput: `{"key":"line","value":"1"}`,
+			expectedOutput: `{"key":"line","value":"1","source":"MAP"}`, // El shuffle lleva la etapa de origen
 			expectErr:         false,
 		},
 		{
@@ -128,7 +131,7 @@ func TestExecutor_MapAndFilter(t *testing.T) {
 				nil,
 			)
 
-			metas, err := GlobalExecutor.Submit(task)
+			metas, err := GlobalExecutor.Submit(context.Background(), task)
 
 			if (err != nil) != tt.expectErr {
 				t.Fatalf("Submit falló. Esperaba error=%t, obtuvo: %v", tt.expectErr, err)
@@ -155,6 +158,140 @@ func TestExecutor_MapAndFilter(t *testing.T) {
 	}
 }
 
+func TestExecutor_InputFormats(t *testing.T) {
+	tempDir := t.TempDir()
+
+	tests := []struct {
+		name       string
+		content    string
+		format     string
+		skipHeader bool
+		expected   []string
+	}{
+		{name: "CSV_SkipHeader", content: "ID,Nombre\n1,Ana\n2,Luis\n", format: common.InputFormatCSV, skipHeader: true, expected: []string{"1,Ana", "2,Luis"}},
+		{name: "CSV_ConHeader", content: "ID,Nombre\n1,Ana\n", format: common.InputFormatCSV, expected: []string{"ID,Nombre", "1,Ana"}},
+		{name: "JSONL_DescartaInvalidas", content: `{"key":"a","value":"1"}` + "\nbasura\n", format: common.InputFormatJSONL, expected: []string{`{"key":"a","value":"1"}`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inputPath := createInputFile(t, tempDir, tt.name+".txt", tt.content)
+			task := createMockTask("job-formats", tt.name, common.OpTypeFilter, "not_empty", common.OutputTypeLocalSpill, 1, inputPath, nil)
+			task.InputPartition.Format = tt.format
+			task.InputPartition.SkipHeader = tt.skipHeader
+
+			metas, err := GlobalExecutor.Submit(context.Background(), task)
+			if err != nil {
+				t.Fatalf("Submit falló: %v", err)
+			}
+			got := strings.Fields(readOutputFile(t, metas[0].Path))
+			if strings.Join(got, "|") != strings.Join(tt.expected, "|") {
+				t.Errorf("Registros leídos incorrectos. Esperado %v, obtuvo %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestExecutor_FinalOutputAttempt(t *testing.T) {
+	tempDir := t.TempDir()
+	inputPath := createInputFile(t, tempDir, "in.txt", "hola\nmundo\n")
+
+	// Cada intento escribe en su propio directorio temporal: dos intentos de la misma tarea no se pisan
+	task := createMockTask("job-final", "filter", common.OpTypeFilter, "not_empty", common.OutputTypeFinal, 1, inputPath, nil)
+	task.OutputTarget.Path = filepath.Join(tempDir, "out")
+	task.PartitionIndex = 3
+	task.InputPartition.Splits = []common.FileSplit{{Path: inputPath, Offsets: [2]int64{0, -1}}}
+	var paths []string
+	for _, attempt := range []int{1, 2} {
+		task.Attempt = attempt
+		metas, err := GlobalExecutor.Submit(context.Background(), task)
+		if err != nil {
+			t.Fatalf("Submit falló: %v", err)
+		}
+		expected := common.AttemptOutputPath(task.OutputTarget.Path, task.TaskID, attempt, 3)
+		if len(metas) != 1 || metas[0].Path != expected {
+			t.Fatalf("Salida del intento %d incorrecta. Esperado %s, obtuvo %v", attempt, expected, metas)
+		}
+		paths = append(paths, metas[0].Path)
+	}
+	for _, p := range paths {
+		if got := readOutputFile(t, p); got != "hola\nmundo\n" {
+			t.Errorf("Contenido de %s incorrecto: %q", p, got)
+		}
+	}
+}
+
+func TestExecutor_ConcurrentShuffleAttempts(t *testing.T) {
+	tempDir := t.TempDir()
+	inputPath := createInputFile(t, tempDir, "in.txt", strings.Repeat("uno dos tres\n", 2000))
+
+	// Dos intentos de la misma tarea MAP (p. ej. la original y su copia especulativa) en el mismo directorio
+	task := createMockTask("job-spec", "map", common.OpTypeMap, "map_wordcount", common.OutputTypeShuffle, 2, inputPath, nil)
+	task.OutputTarget.Path = filepath.Join(tempDir, "shuffle")
+	task.Operation.NumPartitions = 1 // Una sola tarea lee todo el archivo
+	results := make([][]common.ShuffleMeta, 3)
+	errs := make([]error, 3)
+	done := make(chan struct{})
+	for _, attempt := range []int{1, 2} {
+		go func(attempt int) {
+			defer func() { done <- struct{}{} }()
+			attemptTask := task
+			attemptTask.Attempt = attempt
+			results[attempt], errs[attempt] = GlobalExecutor.Submit(context.Background(), attemptTask)
+		}(attempt)
+	}
+	<-done
+	<-done
+
+	for _, attempt := range []int{1, 2} {
+		if errs[attempt] != nil {
+			t.Fatalf("El intento %d falló: %v", attempt, errs[attempt])
+		}
+		lines := 0
+		for _, meta := range results[attempt] {
+			if !strings.Contains(meta.Path, fmt.Sprintf("_attempt_%d_", attempt)) {
+				t.Errorf("La ruta %s no identifica al intento %d", meta.Path, attempt)
+			}
+			lines += strings.Count(readOutputFile(t, meta.Path), "\n")
+		}
+		if lines != 6000 {
+			t.Errorf("El intento %d tiene %d registros, se esperaban 6000 (archivos pisados por el otro intento)", attempt, lines)
+		}
+	}
+
+	// Descartar el intento perdedor no toca los archivos del ganador
+	discardOutputs(task, metaPaths(results[2]))
+	for _, meta := range results[1] {
+		if _, err := os.Stat(meta.Path); err != nil {
+			t.Errorf("Descartar el intento 2 borró %s del intento 1", meta.Path)
+		}
+	}
+}
+
+func TestExecutor_FusedPipeline(t *testing.T) {
+	tempDir := t.TempDir()
+	inputPath := createInputFile(t, tempDir, "users.csv", "ID,Nombre,Edad,Ciudad\n1,Juan,25,Madrid\n3,Pedro,15,Valencia\n4,Maria,40,Madrid\n")
+
+	// FILTER -> MAP en una sola tarea: la salida del filtro nunca toca disco
+	task := createMockTask("job-fused", "map-prep", common.OpTypeFilter, "filter_adults", common.OutputTypeShuffle, 1, inputPath, nil)
+	task.Pipeline = []common.OperationNode{{ID: "map-prep", Type: common.OpTypeMap, UDFName: "map_identity"}}
+
+	metas, err := GlobalExecutor.Submit(context.Background(), task)
+	if err != nil {
+		t.Fatalf("Submit falló: %v", err)
+	}
+	output := readOutputFile(t, metas[0].Path)
+	if strings.Count(output, `"key":"adultos"`) != 2 || strings.Contains(output, "Pedro") {
+		t.Errorf("Pipeline fusionado incorrecto:\n%s", output)
+	}
+
+	// Una operación no narrow en el pipeline es un error de planificación
+	task.Pipeline = []common.OperationNode{{Type: common.OpTypeReduceByKey, UDFName: "reduce_sum"}}
+	if _, err := GlobalExecutor.Submit(context.Background(), task); err == nil {
+		t.Errorf("Se esperaba error al fusionar un REDUCE en el pipeline")
+	}
+}
+
 func TestExecutor_ReduceAndJoin(t *testing.T) {
 	// --- 1. Configurar Servidor HTTP Mock para el Shuffle ---
 	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -182,10 +319,12 @@ func TestExecutor_ReduceAndJoin(t *testing.T) {
 	// Contenido del Map-side: Claves para REDUCE (a:2) y JOIN (a:L1 + a:R1)
 	pathA := createInputFile(t, tempDir, "map_a.jsonl", `{"key":"a","value":"1"}`+"\n"+`{"key":"b","value":"1"}`+"\n")
 	pathB := createInputFile(t, tempDir, "map_b.jsonl", `{"key":"a","value":"1"}`+"\n"+`{"key":"c","value":"1"}`+"\n")
-	// Contenido de JOIN etiquetado (L=Left, R=Right)
-	pathJoin := createInputFile(t, tempDir, "join_data.jsonl", 
-		`{"key":"a","value":"L:L1"}`+"\n"+ // Usuario A (Left)
-		`{"key":"a","value":"R:R1"}`+"\n") // Orden A (Right)
+	// Contenido de JOIN: cada shuffle viene etiquetado con la etapa que lo produjo (Source)
+	pathUsers := createInputFile(t, tempDir, "join_users.jsonl",
+		`{"key":"a","value":"L1","source":"map-users"}`+"\n"+ // Usuario A (Left)
+		`{"key":"b","value":"L2","source":"map-users"}`+"\n") // Usuario B sin pedidos
+	pathOrders := createInputFile(t, tempDir, "join_orders.jsonl",
+		`{"key":"a","value":"R1","source":"map-orders"}`+"\n") // Orden A (Right)
 	
 	// ShuffleMap para las pruebas
 	reduceShuffleMap := map[string]string{
@@ -193,7 +332,8 @@ func TestExecutor_ReduceAndJoin(t *testing.T) {
 		"w2-B": fmt.Sprintf("%s/?path=%s", serverURL, pathB),
 	}
 	joinShuffleMap := map[string]string{
-		"w1-Join": fmt.Sprintf("%s/?path=%s", serverURL, pathJoin),
+		"w1-Users":  fmt.Sprintf("%s/?path=%s", serverURL, pathUsers),
+		"w2-Orders": fmt.Sprintf("%s/?path=%s", serverURL, pathOrders),
 	}
 
 	tests := []struct {
@@ -202,6 +342,7 @@ func TestExecutor_ReduceAndJoin(t *testing.T) {
 		udfName        string
 		shuffleMap     map[string]string
 		expectedOutput string 
+		unexpected     string // Texto que NO debe aparecer en la salida
 		expectErr      bool
 	}{
 		{
@@ -218,6 +359,7 @@ func TestExecutor_ReduceAndJoin(t *testing.T) {
 			udfName:        "join_users_orders", // Usar la UDF de Join real
 			shuffleMap:     joinShuffleMap,
 			expectedOutput: `"key":"a","value":"L1 compro R1"`, // La UDF Join genera un string complejo, solo verificamos la clave
+			unexpected:     `"key":"b"`,                        // INNER: sin lado derecho no hay salida
 			expectErr:      false,
 		},
 	}
@@ -234,8 +376,9 @@ func TestExecutor_ReduceAndJoin(t *testing.T) {
 				"", 
 				tt.shuffleMap,
 			)
+			task.Operation.Dependencies = []string{"map-users", "map-orders"}
 
-			metas, err := GlobalExecutor.Submit(task)
+			metas, err := GlobalExecutor.Submit(context.Background(), task)
 
 			if (err != nil) != tt.expectErr {
 				t.Fatalf("Submit falló. Esperaba error=%t, obtuvo: %v", tt.expectErr, err)
@@ -251,6 +394,89 @@ func TestExecutor_ReduceAndJoin(t *testing.T) {
 			if !strings.Contains(output, tt.expectedOutput) {
 				t.Errorf("Salida incorrecta. Falta '%s' en:\n%s", tt.expectedOutput, output)
 			}
+			if tt.unexpected != "" && strings.Contains(output, tt.unexpected) {
+				t.Errorf("Salida incorrecta. No debía aparecer '%s' en:\n%s", tt.unexpected, output)
+			}
 		})
 	}
-}
\ No newline at end of file
+}
+func TestExecutor_Cancellation(t *testing.T) {
+	// Shuffle que entrega un registro y luego se cuelga hasta que el cliente corta la conexión
+	stuck := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"key":"a","value":"1"}` + "\n"))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer stuck.Close()
+
+	t.Run("Contexto cancelado descarta la salida", func(t *testing.T) {
+		tempDir := t.TempDir()
+		inputPath := createInputFile(t, tempDir, "in.txt", "hola\nmundo\n")
+		task := createMockTask("job-cancel", "filter", common.OpTypeFilter, "not_empty", common.OutputTypeFinal, 1, inputPath, nil)
+		task.OutputTarget.Path = filepath.Join(tempDir, "out")
+		task.Attempt = 1
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if _, err := GlobalExecutor.Submit(ctx, task); !errors.Is(err, context.Canceled) {
+			t.Fatalf("Se esperaba context.Canceled, obtuvo %v", err)
+		}
+		attemptDir := filepath.Dir(common.AttemptOutputPath(task.OutputTarget.Path, task.TaskID, 1, 0))
+		if _, err := os.Stat(attemptDir); !os.IsNotExist(err) {
+			t.Errorf("El intento cancelado dejó archivos en %s", attemptDir)
+		}
+	})
+
+	t.Run("Timeout durante la descarga del shuffle", func(t *testing.T) {
+		task := createMockTask("job-stuck", "reduce", common.OpTypeReduceByKey, "reduce_sum", common.OutputTypeShuffle, 1, "", map[string]string{"w1": stuck.URL})
+		task.OutputTarget.Path = filepath.Join(t.TempDir(), "shuffle")
+
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		if _, err := GlobalExecutor.Submit(ctx, task); err == nil {
+			t.Fatalf("Se esperaba error por timeout")
+		}
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Errorf("La tarea no se detuvo al expirar el contexto (%s)", elapsed)
+		}
+		if busy := len(GlobalExecutor.semaphore); busy != 0 {
+			t.Errorf("La tarea expirada sigue ocupando %d hilos del pool", busy)
+		}
+	})
+
+	t.Run("Cancelación mientras espera un hilo libre", func(t *testing.T) {
+		// Pool lleno: la tarea no debe quedarse esperando más allá de su límite
+		for i := 0; i < cap(GlobalExecutor.semaphore); i++ { GlobalExecutor.semaphore <- struct{}{} }
+		defer func() {
+			for i := 0; i < cap(GlobalExecutor.semaphore); i++ { <-GlobalExecutor.semaphore }
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		task := createMockTask("job-wait", "filter", common.OpTypeFilter, "not_empty", common.OutputTypeShuffle, 1, "", nil)
+		if _, err := GlobalExecutor.Submit(ctx, task); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Se esperaba context.DeadlineExceeded, obtuvo %v", err)
+		}
+	})
+}
+
+func TestExecutor_FetchFailure(t *testing.T) {
+	// El worker que tenía el shuffle ya no lo sirve
+	gone := httptest.NewServer(http.NotFoundHandler())
+	defer gone.Close()
+	host := gone.Listener.Addr().String()
+
+	task := createMockTask("job-fetch", "reduce", common.OpTypeReduceByKey, "reduce_sum", common.OutputTypeShuffle, 1, "",
+		map[string]string{"w1": fmt.Sprintf("http://%s/shuffle?path=/tmp/map-0_part_0", host)})
+	task.OutputTarget.Path = filepath.Join(t.TempDir(), "shuffle")
+
+	_, err := GlobalExecutor.Submit(context.Background(), task)
+	var ff *FetchFailedError
+	if !errors.As(err, &ff) {
+		t.Fatalf("Se esperaba FetchFailedError, obtuvo %v", err)
+	}
+	if ff.WorkerID != host || ff.Path != "/tmp/map-0_part_0" {
+		t.Errorf("La falla debe nombrar la salida perdida, obtuvo %s:%s", ff.WorkerID, ff.Path)
+	}
+}
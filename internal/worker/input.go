@@ -0,0 +1,174 @@
+package worker
+
+import (
+	"bufio"
+	"compress/bzip2"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"mini-spark/internal/common"
+)
+
+// ==========================================
+// CAPA DE ENTRADA (Archivos fuente)
+// ==========================================
+
+// splitReader lee las líneas de un rango de bytes [start, end) de un archivo, alineado a líneas
+// como el TextInputFormat de Hadoop:
+//   - si start > 0, la línea que cruza start pertenece al split anterior y se descarta;
+//   - se leen todas las líneas que EMPIEZAN antes de end (la última puede terminar después).
+// Así cada línea del archivo la procesa exactamente un split.
+type splitReader struct {
+	file   *os.File
+	reader *bufio.Reader
+	pos    int64 // Offset del inicio de la próxima línea
+	end    int64 // Fin del rango (exclusivo); -1 = hasta EOF
+}
+
+// openSplit abre path y se posiciona en la primera línea completa del rango. end < 0 lee hasta EOF.
+// Los archivos comprimidos se descomprimen al vuelo y no admiten rangos: el split que empieza en 0
+// lee el archivo completo y cualquier otro queda vacío.
+func openSplit(path string, start, end int64) (*splitReader, error) {
+	f, err := os.Open(path)
+	if err != nil { return nil, err }
+
+	if codec := common.InputCodec(path); codec != common.CodecNone {
+		s := &splitReader{file: f, end: -1}
+		if start > 0 {
+			s.end = 0 // El archivo entero pertenece al split que empieza en 0
+			return s, nil
+		}
+		r, err := decompress(codec, f)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("error abriendo %s (%s): %w", path, codec, err)
+		}
+		s.reader = bufio.NewReader(r)
+		return s, nil
+	}
+
+	s := &splitReader{file: f, end: end}
+	if start > 0 {
+		// Retroceder un byte: si el rango empieza justo tras un '\n', la línea de start es nuestra
+		if _, err := f.Seek(start-1, io.SeekStart); err != nil {
+			f.Close()
+			return nil, err
+		}
+		s.reader = bufio.NewReader(f)
+		skipped, err := s.reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			f.Close()
+			return nil, err
+		}
+		s.pos = start - 1 + int64(len(skipped))
+	} else {
+		s.reader = bufio.NewReader(f)
+	}
+	return s, nil
+}
+
+// Next devuelve la siguiente línea del split (sin salto de línea) y el offset donde empieza.
+// ok = false cuando el split terminó.
+func (s *splitReader) Next() (line string, offset int64, ok bool, err error) {
+	if s.end >= 0 && s.pos >= s.end {
+		return "", 0, false, nil
+	}
+	raw, err := s.reader.ReadString('\n')
+	if len(raw) == 0 {
+		if err == io.EOF { return "", 0, false, nil }
+		return "", 0, false, err
+	}
+	if err != nil && err != io.EOF {
+		return "", 0, false, err
+	}
+	offset = s.pos
+	s.pos += int64(len(raw))
+	line = strings.TrimSuffix(strings.TrimSuffix(raw, "\n"), "\r")
+	return line, offset, true, nil
+}
+
+func (s *splitReader) Close() error { return s.file.Close() }
+
+// decompress envuelve el archivo con el lector del codec indicado
+func decompress(codec string, r io.Reader) (io.Reader, error) {
+	switch codec {
+	case common.CodecGzip:
+		return gzip.NewReader(r) // Soporta varios miembros concatenados (cat a.gz b.gz)
+	case common.CodecBzip2:
+		return bzip2.NewReader(r), nil
+	}
+	return nil, fmt.Errorf("codec no soportado: %s", codec)
+}
+
+// inputReader encadena los rangos asignados a una tarea (de uno o varios archivos) y los lee en orden.
+// Cada rango se abre al llegar a él, así una tarea con cientos de archivos pequeños solo mantiene uno abierto.
+type inputReader struct {
+	splits  []common.FileSplit // Rangos pendientes; Offsets[1] < 0 = hasta EOF
+	current *splitReader
+	path    string // Archivo del rango en curso
+}
+
+func openInput(splits []common.FileSplit) *inputReader {
+	return &inputReader{splits: splits}
+}
+
+// Next devuelve la siguiente línea de la entrada y su offset dentro de su archivo (ver Path).
+func (r *inputReader) Next() (line string, offset int64, ok bool, err error) {
+	for {
+		if r.current == nil {
+			if len(r.splits) == 0 { return "", 0, false, nil }
+			next := r.splits[0]
+			r.splits = r.splits[1:]
+			if r.current, err = openSplit(next.Path, next.Offsets[0], next.Offsets[1]); err != nil {
+				return "", 0, false, err
+			}
+			r.path = next.Path
+		}
+		line, offset, ok, err = r.current.Next()
+		if ok || err != nil { return line, offset, ok, err }
+		r.current.Close()
+		r.current = nil
+	}
+}
+
+// Path devuelve el archivo de la última línea leída.
+func (r *inputReader) Path() string { return r.path }
+
+func (r *inputReader) Close() error {
+	if r.current == nil { return nil }
+	return r.current.Close()
+}
+
+// taskSplits devuelve los rangos que debe leer una tarea de archivo y si además debe repartir
+// las líneas por módulo (archivo sin rango: el Master no pudo verlo y cada tarea lo recorre entero).
+func taskSplits(input common.TaskInput) (splits []common.FileSplit, splitByLine bool) {
+	switch {
+	case input.Splits != nil:
+		return input.Splits, false
+	case hasByteRange(input):
+		return []common.FileSplit{{Path: input.Path, Offsets: input.Offsets}}, false
+	default:
+		return []common.FileSplit{{Path: input.Path, Offsets: [2]int64{0, -1}}}, true
+	}
+}
+
+// hasByteRange indica si la tarea trae un rango de bytes asignado por el Scheduler.
+// Offsets = {0,0} significa "sin rango": la tarea recorre el archivo entero (reparto por módulo de línea).
+func hasByteRange(input common.TaskInput) bool {
+	return input.Offsets != [2]int64{0, 0}
+}
+
+// skipInputLine indica si una línea del archivo fuente debe descartarse según el formato declarado.
+// offset es la posición de la línea en el archivo (0 = primera línea, la cabecera).
+func skipInputLine(input common.TaskInput, offset int64, line string) bool {
+	if input.SourceType != common.SourceTypeFile { return false }
+	if input.SkipHeader && offset == 0 { return true }
+	if input.Format == common.InputFormatJSONL {
+		return !json.Valid([]byte(line))
+	}
+	return false
+}
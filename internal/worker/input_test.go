@@ -0,0 +1,182 @@
+package worker
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"mini-spark/internal/common"
+	"mini-spark/internal/dag"
+)
+
+// readAllSplits lee el archivo partido en n rangos y devuelve las líneas de cada split
+func readAllSplits(t *testing.T, path string, size int64, n int) [][]string {
+	var out [][]string
+	for _, r := range dag.ByteRangeSplits(size, n) {
+		s, err := openSplit(path, r[0], r[1])
+		if err != nil {
+			t.Fatalf("openSplit(%v) falló: %v", r, err)
+		}
+		var lines []string
+		for {
+			line, _, ok, err := s.Next()
+			if err != nil {
+				t.Fatalf("Next falló: %v", err)
+			}
+			if !ok {
+				break
+			}
+			lines = append(lines, line)
+		}
+		s.Close()
+		out = append(out, lines)
+	}
+	return out
+}
+
+func TestSplitReader_EveryLineOnce(t *testing.T) {
+	tempDir := t.TempDir()
+	contents := map[string]string{
+		"Lineas_Variadas": "a\nbb\nccc\ndddd\neeeee\nf\n\nggggggg\nh\n",
+		"Sin_Salto_Final": "uno\ndos\ntres",
+		"CRLF":            "x1\r\nx2\r\nx3\r\n",
+		"Una_Linea_Larga": strings.Repeat("z", 100) + "\n",
+	}
+
+	for name, content := range contents {
+		path := createInputFile(t, tempDir, name+".txt", content)
+		expected := strings.Split(strings.TrimSuffix(strings.ReplaceAll(content, "\r\n", "\n"), "\n"), "\n")
+
+		for _, n := range []int{1, 2, 3, 7, len(content) + 5} {
+			t.Run(fmt.Sprintf("%s_%d", name, n), func(t *testing.T) {
+				var got []string
+				for _, lines := range readAllSplits(t, path, int64(len(content)), n) {
+					got = append(got, lines...)
+				}
+				if strings.Join(got, "|") != strings.Join(expected, "|") {
+					t.Errorf("Líneas leídas incorrectas.\nEsperado: %q\nObtenido: %q", expected, got)
+				}
+			})
+		}
+	}
+}
+
+func TestExecutor_ByteRangeTasks(t *testing.T) {
+	tempDir := t.TempDir()
+	content := "ID,Nombre\n1,Ana\n2,Luis\n3,Eva\n4,Juan\n5,Sara\n"
+	inputPath := createInputFile(t, tempDir, "users.csv", content)
+
+	// Cada tarea recibe su rango: la cabecera solo la ve (y la descarta) el primer split
+	n := 3
+	var got []string
+	for i, r := range dag.ByteRangeSplits(int64(len(content)), n) {
+		task := createMockTask("job-ranges", "filter", common.OpTypeFilter, "not_empty", common.OutputTypeLocalSpill, n, inputPath, nil)
+		task.TaskID = fmt.Sprintf("job-ranges-filter-%d", i)
+		task.PartitionIndex = i
+		task.InputPartition.Format = common.InputFormatCSV
+		task.InputPartition.SkipHeader = true
+		task.InputPartition.Offsets = r
+		task.OutputTarget.NumPartitions = 1
+
+		metas, err := GlobalExecutor.Submit(context.Background(), task)
+		if err != nil {
+			t.Fatalf("Submit falló: %v", err)
+		}
+		got = append(got, strings.Fields(readOutputFile(t, metas[0].Path))...)
+	}
+
+	sort.Strings(got)
+	expected := []string{"1,Ana", "2,Luis", "3,Eva", "4,Juan", "5,Sara"}
+	if strings.Join(got, "|") != strings.Join(expected, "|") {
+		t.Errorf("Registros incorrectos entre splits. Esperado %v, obtuvo %v", expected, got)
+	}
+}
+
+func TestExecutor_MultiFileSplits(t *testing.T) {
+	tempDir := t.TempDir()
+	a := createInputFile(t, tempDir, "a.csv", "ID,Nombre\n1,Ana\n2,Luis\n")
+	b := createInputFile(t, tempDir, "b.csv", "ID,Nombre\n3,Eva\n")
+
+	// Una sola tarea con dos archivos (el segundo a partir de un rango): la cabecera se descarta en cada uno
+	task := createMockTask("job-multi", "filter", common.OpTypeFilter, "not_empty", common.OutputTypeLocalSpill, 1, "", nil)
+	task.InputPartition.Format = common.InputFormatCSV
+	task.InputPartition.SkipHeader = true
+	task.InputPartition.IncludeFileName = true
+	task.InputPartition.Splits = []common.FileSplit{
+		{Path: a, Offsets: [2]int64{0, 14}}, // Cabecera y "1,Ana"
+		{Path: b, Offsets: [2]int64{0, 16}},
+	}
+
+	metas, err := GlobalExecutor.Submit(context.Background(), task)
+	if err != nil {
+		t.Fatalf("Submit falló: %v", err)
+	}
+	got := strings.Split(strings.TrimSpace(readOutputFile(t, metas[0].Path)), "\n")
+	expected := []string{a + "\t1,Ana", b + "\t3,Eva"}
+	if strings.Join(got, "|") != strings.Join(expected, "|") {
+		t.Errorf("Registros incorrectos.\nEsperado: %q\nObtenido: %q", expected, got)
+	}
+
+	// Una lista vacía significa que la tarea no tiene datos (no que lea Path entero)
+	task.TaskID = "job-multi-filter-1"
+	task.InputPartition.Path = a
+	task.InputPartition.Splits = []common.FileSplit{}
+	metas, err = GlobalExecutor.Submit(context.Background(), task)
+	if err != nil {
+		t.Fatalf("Submit falló: %v", err)
+	}
+	if out := readOutputFile(t, metas[0].Path); out != "" {
+		t.Errorf("Una tarea sin splits no debería producir salida, obtuvo %q", out)
+	}
+}
+
+// "hola mundo\nadios mundo\n" comprimido con bzip2 (la librería estándar solo trae el lector)
+var bzip2Sample = []byte{
+	0x42, 0x5a, 0x68, 0x39, 0x31, 0x41, 0x59, 0x26, 0x53, 0x59, 0x6d, 0x43,
+	0x19, 0xeb, 0x00, 0x00, 0x04, 0xd1, 0x80, 0x00, 0x10, 0x40, 0x00, 0x24,
+	0x67, 0x8a, 0x00, 0x20, 0x00, 0x21, 0x29, 0x36, 0xa1, 0xa6, 0xd2, 0x10,
+	0x34, 0x0d, 0x0b, 0x13, 0xf6, 0xd3, 0x26, 0xbb, 0x46, 0x08, 0xb7, 0xcc,
+	0x3e, 0x2e, 0xe4, 0x8a, 0x70, 0xa1, 0x20, 0xda, 0x86, 0x33, 0xd6,
+}
+
+func TestSplitReader_CompressedInputs(t *testing.T) {
+	tempDir := t.TempDir()
+
+	// Dos miembros gzip concatenados, como produce "cat a.gz b.gz"
+	var gz bytes.Buffer
+	for _, part := range []string{"hola mundo\n", "adios mundo\n"} {
+		w := gzip.NewWriter(&gz)
+		w.Write([]byte(part))
+		w.Close()
+	}
+	files := map[string][]byte{"logs.txt.gz": gz.Bytes(), "logs.txt.bz2": bzip2Sample}
+
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(tempDir, name)
+			if err := os.WriteFile(path, content, 0644); err != nil {
+				t.Fatal(err)
+			}
+			// Aunque lleguen varios rangos, solo el que empieza en 0 lee (todo) el archivo
+			var got []string
+			for _, lines := range readAllSplits(t, path, int64(len(content)), 3) {
+				got = append(got, lines...)
+			}
+			if strings.Join(got, "|") != "hola mundo|adios mundo" {
+				t.Errorf("Contenido descomprimido incorrecto: %q", got)
+			}
+		})
+	}
+
+	// Un archivo corrupto es un error de la tarea, no basura en la salida
+	bad := createInputFile(t, tempDir, "roto.gz", "esto no es gzip\n")
+	if _, err := openSplit(bad, 0, -1); err == nil {
+		t.Errorf("Se esperaba error al abrir un gzip inválido")
+	}
+}
@@ -0,0 +1,156 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"mini-spark/internal/common"
+	"mini-spark/internal/udf"
+)
+
+// ==========================================
+// JOINS (Interno y Externos)
+// ==========================================
+
+// joinSides devuelve los IDs de las etapas que alimentan el lado izquierdo y derecho de un JOIN.
+func joinSides(node common.OperationNode) (string, string) {
+	var left, right string
+	if len(node.Dependencies) > 0 { left = node.Dependencies[0] }
+	if len(node.Dependencies) > 1 { right = node.Dependencies[1] }
+	return left, right
+}
+
+// resolveJoinFunction adapta la UDF del nodo a un UDFJoinFn.
+// JOIN acepta una UDFJoinFn (la UDF cruza) o una UDFJoinPairFn (cruza el motor).
+// Los joins externos exigen UDFJoinPairFn: el motor decide qué lado falta y la UDF solo formatea.
+func resolveJoinFunction(node common.OperationNode) (udf.UDFJoinFn, error) {
+	if node.Type == common.OpTypeJoin {
+		if fn, err := udf.GetJoinFunction(node.UDFName); err == nil {
+			return fn, nil
+		}
+	}
+	pairFn, err := udf.GetJoinPairFunction(node.UDFName)
+	if err != nil {
+		return nil, fmt.Errorf("%s requiere una UDF de join por pares: %w", node.Type, err)
+	}
+	return func(key string, left []string, right []string) []udf.Record {
+		var results []udf.Record
+		expandJoin(node.Type, left, right, func(l, r *string) {
+			results = append(results, pairFn(key, l, r))
+		})
+		return results
+	}, nil
+}
+
+// expandJoin genera los pares de una clave según el tipo de join. El lado ausente se pasa como nil.
+func expandJoin(joinType string, left, right []string, emit func(l, r *string)) {
+	keepLeft := joinType == common.OpTypeLeftOuterJoin || joinType == common.OpTypeFullOuterJoin
+	keepRight := joinType == common.OpTypeRightOuterJoin || joinType == common.OpTypeFullOuterJoin
+
+	switch {
+	case len(left) > 0 && len(right) > 0:
+		for i := range left {
+			for j := range right {
+				emit(&left[i], &right[j])
+			}
+		}
+	case len(left) > 0 && keepLeft:
+		for i := range left {
+			emit(&left[i], nil)
+		}
+	case len(right) > 0 && keepRight:
+		for j := range right {
+			emit(nil, &right[j])
+		}
+	}
+}
+
+// broadcastTables guarda en memoria los lados replicados de una tarea: etapa productora (KeyValue.Source)
+// -> clave -> valores.
+type broadcastTables map[string]map[string][]string
+
+// loadBroadcastTables descarga completo el lado replicado de la tarea (BroadcastMap)
+func loadBroadcastTables(ctx context.Context, task common.Task) (broadcastTables, error) {
+	tables := make(broadcastTables)
+	for _, url := range task.InputPartition.BroadcastMap {
+		err := streamShuffle(ctx, url, func(kv common.KeyValue) {
+			if tables[kv.Source] == nil { tables[kv.Source] = make(map[string][]string) }
+			tables[kv.Source][kv.Key] = append(tables[kv.Source][kv.Key], kv.Value)
+		})
+		if err != nil { return nil, err }
+	}
+	return tables, nil
+}
+
+// taskPipeline prepara las operaciones fusionadas de la tarea. Si incluyen un JOIN (broadcast join
+// fusionado en el stage del lado que se recorre), antes carga el lado replicado.
+func taskPipeline(ctx context.Context, task common.Task, nodes []common.OperationNode) (func(udf.Record) []udf.Record, error) {
+	var tables broadcastTables
+	for _, node := range nodes {
+		if !common.IsJoinOp(node.Type) { continue }
+		var err error
+		if tables, err = loadBroadcastTables(ctx, task); err != nil { return nil, err }
+		break
+	}
+	return buildPipeline(nodes, tables)
+}
+
+// replicatedStage devuelve la etapa del lado replicado de un broadcast join
+func replicatedStage(node common.OperationNode) string {
+	left, right := joinSides(node)
+	if node.Broadcast == common.BroadcastLeft { return left }
+	return right
+}
+
+// broadcastProbe cruza un registro del lado que se recorre contra la tabla del lado replicado
+func broadcastProbe(node common.OperationNode, table map[string][]string) (func(common.KeyValue) []udf.Record, error) {
+	joinFn, err := resolveJoinFunction(node)
+	if err != nil { return nil, err }
+	replicatedLeft := node.Broadcast == common.BroadcastLeft
+	return func(kv common.KeyValue) []udf.Record {
+		left, right := []string{kv.Value}, table[kv.Key]
+		if replicatedLeft { left, right = right, left }
+		return joinFn(kv.Key, left, right)
+	}, nil
+}
+
+// pipelineProbe adapta broadcastProbe a un paso del pipeline: los registros sin clave no tienen con
+// qué cruzarse (igual que en un JOIN por shuffle)
+func pipelineProbe(node common.OperationNode, tables broadcastTables) (func(udf.Record) []udf.Record, error) {
+	probe, err := broadcastProbe(node, tables[replicatedStage(node)])
+	if err != nil { return nil, err }
+	return func(r udf.Record) []udf.Record {
+		var kv common.KeyValue
+		if err := json.Unmarshal([]byte(r), &kv); err != nil || kv.Key == "" { return nil }
+		return probe(kv)
+	}, nil
+}
+
+// executeBroadcastJoin ejecuta una tarea de BROADCAST_JOIN: carga en memoria el lado replicado
+// (BroadcastMap) y cruza cada registro del otro lado (ShuffleMap) a medida que lo descarga, sin
+// agruparlo por clave ni pasarlo por disco.
+func executeBroadcastJoin(ctx context.Context, task common.Task) ([]common.ShuffleMeta, error) {
+	tables, err := loadBroadcastTables(ctx, task)
+	if err != nil { return nil, err }
+	probeFn, err := broadcastProbe(task.Operation, tables[replicatedStage(task.Operation)])
+	if err != nil { return nil, err }
+	postFn, err := buildPipeline(task.Pipeline, tables)
+	if err != nil { return nil, err }
+
+	write, finish, err := openReduceOutput(task)
+	if err != nil { return nil, err }
+	probe := func(kv common.KeyValue) {
+		for _, r := range probeFn(kv) {
+			for _, out := range postFn(r) { write(out) }
+		}
+	}
+	for _, url := range task.InputPartition.ShuffleMap {
+		if err := streamShuffle(ctx, url, probe); err != nil {
+			metas, _ := finish()
+			discardOutputs(task, metaPaths(metas))
+			return nil, err
+		}
+	}
+	return finish()
+}
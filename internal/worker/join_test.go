@@ -0,0 +1,149 @@
+package worker
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"mini-spark/internal/common"
+)
+
+func TestExpandJoin(t *testing.T) {
+	users := []string{"ana"}
+	orders := []string{"laptop", "mouse"}
+
+	tests := []struct {
+		name     string
+		joinType string
+		left     []string
+		right    []string
+		expected []string
+	}{
+		{name: "INNER_Ambos", joinType: common.OpTypeJoin, left: users, right: orders, expected: []string{"ana|laptop", "ana|mouse"}},
+		{name: "INNER_SinDerecho", joinType: common.OpTypeJoin, left: users, right: nil, expected: nil},
+		{name: "LEFT_SinDerecho", joinType: common.OpTypeLeftOuterJoin, left: users, right: nil, expected: []string{"ana|null"}},
+		{name: "LEFT_SinIzquierdo", joinType: common.OpTypeLeftOuterJoin, left: nil, right: orders, expected: nil},
+		{name: "RIGHT_SinIzquierdo", joinType: common.OpTypeRightOuterJoin, left: nil, right: orders, expected: []string{"null|laptop", "null|mouse"}},
+		{name: "FULL_SinDerecho", joinType: common.OpTypeFullOuterJoin, left: users, right: nil, expected: []string{"ana|null"}},
+		{name: "FULL_SinIzquierdo", joinType: common.OpTypeFullOuterJoin, left: nil, right: []string{"cable"}, expected: []string{"null|cable"}},
+		{name: "FULL_Ambos", joinType: common.OpTypeFullOuterJoin, left: users, right: []string{"cable"}, expected: []string{"ana|cable"}},
+	}
+
+	side := func(v *string) string {
+		if v == nil {
+			return "null"
+		}
+		return *v
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			expandJoin(tt.joinType, tt.left, tt.right, func(l, r *string) {
+				got = append(got, side(l)+"|"+side(r))
+			})
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Pares incorrectos. Esperado %v, obtuvo %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestResolveJoinFunction(t *testing.T) {
+	tests := []struct {
+		name      string
+		opType    string
+		udfName   string
+		expectErr bool
+	}{
+		{name: "JOIN_UDFCruzaTodo", opType: common.OpTypeJoin, udfName: "join_users_orders"},
+		{name: "JOIN_UDFPorPares", opType: common.OpTypeJoin, udfName: "join_pair_concat"},
+		{name: "LEFT_UDFPorPares", opType: common.OpTypeLeftOuterJoin, udfName: "join_users_orders_pair"},
+		{name: "FULL_UDFNoEsPar", opType: common.OpTypeFullOuterJoin, udfName: "join_users_orders", expectErr: true},
+		{name: "RIGHT_UDFInexistente", opType: common.OpTypeRightOuterJoin, udfName: "no_existe", expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fn, err := resolveJoinFunction(common.OperationNode{Type: tt.opType, UDFName: tt.udfName})
+			if (err != nil) != tt.expectErr {
+				t.Fatalf("Se esperaba error=%t, obtuvo: %v", tt.expectErr, err)
+			}
+			if !tt.expectErr && len(fn("k", []string{"a"}, []string{"b"})) != 1 {
+				t.Errorf("La UDF resuelta no produjo el par esperado")
+			}
+		})
+	}
+}
+
+func TestExecutor_BroadcastJoin(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, r.URL.Query().Get("path"))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	users := createInputFile(t, dir, "users.jsonl", `{"key":"a","value":"ana","source":"users"}`+"\n")
+	orders := createInputFile(t, dir, "orders.jsonl",
+		`{"key":"a","value":"laptop","source":"orders"}`+"\n"+
+		`{"key":"a","value":"mouse","source":"orders"}`+"\n"+
+		`{"key":"z","value":"cable","source":"orders"}`+"\n")
+
+	task := createMockTask("job-bcast", "join", common.OpTypeLeftOuterJoin, "join_pair_concat", common.OutputTypeLocalSpill, 1, "",
+		map[string]string{"w1-orders": server.URL + "/?path=" + orders})
+	task.Operation.Dependencies = []string{"orders", "users"}
+	task.Operation.Broadcast = common.BroadcastRight
+	task.InputPartition.BroadcastMap = map[string]string{"w2-users": server.URL + "/?path=" + users}
+
+	metas, err := executeTaskLogic(context.Background(), task)
+	if err != nil { t.Fatalf("Error en el BROADCAST_JOIN: %v", err) }
+	output := readOutputFile(t, metas[0].Path)
+	for _, want := range []string{`"laptop|ana"`, `"mouse|ana"`, `"cable|null"`} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Falta %s en la salida:\n%s", want, output)
+		}
+	}
+}
+
+func TestExecutor_MapSideBroadcastJoin(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, r.URL.Query().Get("path"))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	users := createInputFile(t, dir, "users.jsonl",
+		`{"key":"1","value":"ana","source":"users"}`+"\n"+
+		`{"key":"2","value":"beto","source":"users"}`+"\n")
+	orders := createInputFile(t, dir, "orders.csv", "100,1,laptop\n101,1,mouse\n102,9,cable\n")
+
+	// El stage de orders lee su archivo y cruza cada registro contra la tabla de users (lado izquierdo)
+	task := createMockTask("job-mapside", "joined", common.OpTypeMap, "map_parse_orders", common.OutputTypeLocalSpill, 1, orders, nil)
+	task.Pipeline = []common.OperationNode{{
+		ID: "joined", Type: common.OpTypeJoin, UDFName: "join_pair_concat",
+		Dependencies: []string{"users", "orders"}, Broadcast: common.BroadcastLeft,
+	}}
+	task.InputPartition.BroadcastMap = map[string]string{"w2-users": server.URL + "/?path=" + users}
+
+	metas, err := executeTaskLogic(context.Background(), task)
+	if err != nil { t.Fatalf("Error en el JOIN del lado map: %v", err) }
+	output := readOutputFile(t, metas[0].Path)
+	for _, want := range []string{`"ana|laptop"`, `"ana|mouse"`} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Falta %s en la salida:\n%s", want, output)
+		}
+	}
+	if strings.Contains(output, "cable") || strings.Contains(output, "beto") {
+		t.Errorf("El JOIN interno no debía emitir claves sin pareja:\n%s", output)
+	}
+
+	// Sin la tabla replicada la tarea falla en lugar de producir un cruce vacío
+	task.InputPartition.BroadcastMap = map[string]string{"w2-users": server.URL + "/?path=" + filepath.Join(dir, "no_existe")}
+	if _, err := executeTaskLogic(context.Background(), task); err == nil {
+		t.Errorf("Se esperaba un error al no poder cargar el lado replicado")
+	}
+}
@@ -1 +1,68 @@
 package worker
+
+import (
+	"sort"
+
+	"mini-spark/internal/common"
+)
+
+// ==========================================
+// MUESTRA DE CLAVES DEL SHUFFLE
+// ==========================================
+// Cada bloque de shuffle reporta sus claves más pesadas (ShuffleMeta.HotKeys). Con esa muestra el
+// Master detecta claves calientes y reparte su partición entre varias tareas.
+
+const (
+	KeySampleSize    = 8  // Claves reportadas por bloque de shuffle
+	keySketchEntries = 32 // Contadores por bloque: acota la memoria aunque haya millones de claves distintas
+)
+
+// keySketch estima las claves con más bytes de un bloque con el algoritmo Space-Saving: con los
+// contadores llenos, una clave nueva reemplaza a la más liviana y hereda su peso. Las estimaciones
+// pueden exceder el valor real, pero toda clave que pese más que el mínimo de la tabla está en ella.
+type keySketch struct {
+	counts map[string]*common.KeyCount
+}
+
+func newKeySketch() *keySketch {
+	return &keySketch{counts: make(map[string]*common.KeyCount)}
+}
+
+func (k *keySketch) Add(key string, bytes int64) {
+	if c, ok := k.counts[key]; ok {
+		c.Records++
+		c.Bytes += bytes
+		return
+	}
+	if len(k.counts) < keySketchEntries {
+		k.counts[key] = &common.KeyCount{Key: key, Records: 1, Bytes: bytes}
+		return
+	}
+	var lightest *common.KeyCount
+	for _, c := range k.counts {
+		if lightest == nil || c.Bytes < lightest.Bytes { lightest = c }
+	}
+	delete(k.counts, lightest.Key)
+	k.counts[key] = &common.KeyCount{Key: key, Records: lightest.Records + 1, Bytes: lightest.Bytes + bytes}
+}
+
+// Top devuelve las n claves más pesadas, de mayor a menor
+func (k *keySketch) Top(n int) []common.KeyCount {
+	top := make([]common.KeyCount, 0, len(k.counts))
+	for _, c := range k.counts { top = append(top, *c) }
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Bytes != top[j].Bytes { return top[i].Bytes > top[j].Bytes }
+		return top[i].Key < top[j].Key
+	})
+	if len(top) > n { top = top[:n] }
+	return top
+}
+
+// keySamples lleva un keySketch por partición de salida
+type keySamples map[int]*keySketch
+
+func (s keySamples) add(partID int, key string, bytes int) {
+	if key == "" { return }
+	if s[partID] == nil { s[partID] = newKeySketch() }
+	s[partID].Add(key, int64(bytes))
+}
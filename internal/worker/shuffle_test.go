@@ -0,0 +1,52 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"mini-spark/internal/common"
+)
+
+func TestKeySketch(t *testing.T) {
+	sketch := newKeySketch()
+	// Una clave dominante entre muchas más claves distintas que contadores
+	for i := 0; i < 1000; i++ {
+		sketch.Add("hot", 10)
+		sketch.Add(fmt.Sprintf("cold-%d", i), 10)
+	}
+	top := sketch.Top(KeySampleSize)
+	if len(top) != KeySampleSize || top[0].Key != "hot" {
+		t.Fatalf("La clave dominante debe encabezar la muestra: %+v", top)
+	}
+	if top[0].Bytes < 10000 || top[0].Records < 1000 {
+		t.Errorf("El peso estimado no puede ser menor al real: %+v", top[0])
+	}
+	if len(sketch.counts) > keySketchEntries {
+		t.Errorf("El resumen debe quedar acotado a %d claves, tiene %d", keySketchEntries, len(sketch.counts))
+	}
+}
+
+func TestExecutor_ShuffleReportsHotKeys(t *testing.T) {
+	var lines []string
+	for i := 0; i < 50; i++ { lines = append(lines, "Ana,30") }
+	inputPath := createInputFile(t, t.TempDir(), "people.txt", strings.Join(lines, "\n")+"\n")
+
+	task := createMockTask("job-hot", "map", common.OpTypeMap, "map_identity", common.OutputTypeShuffle, 2, inputPath, nil)
+	task.Operation.NumPartitions = 1 // Una sola tarea lee todo el archivo; la salida sigue en 2 particiones
+	metas, err := executeTaskLogic(context.Background(), task)
+	if err != nil { t.Fatalf("Error ejecutando el map: %v", err) }
+
+	var sampled *common.KeyCount
+	var size int64
+	for _, meta := range metas {
+		for _, kc := range meta.HotKeys {
+			if kc.Key == "adultos" { sampled, size = &kc, meta.Size }
+		}
+	}
+	if sampled == nil { t.Fatalf("El bloque con la clave 'adultos' debe reportarla: %+v", metas) }
+	if sampled.Records != 50 || sampled.Bytes != size {
+		t.Errorf("La clave ocupa todo su bloque (50 registros, %d bytes): %+v", size, *sampled)
+	}
+}
@@ -0,0 +1,246 @@
+package worker
+
+import (
+	"bufio"
+	"container/heap"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	"mini-spark/internal/common"
+	"mini-spark/internal/udf"
+)
+
+// ==========================================
+// SORT_BY_KEY
+// ==========================================
+// Lado map: la tarea cuyo shuffle lee un SORT_BY_KEY (TaskOutput.Sort) junta sus registros, los ordena
+// por clave (en corridas a disco si no caben en memoria) y escribe un único bloque ordenado con un
+// índice de claves y offsets. Lado sort: la tarea de cada rango descarga de cada bloque solo su tramo,
+// ya ordenado, y los mezcla.
+
+const (
+	sortIndexEntries = 64               // Entradas del índice por bloque ordenado (muestra para los rangos del Master)
+	sortBufferBytes  = 50 * 1024 * 1024 // Registros en memoria antes de volcar una corrida ordenada a disco
+)
+
+type sortedRecord struct {
+	key  string
+	line string
+}
+
+// runSorter ordena registros por clave con corridas a disco: cada vez que el buffer supera el límite
+// se ordena y se vuelca a un archivo; al final se mezclan todas las corridas
+type runSorter struct {
+	less     func(a, b string) bool
+	buf      []sortedRecord
+	bufBytes int64
+	limit    int64
+	total    int64 // Bytes del bloque final (registros + saltos de línea)
+	runs     []string
+}
+
+func newRunSorter(order string) *runSorter {
+	return &runSorter{less: common.KeyLess(order), limit: sortBufferBytes}
+}
+
+// keylessSortError rechaza un registro sin clave en la salida que lee un SORT_BY_KEY: no tiene lugar
+// en el orden, y descartarlo en silencio perdería datos del resultado
+func keylessSortError(task common.Task, line string) error {
+	if len(line) > 80 { line = line[:80] + "..." }
+	return fmt.Errorf("SORT_BY_KEY requiere pares clave/valor: la etapa %s emitió un registro sin clave: %q", task.StageID, line)
+}
+
+func (r *runSorter) Add(key, line string) error {
+	r.buf = append(r.buf, sortedRecord{key, line})
+	r.bufBytes += int64(len(key) + len(line))
+	r.total += int64(len(line) + 1)
+	if r.bufBytes > r.limit { return r.spill() }
+	return nil
+}
+
+func (r *runSorter) sortBuffer() {
+	sort.SliceStable(r.buf, func(i, j int) bool { return r.less(r.buf[i].key, r.buf[j].key) })
+}
+
+// spill vuelca el buffer ordenado a una corrida en disco
+func (r *runSorter) spill() error {
+	r.sortBuffer()
+	path := fmt.Sprintf("/tmp/sort_run_%d_%d.jsonl", time.Now().UnixNano(), len(r.runs))
+	f, err := os.Create(path)
+	if err != nil { return err }
+	w := bufio.NewWriter(f)
+	for _, rec := range r.buf { w.WriteString(rec.line + "\n") }
+	err = w.Flush()
+	f.Close()
+	r.runs = append(r.runs, path)
+	if err != nil { return err }
+	r.buf, r.bufBytes = nil, 0
+	log.Printf("[Executor] Corrida de sort a disco: %s", path)
+	return nil
+}
+
+// WriteTo escribe todos los registros en orden y devuelve el índice del bloque: la clave y el offset
+// del primer registro de cada tramo de ~1/sortIndexEntries del total
+func (r *runSorter) WriteTo(w *bufio.Writer) ([]common.KeyOffset, error) {
+	var index []common.KeyOffset
+	step := max(r.total/sortIndexEntries, 1)
+	var offset, next int64
+	write := func(key, line string) error {
+		if offset >= next {
+			index = append(index, common.KeyOffset{Key: key, Offset: offset})
+			next = offset + step
+		}
+		_, err := w.WriteString(line + "\n")
+		offset += int64(len(line) + 1)
+		return err
+	}
+
+	if len(r.runs) == 0 {
+		r.sortBuffer()
+		for _, rec := range r.buf {
+			if err := write(rec.key, rec.line); err != nil { return nil, err }
+		}
+		return index, nil
+	}
+	if len(r.buf) > 0 {
+		if err := r.spill(); err != nil { return nil, err }
+	}
+	err := mergeSorted(r.runs, r.less, func(kv common.KeyValue, line string) error { return write(kv.Key, line) })
+	return index, err
+}
+
+func (r *runSorter) Cleanup() {
+	for _, p := range r.runs { os.Remove(p) }
+}
+
+// finishBlock escribe el bloque ordenado en la única partición de la tarea y le agrega su índice
+func (r *runSorter) finishBlock(writers map[int]*bufio.Writer, files map[int]*os.File, paths map[int]string) ([]common.ShuffleMeta, error) {
+	index, err := r.WriteTo(writers[0])
+	if err != nil { return nil, err }
+	metas, err := generateMeta(writers, files, paths, nil)
+	if err != nil { return nil, err }
+	metas[0].KeyIndex = index
+	return metas, nil
+}
+
+// ------------------------------------------
+// MEZCLA DE ARCHIVOS ORDENADOS
+// ------------------------------------------
+
+// sortedCursor es la línea actual de un archivo ordenado durante la mezcla
+type sortedCursor struct {
+	sc   *bufio.Scanner
+	kv   common.KeyValue
+	line string
+}
+
+// next avanza a la próxima línea con clave (descarta las que no son pares clave-valor)
+func (c *sortedCursor) next() bool {
+	for c.sc.Scan() {
+		c.kv = common.KeyValue{}
+		if err := json.Unmarshal(c.sc.Bytes(), &c.kv); err == nil && c.kv.Key != "" {
+			c.line = c.sc.Text()
+			return true
+		}
+	}
+	return false
+}
+
+type cursorHeap struct {
+	cursors []*sortedCursor
+	less    func(a, b string) bool
+}
+
+func (h *cursorHeap) Len() int { return len(h.cursors) }
+func (h *cursorHeap) Less(i, j int) bool {
+	if h.cursors[i].kv.Key != h.cursors[j].kv.Key { return h.less(h.cursors[i].kv.Key, h.cursors[j].kv.Key) }
+	return i < j
+}
+func (h *cursorHeap) Swap(i, j int)       { h.cursors[i], h.cursors[j] = h.cursors[j], h.cursors[i] }
+func (h *cursorHeap) Push(x interface{}) { h.cursors = append(h.cursors, x.(*sortedCursor)) }
+func (h *cursorHeap) Pop() interface{} {
+	last := h.cursors[len(h.cursors)-1]
+	h.cursors = h.cursors[:len(h.cursors)-1]
+	return last
+}
+
+// mergeSorted recorre en orden las líneas de varios archivos JSONL ya ordenados por clave
+func mergeSorted(paths []string, less func(a, b string) bool, emit func(kv common.KeyValue, line string) error) error {
+	h := &cursorHeap{less: less}
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil { return err }
+		defer f.Close()
+		c := &sortedCursor{sc: bufio.NewScanner(f)}
+		if c.next() { h.cursors = append(h.cursors, c) }
+	}
+	heap.Init(h)
+	for h.Len() > 0 {
+		c := h.cursors[0]
+		if err := emit(c.kv, c.line); err != nil { return err }
+		if c.next() {
+			heap.Fix(h, 0)
+		} else {
+			heap.Pop(h)
+		}
+	}
+	return nil
+}
+
+// ------------------------------------------
+// LADO SORT
+// ------------------------------------------
+
+// executeSortSide ordena un rango de claves (TaskInput.KeyRange): descarga de cada bloque ordenado del
+// padre las claves del rango a un archivo temporal y los mezcla en orden hacia la salida
+func executeSortSide(ctx context.Context, task common.Task) ([]common.ShuffleMeta, error) {
+	postFn, err := taskPipeline(ctx, task, task.Pipeline)
+	if err != nil { return nil, err }
+	less := common.KeyLess(task.Operation.Order)
+
+	var runs []string
+	defer func() {
+		for _, p := range runs { os.Remove(p) }
+	}()
+	for _, url := range task.InputPartition.ShuffleMap {
+		path := fmt.Sprintf("/tmp/sort_in_%s_%d_%d.tmp", task.TaskID, task.Attempt, len(runs))
+		runs = append(runs, path)
+		if err := downloadKeyRange(ctx, url, path, task.InputPartition.KeyRange, less); err != nil { return nil, err }
+	}
+
+	write, finish, err := openReduceOutput(task)
+	if err != nil { return nil, err }
+	err = mergeSorted(runs, less, func(kv common.KeyValue, _ string) error {
+		// Punto de cancelación cooperativa: se descarta la salida parcial
+		if err := ctx.Err(); err != nil { return err }
+		b, _ := json.Marshal(common.KeyValue{Key: kv.Key, Value: kv.Value})
+		for _, out := range postFn(udf.Record(b)) { write(out) }
+		return nil
+	})
+	if err != nil {
+		metas, _ := finish()
+		discardOutputs(task, metaPaths(metas))
+		return nil, err
+	}
+	return finish()
+}
+
+// downloadKeyRange guarda en destPath los registros de un bloque ordenado cuya clave cae en el rango
+func downloadKeyRange(ctx context.Context, url, destPath string, keyRange []string, less func(a, b string) bool) error {
+	f, err := os.Create(destPath)
+	if err != nil { return err }
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	err = streamShuffle(ctx, url, func(kv common.KeyValue) {
+		if !common.InKeyRange(kv.Key, keyRange, less) { return }
+		b, _ := json.Marshal(kv)
+		w.Write(append(b, '\n'))
+	})
+	if err != nil { return err }
+	return w.Flush()
+}
@@ -0,0 +1,131 @@
+package worker
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+
+	"mini-spark/internal/common"
+	"mini-spark/internal/udf"
+)
+
+func TestRunSorter(t *testing.T) {
+	for _, order := range []string{common.SortAscending, common.SortDescending} {
+		t.Run(order, func(t *testing.T) {
+			sorter := newRunSorter(order)
+			sorter.limit = 200 // Fuerza varias corridas a disco
+			defer sorter.Cleanup()
+			var keys []string
+			for i := 0; i < 100; i++ {
+				key := fmt.Sprintf("k%03d", (i*37)%100)
+				keys = append(keys, key)
+				b, _ := json.Marshal(common.KeyValue{Key: key, Value: "v"})
+				if err := sorter.Add(key, string(b)); err != nil { t.Fatalf("Error agregando: %v", err) }
+			}
+			if len(sorter.runs) < 2 { t.Fatalf("Se esperaban corridas a disco, hay %d", len(sorter.runs)) }
+
+			var buf bytes.Buffer
+			w := bufio.NewWriter(&buf)
+			index, err := sorter.WriteTo(w)
+			if err != nil { t.Fatalf("Error escribiendo: %v", err) }
+			w.Flush()
+
+			less := common.KeyLess(order)
+			sort.Slice(keys, func(i, j int) bool { return less(keys[i], keys[j]) })
+			var got []string
+			for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+				var kv common.KeyValue
+				json.Unmarshal([]byte(line), &kv)
+				got = append(got, kv.Key)
+			}
+			if strings.Join(got, ",") != strings.Join(keys, ",") {
+				t.Errorf("Bloque mal ordenado: %v", got)
+			}
+			// Cada entrada del índice apunta al inicio del registro de su clave
+			if len(index) < 2 || index[0].Offset != 0 { t.Fatalf("Índice inesperado: %+v", index) }
+			for _, entry := range index {
+				if !strings.HasPrefix(buf.String()[entry.Offset:], `{"key":"`+entry.Key+`"`) {
+					t.Errorf("La entrada %+v no apunta a su registro", entry)
+				}
+			}
+		})
+	}
+}
+
+func TestExecutor_SortByKeyRejectsKeyless(t *testing.T) {
+	// Lado map: to_uppercase emite líneas que no son pares clave/valor
+	inputPath := createInputFile(t, t.TempDir(), "words.txt", "delta\nalfa\n")
+	mapTask := createMockTask("job-keyless", "upper", common.OpTypeMap, "to_uppercase", common.OutputTypeShuffle, 1, inputPath, nil)
+	mapTask.OutputTarget.Sort = common.SortAscending
+	_, err := executeTaskLogic(context.Background(), mapTask)
+	if err == nil || !strings.Contains(err.Error(), "sin clave") {
+		t.Fatalf("Un registro sin clave antes de un SORT_BY_KEY debía hacer fallar la tarea: %v", err)
+	}
+	block := common.AttemptShufflePath(mapTask.OutputTarget.Path, mapTask.TaskID, mapTask.Attempt, "part_0")
+	if _, statErr := os.Stat(block); !os.IsNotExist(statErr) {
+		t.Errorf("La tarea fallida no debía dejar su bloque: %v", statErr)
+	}
+
+	// Lado reduce: la salida ordenada rechaza igual un registro sin clave entre registros con clave
+	reduceTask := createMockTask("job-keyless", "counts", common.OpTypeReduceByKey, "reduce_sum", common.OutputTypeShuffle, 1, "", map[string]string{})
+	reduceTask.OutputTarget.Sort = common.SortAscending
+	write, finish, err := openReduceOutput(reduceTask)
+	if err != nil { t.Fatalf("Error abriendo la salida: %v", err) }
+	write(udf.Record(`{"key":"alfa","value":"1"}`))
+	write(udf.Record("SIN CLAVE"))
+	if _, err := finish(); err == nil || !strings.Contains(err.Error(), "SIN CLAVE") {
+		t.Errorf("El cierre debía informar el registro sin clave: %v", err)
+	}
+	block = common.AttemptShufflePath(reduceTask.OutputTarget.Path, reduceTask.TaskID, reduceTask.Attempt, "part_0")
+	if _, statErr := os.Stat(block); !os.IsNotExist(statErr) {
+		t.Errorf("La salida del reduce fallido no debía quedar: %v", statErr)
+	}
+}
+
+func TestExecutor_SortByKey(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(handleShuffleFetch))
+	defer server.Close()
+
+	// Lado map: el shuffle que lee un SORT_BY_KEY es un bloque ordenado con índice
+	inputPath := createInputFile(t, t.TempDir(), "words.txt", "delta alfa charlie\nbravo echo alfa\n")
+	mapTask := createMockTask("job-sort", "words", common.OpTypeMap, "map_wordcount", common.OutputTypeShuffle, 1, inputPath, nil)
+	mapTask.OutputTarget.Sort = common.SortDescending
+	metas, err := executeTaskLogic(context.Background(), mapTask)
+	if err != nil || len(metas) != 1 || len(metas[0].KeyIndex) == 0 {
+		t.Fatalf("Se esperaba un bloque ordenado con índice: %v %+v", err, metas)
+	}
+	block := metas[0]
+
+	// Lado sort: dos rangos descendentes, [fin, charlie) y [charlie, inicio)
+	sortRange := func(keyRange []string) []string {
+		t.Helper()
+		url := fmt.Sprintf("%s/shuffle?path=%s&start=0&end=%d", server.URL, block.Path, block.Size)
+		task := createMockTask("job-sort", "sorted", common.OpTypeSortByKey, "", common.OutputTypeLocalSpill, 1, "", map[string]string{"w1": url})
+		task.TaskID += strings.Join(keyRange, "-")
+		task.Operation.Order = common.SortDescending
+		task.InputPartition.KeyRange = keyRange
+		out, err := executeTaskLogic(context.Background(), task)
+		if err != nil { t.Fatalf("Error ordenando: %v", err) }
+		var keys []string
+		for _, line := range strings.Fields(readOutputFile(t, out[0].Path)) {
+			var kv common.KeyValue
+			json.Unmarshal([]byte(line), &kv)
+			keys = append(keys, kv.Key)
+		}
+		return keys
+	}
+	if got := strings.Join(sortRange([]string{"", "charlie"}), ","); got != "echo,delta" {
+		t.Errorf("Primer rango incorrecto: %s", got)
+	}
+	if got := strings.Join(sortRange([]string{"charlie", ""}), ","); got != "charlie,bravo,alfa,alfa" {
+		t.Errorf("Segundo rango incorrecto: %s", got)
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"strings"
 	"sync"   // Usado por MasterMock
 	"testing"
+	"time"
 
 	"mini-spark/internal/common"
 	"mini-spark/internal/worker"
@@ -87,8 +88,8 @@ func TestE2EFlow(t *testing.T) {
 	// Ruta BASE que la tarea usa
 	outputBasePath := filepath.Join(tempDir, "output_task.txt") 
     
-    // Ruta REAL esperada que crea el Executor para una salida simple (_part_0)
-    expectedReportPath := outputBasePath + "_part_0" 
+    // Ruta REAL esperada que crea el Executor para una salida simple (<base>_<taskID>_attempt_<n>_part_0)
+    expectedReportPath := outputBasePath + "_task-123_attempt_0_part_0"
 
 	task := common.Task{
 		TaskID: "task-123",
@@ -108,6 +109,9 @@ func TestE2EFlow(t *testing.T) {
 		},
 	}
 
+	// El pool de ejecución lo inicializa normalmente StartServer
+	worker.InitExecutor(1)
+
 	// --- MOCKING (Interceptar la red de reporte) ---
 	originalReportFn := worker.ReportToMaster
 	worker.ReportToMaster = func(report common.TaskReport) error {
@@ -131,9 +135,15 @@ func TestE2EFlow(t *testing.T) {
 		t.Fatalf("Worker devolvió error HTTP: %d Body: %s", rr.Code, rr.Body.String())
 	}
 
-	masterMock.ReportMutex.Lock()
-	report := masterMock.ReceivedReport
-	masterMock.ReportMutex.Unlock()
+	// La tarea corre en segundo plano: esperar el reporte (con límite)
+	var report *common.TaskReport
+	deadline := time.Now().Add(5 * time.Second)
+	for report == nil && time.Now().Before(deadline) {
+		masterMock.ReportMutex.Lock()
+		report = masterMock.ReceivedReport
+		masterMock.ReportMutex.Unlock()
+		if report == nil { time.Sleep(10 * time.Millisecond) }
+	}
 
 	if report == nil {
 		t.Fatal("El Master Mock no recibió ningún reporte.")
@@ -19,20 +19,20 @@ func main() {
 	}
 	os.WriteFile("data/inputs/wordcount.txt", []byte(wcContent), 0644)
 
-	// 2. DATA PARA JOIN (Usuarios + Pedidos Mixtos)
-	// Formato: U,ID,Nombre  y  O,OrderID,UserID,Producto
-	fmt.Println("Generando data/inputs/join_data.txt ...")
-	joinContent := ""
-	// Usuarios (1 al 10)
+	// 2. DATA PARA JOIN MULTI-FUENTE (un archivo por tabla, como llegan de sistemas distintos)
+	fmt.Println("Generando data/inputs/join_users.csv y data/inputs/join_orders.csv ...")
+	usersTable := "ID,Nombre\n"
+	ordersTable := "OrderID,UserID,Producto\n"
 	for i := 1; i <= 10; i++ {
-		joinContent += fmt.Sprintf("U,%d,Usuario%d\n", i, i)
+		usersTable += fmt.Sprintf("%d,Usuario%d\n", i, i)
+		ordersTable += fmt.Sprintf("10%d,%d,Laptop\n", i, i)
+		ordersTable += fmt.Sprintf("20%d,%d,Mouse\n", i, i)
 	}
-	// Pedidos (Cada usuario hace 2 pedidos)
-	for i := 1; i <= 10; i++ {
-		joinContent += fmt.Sprintf("O,10%d,%d,Laptop\n", i, i)
-		joinContent += fmt.Sprintf("O,20%d,%d,Mouse\n", i, i)
-	}
-	os.WriteFile("data/inputs/join_data.txt", []byte(joinContent), 0644)
+	// Filas sin pareja para los joins externos: un usuario sin pedidos y un pedido huérfano
+	usersTable += "11,Usuario11\n"
+	ordersTable += "999,99,Teclado\n"
+	os.WriteFile("data/inputs/join_users.csv", []byte(usersTable), 0644)
+	os.WriteFile("data/inputs/join_orders.csv", []byte(ordersTable), 0644)
 
 	// 3. DATA PARA FILTER/FLATMAP (CSV Simple)
 	// Formato: ID,Nombre,Edad,Ciudad
@@ -45,6 +45,17 @@ func main() {
 	usersContent += "5,Luis,12,Sevilla\n"  // Menor de edad
 	os.WriteFile("data/inputs/users.csv", []byte(usersContent), 0644)
 
+	// 5. LOGS ROTADOS (un archivo por hora, para entradas de directorio/glob)
+	fmt.Println("Generando data/inputs/logs/app-2024-01-15-HH.log ...")
+	os.MkdirAll("data/inputs/logs", 0755)
+	for h := 0; h < 24; h++ {
+		logContent := ""
+		for i := 0; i < 10+h*5; i++ {
+			logContent += fmt.Sprintf("2024-01-15T%02d:%02d:00 INFO request %d ok\n", h, i%60, i)
+		}
+		os.WriteFile(fmt.Sprintf("data/inputs/logs/app-2024-01-15-%02d.log", h), []byte(logContent), 0644)
+	}
+
 	fmt.Println(" Todos los datos generados exitosamente.")
 
 